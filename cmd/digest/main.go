@@ -1,17 +1,21 @@
 // File: cmd/digest/main.go
 
 // This program sends daily, weekly, monthly, and quarterly digests.
-// It also does caching and cleanup jobs.
+// It also does caching, anomaly detection, and cleanup jobs.
 
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"twothumbs/internal/config"
 	"twothumbs/internal/cronjobs"
 	"twothumbs/internal/digests"
+	"twothumbs/internal/integrations"
 	"twothumbs/internal/utils"
 )
 
@@ -26,9 +30,17 @@ func main() {
 	}
 	defer conn.Close()
 
+	// Set the master keys to encrypt and decrypt bot tokens, and the credentials to refresh them
+	utils.SetTokenKeyring(cfg.TokenKeyring)
+	integrations.SetSlackAppCredentials(cfg.SlackAppClientId, cfg.SlackAppClientSecret)
+
+	// Stop the AI calls of the run when it is terminated, e.g. by a job timeout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Run daily cache job
 	log.Println("Starting daily cache job...")
-	if err := cronjobs.RunDailyCacheJob(conn, cfg); err != nil {
+	if err := cronjobs.RunDailyCacheJob(ctx, conn, cfg); err != nil {
 		log.Printf("Daily cache job failed: %v", err)
 	} else {
 		log.Println("Daily cache job completed.")
@@ -40,7 +52,7 @@ func main() {
 
 	// Send daily digests
 	log.Println("Sending daily digests...")
-	if err := digests.SendDailyDigests(conn, cfg); err != nil {
+	if err := digests.SendDailyDigests(ctx, conn, cfg); err != nil {
 		log.Printf("Failed to send daily digests: %v", err)
 		digestErr = err
 	} else {
@@ -51,7 +63,7 @@ func main() {
 	if utils.IsMonday() {
 		// Send weekly digests
 		log.Println("Sending weekly digests...")
-		if err := digests.SendWeeklyDigests(conn, cfg); err != nil {
+		if err := digests.SendWeeklyDigests(ctx, conn, cfg); err != nil {
 			log.Printf("Failed to send weekly digests: %v", err)
 			digestErr = err
 		} else {
@@ -62,7 +74,7 @@ func main() {
 	if utils.IsFirstWeekdayOfMonth() {
 		// Send monthly digests
 		log.Println("Sending monthly digests...")
-		if err := digests.SendMonthlyDigests(conn, cfg); err != nil {
+		if err := digests.SendMonthlyDigests(ctx, conn, cfg); err != nil {
 			log.Printf("Failed to send monthly digests: %v", err)
 			digestErr = err
 		} else {
@@ -73,7 +85,7 @@ func main() {
 	if utils.IsSecondWeekdayOfQuarter() {
 		// Send quarterly digests
 		log.Println("Sending quarterly digests...")
-		if err := digests.SendQuarterlyDigests(conn, cfg); err != nil {
+		if err := digests.SendQuarterlyDigests(ctx, conn, cfg); err != nil {
 			log.Printf("Failed to send quarterly digests: %v", err)
 			digestErr = err
 		} else {
@@ -81,12 +93,20 @@ func main() {
 		}
 	}
 
+	// Detect significant shifts in yesterday's feedback
+	log.Println("Detecting anomalies...")
+	if err := cronjobs.RunAnomalyDetection(conn); err != nil {
+		log.Printf("Anomaly detection failed: %v", err)
+	} else {
+		log.Println("Anomaly detection completed.")
+	}
+
 	// *Run maintenance jobs*
 
 	// Run the cleanup job only after processing the digests
 	if utils.IsFirstWeekdayOfMonth() && digestErr == nil {
 		log.Println("Starting the cleanup job...")
-		if err := cronjobs.RunCleanup(conn); err != nil {
+		if err := cronjobs.RunCleanup(conn, cfg); err != nil {
 			log.Printf("Cleanup job failed: %v", err)
 		} else {
 			log.Println("Cleanup job completed.")
@@ -5,15 +5,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"twothumbs/internal/api"
 	"twothumbs/internal/config"
+	"twothumbs/internal/digests"
+	"twothumbs/internal/integrations"
 	"twothumbs/internal/utils"
+	"twothumbs/internal/webhooks"
 )
 
 func main() {
@@ -27,32 +32,71 @@ func main() {
 	}
 	defer conn.Close()
 
+	// Set the master keys to encrypt and decrypt bot tokens, and the credentials to refresh them
+	utils.SetTokenKeyring(cfg.TokenKeyring)
+	integrations.SetSlackAppCredentials(cfg.SlackAppClientId, cfg.SlackAppClientSecret)
+
+	// Start delivering new feedback to webhooks
+	dispatcher := webhooks.NewDispatcher(conn, cfg.WebhookMaxAttempts, cfg.WebhookAllowPrivateURLs)
+	go dispatcher.Run(context.Background())
+
+	// Start posting thumbs-down comments on live prompts
+	go digests.RunLiveAlerts(context.Background(), conn, time.Duration(cfg.LiveAlertInterval)*time.Second)
+
 	// Initialize handlers
 	slackHandler := api.NewSlackHandler(conn, cfg)
-	feedbackHandler := api.NewFeedbackHandler(conn, cfg)
+	feedbackHandler := api.NewFeedbackHandler(conn, cfg, dispatcher)
 
 	router := gin.Default()
 
-	// Add rate limiter middleware
-	rateLimiter := utils.NewRateLimiter(
+	// Add rate limiter middleware: a per-IP limit in front of authentication, so that requests with
+	// made-up API keys are limited too, and a per-key limit behind it
+	rateLimitStore := utils.NewRateLimitStore(cfg.RateLimitBackend, conn, cfg.FeedbackRateLimitWindow, "feedback-ip", "feedback-key") // Use window as cleanup interval
+	var apiMiddleware []gin.HandlerFunc
+	if cfg.IPRateLimitRequests > 0 {
+		ipRateLimiter := utils.NewRateLimiter(
+			rateLimitStore,
+			"feedback-ip",
+			cfg.IPRateLimitRequests,
+			cfg.FeedbackRateLimitWindow,
+			utils.ClientIPRateLimitKey,
+		)
+		apiMiddleware = append(apiMiddleware, ipRateLimiter.Limit())
+	}
+	keyRateLimiter := utils.NewRateLimiter(
+		rateLimitStore,
+		"feedback-key",
 		cfg.FeedbackRateLimitRequests,
 		cfg.FeedbackRateLimitWindow,
-		cfg.FeedbackRateLimitWindow, // Use window as cleanup interval
+		utils.APIKeyRateLimitKey,
 	)
+	apiMiddleware = append(apiMiddleware, feedbackHandler.Authenticate(), keyRateLimiter.Limit())
 
 	// Health check endpoint
 	router.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "pong"})
 	})
 
-	// Slack oauth endpoint
+	// Slack oauth endpoints
+	router.GET("/slack/install", slackHandler.Install)
 	router.GET("/slack/oauth/callback", slackHandler.OAuthCallback)
 
-	// Slack events endpoint
-	router.POST("/slack/events", slackHandler.EventsHandler)
+	// Slack events endpoint, verified by signature
+	slackVerifier := utils.NewSlackVerifier(cfg.SlackSigningSecret, utils.SlackReplayWindow)
+	router.POST("/slack/events", slackVerifier.Verify(), slackHandler.EventsHandler)
+
+	// Slack commands endpoint, verified by signature
+	router.POST("/slack/commands", slackVerifier.Verify(), slackHandler.CommandsHandler)
+
+	// Feedback endpoints with authentication and rate limiting
+	feedback := router.Group("/feedback", apiMiddleware...)
+	feedback.POST("", feedbackHandler.PostFeedback)
+	feedback.POST("/batch", feedbackHandler.PostFeedbackBatch)
+	feedback.GET("", feedbackHandler.GetFeedback)
 
-	// Feedback endpoint with rate limiting
-	router.POST("/feedback", rateLimiter.Limit(), feedbackHandler.PostFeedback)
+	// Stats endpoint with authentication and rate limiting
+	stats := router.Group("/stats", apiMiddleware...)
+	stats.GET("", feedbackHandler.GetStats)
 
 	// Start server
 	if err := router.Run(":8080"); err != nil {
@@ -5,8 +5,6 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
 	"log"
 	"net/http"
 	"os"
@@ -14,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"twothumbs/internal/config"
+	"twothumbs/internal/integrations"
 	"twothumbs/internal/interactions"
 	"twothumbs/internal/utils"
 )
@@ -29,6 +28,10 @@ func main() {
 	}
 	defer conn.Close()
 
+	// Set the master keys to encrypt and decrypt bot tokens, and the credentials to refresh them
+	utils.SetTokenKeyring(cfg.TokenKeyring)
+	integrations.SetSlackAppCredentials(cfg.SlackAppClientId, cfg.SlackAppClientSecret)
+
 	router := gin.Default()
 
 	// Health check endpoint
@@ -36,52 +39,12 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"message": "pong"})
 	})
 
-	// Register Slack interaction handler
-	router.POST("/slack/interact", slackInteractHandler(cfg, conn))
+	// Register Slack interaction handler, verified by signature
+	slackVerifier := utils.NewSlackVerifier(cfg.SlackSigningSecret, utils.SlackReplayWindow)
+	router.POST("/slack/interact", slackVerifier.Verify(), interactions.SlackInteractHandler(cfg, conn))
 
 	// Start server
 	if err := router.Run(":8080"); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
-
-// Interaction handler for Slack interactions
-func slackInteractHandler(cfg *config.InteractConfig, conn *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var payload map[string]any
-		if err := json.Unmarshal([]byte(c.PostForm("payload")), &payload); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-			log.Printf("failed to unmarshal payload: %v", err)
-			return
-		}
-
-		// Detect interaction type
-		switch payload["type"] {
-		case "view_submission":
-			// Modal submission
-			log.Printf("processing a modal submission")
-			interactions.HandleModalSubmission(c, payload, conn, cfg)
-			return
-
-		case "block_actions":
-			// Detect source from container type
-			container, ok := payload["container"].(map[string]any)
-			if ok {
-				switch container["type"] {
-				case "view":
-					log.Printf("processing a view interaction")
-					interactions.HandleViewInteraction(c, payload, conn, cfg)
-					return
-				case "message":
-					log.Printf("processing a message interaction")
-					interactions.HandleMessageInteraction(c, payload, conn, cfg)
-					return
-				}
-			}
-		}
-
-		// Unsupported payload
-		log.Printf("unsupported payload: %v", payload)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported payload"})
-	}
-}
@@ -1,12 +1,15 @@
 // File: cmd/keygen/main.go
 
-// This helper program prints a new activation code and API key.
+// This helper program prints a new activation code and API key, along with the SQL to store the key hashed.
 
 package main
 
 import (
 	"fmt"
 	"log"
+	"strings"
+
+	"twothumbs/internal/models"
 	"twothumbs/internal/utils"
 )
 
@@ -19,5 +22,17 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	salt, err := utils.GenerateApiKeySalt()
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Printf("Activation code: %s\nAPI key: %s\n", activationCode, apiKey)
+	fmt.Println("\nThe API key is only stored hashed, so hand it over now. It cannot be shown again.")
+	fmt.Printf(
+		"\nStore the key for the new account with:\nINSERT INTO api_keys (account_id, name, key_prefix, key_salt, key_hash, scopes) VALUES (<account_id>, 'Default', '%s', '%s', '%s', ARRAY['%s']);\n",
+		utils.ApiKeyPrefix(apiKey),
+		salt,
+		utils.HashApiKey(apiKey, salt),
+		strings.Join(models.APIKeyScopes, "', '"),
+	)
 }
@@ -0,0 +1,59 @@
+// File: cmd/rekey/main.go
+
+// This helper program encrypts legacy plaintext bot tokens and re-encrypts the data keys of all
+// bot tokens and webhook secrets with the current master key. Tokens and secrets not yet bound to
+// their row are encrypted again. Run it after adding a new master key to the front of
+// TOKEN_MASTER_KEYS, and remove the old key once it reports nothing left to rewrap.
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"twothumbs/internal/queries"
+	"twothumbs/internal/utils"
+)
+
+func main() {
+	log.SetOutput(os.Stdout)
+
+	tokenKeyring, err := utils.ParseTokenKeyring(utils.GetEnv("TOKEN_MASTER_KEYS"))
+	if err != nil {
+		log.Fatalf("Invalid TOKEN_MASTER_KEYS: %v", err)
+	}
+	utils.SetTokenKeyring(tokenKeyring)
+
+	// Connect to the database
+	conn, err := utils.ConnectToDB(utils.GetEnv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer conn.Close()
+
+	// Encrypt tokens left over from before encryption at rest
+	legacy, err := queries.HasLegacyBotTokenColumn(conn)
+	if err != nil {
+		log.Fatalf("Failed to check for plaintext bot tokens: %v", err)
+	}
+	if legacy {
+		n, err := queries.EncryptLegacyBotTokens(conn)
+		if err != nil {
+			log.Fatalf("Failed to encrypt plaintext bot tokens after %d rows: %v", n, err)
+		}
+		log.Printf("Encrypted %d plaintext bot tokens.", n)
+	}
+
+	// Rewrap data keys with the current master key
+	n, err := queries.RewrapBotTokens(conn)
+	if err != nil {
+		log.Fatalf("Failed to rewrap bot tokens after %d rows: %v", n, err)
+	}
+	log.Printf("Rewrapped %d bot tokens with master key %s.", n, tokenKeyring.CurrentID())
+
+	m, err := queries.RewrapWebhookSecrets(conn)
+	if err != nil {
+		log.Fatalf("Failed to rewrap webhook secrets after %d rows: %v", m, err)
+	}
+	log.Printf("Rewrapped %d webhook secrets with master key %s.", m, tokenKeyring.CurrentID())
+}
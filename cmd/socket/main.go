@@ -0,0 +1,68 @@
+// File: cmd/socket/main.go
+
+// This program receives Slack events, interactions, and slash commands over Socket Mode,
+// so that a deployment does not need to expose the Slack endpoints publicly.
+// It runs the handlers of the ingest and interact services behind the Socket Mode client.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"twothumbs/internal/api"
+	"twothumbs/internal/config"
+	"twothumbs/internal/integrations"
+	"twothumbs/internal/interactions"
+	"twothumbs/internal/utils"
+)
+
+func main() {
+	log.SetOutput(os.Stdout)
+	cfg := config.LoadSocketConfig()
+	ingestCfg := config.LoadIngestConfig()
+	interactCfg := config.LoadInteractConfig()
+
+	// Connect to the database
+	conn, err := utils.ConnectToDB(ingestCfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer conn.Close()
+
+	// Set the master keys to encrypt and decrypt bot tokens, and the credentials to refresh them
+	utils.SetTokenKeyring(ingestCfg.TokenKeyring)
+	integrations.SetSlackAppCredentials(ingestCfg.SlackAppClientId, ingestCfg.SlackAppClientSecret)
+
+	// Slack handlers served to the Socket Mode client only, so no signature verification
+	slackHandler := api.NewSlackHandler(conn, ingestCfg)
+	slackRouter := gin.New()
+	slackRouter.Use(gin.Logger(), gin.Recovery())
+	slackRouter.POST("/slack/events", slackHandler.EventsHandler)
+	slackRouter.POST("/slack/commands", slackHandler.CommandsHandler)
+	slackRouter.POST("/slack/interact", interactions.SlackInteractHandler(interactCfg, conn))
+
+	// Receive envelopes from Slack
+	client := integrations.NewSocketModeClient(cfg.SlackAppToken, cfg.SlackSocketOpenURL, slackRouter)
+	go func() {
+		if err := client.Run(context.Background()); err != nil {
+			log.Fatalf("Socket Mode client stopped: %v", err)
+		}
+	}()
+
+	router := gin.Default()
+
+	// Health check endpoint
+	router.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+
+	// Start server
+	if err := router.Run(":8080"); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
@@ -3,26 +3,73 @@
 CREATE TABLE installations (
     slack_workspace TEXT PRIMARY KEY,
     created_at TIMESTAMPTZ DEFAULT NOW(),
-    bot_token TEXT NOT NULL
+    bot_token_ciphertext BYTEA NOT NULL,
+    bot_token_data_key BYTEA NOT NULL,
+    bot_token_key_id TEXT NOT NULL,
+    bot_token_hash TEXT NOT NULL,
+    refresh_token_ciphertext BYTEA,
+    bot_token_expires_at TIMESTAMPTZ,
+    bot_token_aad BOOLEAN NOT NULL DEFAULT FALSE, -- whether the tokens are bound to the row, see cmd/rekey
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    bot_user_id TEXT NOT NULL DEFAULT '',
+    installed_by TEXT NOT NULL DEFAULT '',
+    enterprise_id TEXT,
+    is_enterprise_install BOOLEAN NOT NULL DEFAULT FALSE,
+    app_id TEXT NOT NULL DEFAULT '',
+    installed_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+CREATE TABLE enterprise_workspaces (
+    slack_workspace TEXT PRIMARY KEY,
+    enterprise_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_enterprise_workspaces_enterprise_id ON enterprise_workspaces (enterprise_id);
+
 CREATE TABLE accounts (
     account_id BIGSERIAL PRIMARY KEY,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     account_expiry_date TIMESTAMPTZ NOT NULL,
     activation_code TEXT UNIQUE NOT NULL,
-    api_key TEXT UNIQUE NOT NULL,
-    slack_workspace TEXT,
-    slack_channel TEXT,
     feedback_count INT NOT NULL DEFAULT 0
 );
 
+CREATE TABLE account_workspaces (
+    slack_workspace TEXT PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+    slack_channel TEXT,
+    linked_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_account_workspaces_account_id ON account_workspaces (account_id);
+
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+    slack_workspace TEXT,
+    name TEXT NOT NULL,
+    key_prefix TEXT NOT NULL,
+    key_salt TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_key_prefix ON api_keys (key_prefix);
+
 CREATE TABLE prompts (
     id BIGSERIAL PRIMARY KEY,
     slack_workspace TEXT NOT NULL,
     origin TEXT NOT NULL,
     category TEXT NOT NULL,
     prompt TEXT NOT NULL,
+    scale TEXT NOT NULL DEFAULT 'thumbs' CHECK (scale IN ('thumbs', 'csat', 'nps')),
+    live BOOLEAN NOT NULL DEFAULT FALSE,
+    live_since TIMESTAMPTZ, -- when live mode was last turned on
     CONSTRAINT unique_prompt UNIQUE (slack_workspace, origin, category, prompt)
 );
 
@@ -36,9 +83,18 @@ CREATE TABLE feedback (
     origin TEXT NOT NULL,
     category TEXT NOT NULL,
     in_production BOOLEAN NOT NULL,
-    user_id TEXT NOT NULL
+    user_id TEXT NOT NULL,
+    rating SMALLINT,
+    scale TEXT NOT NULL DEFAULT 'thumbs' CHECK (scale IN ('thumbs', 'csat', 'nps')),
+    metadata JSONB,
+    alerted_at TIMESTAMPTZ,
+    alert_leased_until TIMESTAMPTZ -- while a live alert is being posted
 );
 
+CREATE INDEX idx_feedback_live_alerts ON feedback (created_at) WHERE alerted_at IS NULL AND NOT thumb_up AND comment IS NOT NULL;
+CREATE INDEX idx_feedback_metadata ON feedback USING GIN (metadata);
+CREATE INDEX idx_feedback_metadata_workspace ON feedback (slack_workspace, created_at) WHERE metadata IS NOT NULL;
+
 CREATE TABLE summaries (
     id BIGSERIAL PRIMARY KEY,
     summary_date DATE NOT NULL,
@@ -47,6 +103,7 @@ CREATE TABLE summaries (
     category TEXT NOT NULL,
     prompt TEXT NOT NULL,
     n_comments INT NOT NULL,
+    n_comments_summarized INT NOT NULL, -- at most n_comments, as the input of a summary is capped
     summary TEXT NOT NULL
 );
 
@@ -56,3 +113,62 @@ CREATE TABLE issues (
     report TEXT NOT NULL,
     PRIMARY KEY (slack_workspace, origin)
 );
+
+CREATE TABLE idempotency_keys (
+    account_id BIGINT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    status_code INT,
+    response_body BYTEA,
+    request_method TEXT NOT NULL DEFAULT '',
+    request_path TEXT NOT NULL DEFAULT '',
+    request_hash TEXT NOT NULL DEFAULT '', -- hex SHA-256 of the request body
+    PRIMARY KEY (account_id, idempotency_key)
+);
+
+CREATE TABLE rate_limits (
+    bucket_key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    last_allowed BOOLEAN NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_last_seen ON rate_limits (last_seen);
+
+CREATE TABLE slack_events (
+    event_id TEXT PRIMARY KEY,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    slack_workspace TEXT NOT NULL REFERENCES account_workspaces (slack_workspace) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret_ciphertext BYTEA NOT NULL,
+    secret_data_key BYTEA NOT NULL,
+    secret_key_id TEXT NOT NULL,
+    secret_aad BOOLEAN NOT NULL DEFAULT FALSE, -- whether the secret is bound to the row, see cmd/rekey
+    origins TEXT[] NOT NULL DEFAULT '{}',
+    categories TEXT[] NOT NULL DEFAULT '{}',
+    thumb TEXT NOT NULL DEFAULT 'any' CHECK (thumb IN ('any', 'up', 'down')),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhooks_slack_workspace ON webhooks (slack_workspace);
+
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    feedback_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at);
@@ -0,0 +1,162 @@
+// File: internal/api/commands.go
+
+// This file contains the handler for the /twothumbs slash command.
+// Each subcommand replies ephemerally, so feedback can be checked from any channel.
+
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"twothumbs/internal/config"
+	"twothumbs/internal/integrations"
+	"twothumbs/internal/interactions"
+	"twothumbs/internal/models"
+	"twothumbs/internal/queries"
+	"twothumbs/internal/templates/commands"
+	"twothumbs/internal/templates/modals"
+)
+
+// Handler for Slack slash commands, verified by the Slack signature middleware. Slack gives up on a
+// command after 3 seconds, so commands that load data are acknowledged right away and answered through
+// the response_url of the command once the data is loaded.
+func (h *SlackHandler) CommandsHandler(c *gin.Context) {
+	workspace := c.PostForm("team_id")
+	if workspace == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing team_id"})
+		return
+	}
+	enterpriseID := ""
+	if c.PostForm("is_enterprise_install") == "true" {
+		enterpriseID = c.PostForm("enterprise_id")
+	}
+
+	subcommand, args, _ := strings.Cut(strings.TrimSpace(c.PostForm("text")), " ")
+	args = strings.TrimSpace(args)
+
+	switch strings.ToLower(subcommand) {
+	case "stats", "comments", "issues":
+	case "", "help":
+		c.JSON(http.StatusOK, commands.EphemeralResponse("Two Thumbs  👍👎", commands.HelpBlocks()))
+		return
+	default:
+		c.JSON(http.StatusOK, commands.EphemeralResponse(
+			fmt.Sprintf("Unknown command: %s", subcommand),
+			commands.HelpBlocks(),
+		))
+		return
+	}
+
+	responseURL := c.PostForm("response_url")
+	if responseURL == "" {
+		c.JSON(http.StatusOK, h.runCommand(workspace, enterpriseID, strings.ToLower(subcommand), args))
+		return
+	}
+	submitted := h.Workers.Submit(func() {
+		resp := h.runCommand(workspace, enterpriseID, strings.ToLower(subcommand), args)
+		if err := integrations.SendResponseURLMessage(responseURL, resp); err != nil {
+			log.Printf("failed to reply to /twothumbs %s in workspace %s: %v", subcommand, workspace, err)
+		}
+	})
+	if !submitted {
+		c.JSON(http.StatusOK, commands.MessageResponse("Two Thumbs is busy right now. Please try again in a moment."))
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// Run a subcommand that loads data, returning the reply
+func (h *SlackHandler) runCommand(workspace, enterpriseID, subcommand, args string) map[string]any {
+	// Commands of org-wide installations tell which workspace of the enterprise they come from
+	if enterpriseID != "" {
+		if err := queries.SaveEnterpriseWorkspaces(h.DB, enterpriseID, []string{workspace}); err != nil {
+			log.Printf("error saving workspace %s of enterprise %s: %v", workspace, enterpriseID, err)
+		}
+	}
+
+	active, err := queries.IsActiveAccount(h.DB, workspace)
+	if err != nil {
+		log.Printf("could not check if account is active for workspace %s: %v", workspace, err)
+		return commands.MessageResponse("Something went wrong. Please try again in a moment.")
+	}
+	if !active {
+		return commands.MessageResponse("Two Thumbs is not linked to an active account yet. Open the Two Thumbs App Home to get started.")
+	}
+
+	switch subcommand {
+	case "stats":
+		return h.commandStats(workspace, args)
+	case "comments":
+		return h.commandComments(workspace, args)
+	default:
+		return h.commandIssues(workspace)
+	}
+}
+
+// Reply to /twothumbs stats [origin] [7d|30d]
+func (h *SlackHandler) commandStats(workspace, args string) map[string]any {
+	title, period, fetchFunc := "7-Day Stats", models.Last7d, queries.GetLast7DayStats
+	fields := strings.Fields(args)
+	if n := len(fields); n > 0 {
+		switch strings.ToLower(fields[n-1]) {
+		case "7d":
+			fields = fields[:n-1]
+		case "30d":
+			title, period, fetchFunc = "30-Day Stats", models.Last30d, queries.GetLast30DayStats
+			fields = fields[:n-1]
+		}
+	}
+	origin := strings.Join(fields, " ")
+
+	groups, err := queries.GetFeedbackGroups(h.DB, workspace, period)
+	if err != nil {
+		log.Printf("failed to get feedback groups for workspace %s: %v", workspace, err)
+		return commands.MessageResponse("Something went wrong. Please try again in a moment.")
+	}
+	if origin != "" {
+		var filtered []models.FeedbackGroup
+		for _, g := range groups {
+			if strings.EqualFold(g.Origin, origin) {
+				filtered = append(filtered, g)
+			}
+		}
+		if len(filtered) == 0 {
+			return commands.MessageResponse(fmt.Sprintf("No feedback for origin *%s* in the last %s.", origin, period))
+		}
+		groups = filtered
+		title = fmt.Sprintf("%s  ·  %s", title, filtered[0].Origin)
+	}
+
+	stats := interactions.FetchStats(h.DB, workspace, groups, fetchFunc)
+	return commands.EphemeralResponse(title, modals.StatsBlocks(stats))
+}
+
+// Reply to /twothumbs comments [origin]
+func (h *SlackHandler) commandComments(workspace, origin string) map[string]any {
+	results, err := queries.GetLatestComments(h.DB, workspace, origin, config.MaxCommandComments)
+	if err != nil {
+		log.Printf("failed to get latest comments for workspace %s: %v", workspace, err)
+		return commands.MessageResponse("Something went wrong. Please try again in a moment.")
+	}
+
+	title := "Latest Comments  💬"
+	if origin != "" {
+		title = fmt.Sprintf("Latest Comments  ·  %s", origin)
+	}
+	return commands.EphemeralResponse(title, modals.CommentsBlocks(results, config.MaxCommandComments))
+}
+
+// Reply to /twothumbs issues
+func (h *SlackHandler) commandIssues(workspace string) map[string]any {
+	issues, err := queries.GetTopIssuesByWorkspace(h.DB, workspace)
+	if err != nil {
+		log.Printf("failed to get top issues for workspace %s: %v", workspace, err)
+		return commands.MessageResponse("Something went wrong. Please try again in a moment.")
+	}
+	return commands.EphemeralResponse("Top Issues ❗️", modals.TopIssuesBlocks(issues))
+}
@@ -1,14 +1,16 @@
 // File: internal/api/feedback.go
 
-// This file includes the endpoint to push feedback to the database.
+// This file includes the endpoints to push feedback to the database.
 
 package api
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,25 +18,43 @@ import (
 	"twothumbs/internal/models"
 	"twothumbs/internal/queries"
 	"twothumbs/internal/utils"
+	"twothumbs/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
 )
 
 type FeedbackHandler struct {
-	DB     *sql.DB
-	Config *config.IngestConfig
+	DB       *sql.DB
+	Config   *config.IngestConfig
+	Webhooks *webhooks.Dispatcher // delivers new feedback to the webhooks of the account
 }
 
-func NewFeedbackHandler(conn *sql.DB, cfg *config.IngestConfig) *FeedbackHandler {
-	return &FeedbackHandler{DB: conn, Config: cfg}
+func NewFeedbackHandler(conn *sql.DB, cfg *config.IngestConfig, dispatcher *webhooks.Dispatcher) *FeedbackHandler {
+	return &FeedbackHandler{DB: conn, Config: cfg, Webhooks: dispatcher}
 }
 
 func validateFeedbackRequest(req *models.FeedbackRequest) (string, bool) {
 	if len(strings.TrimSpace(req.Prompt)) == 0 || len(req.Prompt) > config.MaxPromptLen {
 		return fmt.Sprintf("Prompt is required and must be at most %d characters", config.MaxPromptLen), false
 	}
-	if req.ThumbUp == nil {
-		return "ThumbUp is required", false
+	switch req.Scale {
+	case "", models.ScaleThumbs:
+		if req.ThumbUp == nil {
+			return "ThumbUp is required", false
+		}
+		if req.Rating != nil {
+			return "Rating requires the csat or nps scale", false
+		}
+	case models.ScaleCSAT:
+		if req.Rating == nil || *req.Rating < 1 || *req.Rating > 5 {
+			return "Rating is required and must be between 1 and 5 for the csat scale", false
+		}
+	case models.ScaleNPS:
+		if req.Rating == nil || *req.Rating < 0 || *req.Rating > 10 {
+			return "Rating is required and must be between 0 and 10 for the nps scale", false
+		}
+	default:
+		return "Scale must be one of thumbs, csat, or nps", false
 	}
 	if len(strings.TrimSpace(req.Origin)) == 0 || len(req.Origin) > config.MaxOriginLen {
 		return fmt.Sprintf("Origin is required and must be at most %d characters", config.MaxOriginLen), false
@@ -51,37 +71,71 @@ func validateFeedbackRequest(req *models.FeedbackRequest) (string, bool) {
 	if len(req.Comment) > config.MaxCommentLen {
 		return fmt.Sprintf("Comment must be at most %d characters", config.MaxCommentLen), false
 	}
-	return "", true
+	return validateMetadata(req.Metadata)
 }
 
-// Handler for POST /feedback
-func (h *FeedbackHandler) PostFeedback(c *gin.Context) {
-	// Authorize via X-API-Key
-	apiKey := c.GetHeader("X-API-Key")
-	if apiKey == "" {
-		log.Println("Missing X-API-Key header")
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
-		return
+// Metadata must be a flat object of scalar values, so that its keys can be used as filters
+func validateMetadata(metadata map[string]any) (string, bool) {
+	if len(metadata) > config.MaxMetadataKeys {
+		return fmt.Sprintf("Metadata must have at most %d keys", config.MaxMetadataKeys), false
+	}
+	for key, val := range metadata {
+		if len(strings.TrimSpace(key)) == 0 || len(key) > config.MaxMetadataKeyLen {
+			return fmt.Sprintf("Metadata keys must be non-empty and at most %d characters", config.MaxMetadataKeyLen), false
+		}
+		switch v := val.(type) {
+		case string:
+			if len(v) > config.MaxMetadataValueLen {
+				return fmt.Sprintf("Metadata values must be at most %d characters", config.MaxMetadataValueLen), false
+			}
+		case float64, bool:
+		default:
+			return "Metadata values must be strings, numbers, or booleans", false
+		}
 	}
+	return "", true
+}
 
-	account, err := queries.GetAccount(h.DB, apiKey)
-	if err != nil {
-		log.Printf("Failed to retrieve account for API key %s: %v", apiKey, err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
-		return
+// Build a feedback record from a validated request, deriving thumb_up from the rating if omitted
+func feedbackFromRequest(workspace string, req *models.FeedbackRequest) *models.Feedback {
+	scale := req.Scale
+	if scale == "" {
+		scale = models.ScaleThumbs
 	}
-	if account == nil || account.SlackWorkspace == nil {
-		log.Printf("Invalid API key: %s", apiKey)
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
-		return
+	var thumbUp bool
+	switch {
+	case req.ThumbUp != nil:
+		thumbUp = *req.ThumbUp
+	case scale == models.ScaleCSAT:
+		thumbUp = *req.Rating >= 4
+	case scale == models.ScaleNPS:
+		thumbUp = *req.Rating >= 9
+	}
+	return &models.Feedback{
+		SlackWorkspace: workspace,
+		Prompt:         req.Prompt,
+		ThumbUp:        thumbUp,
+		Comment:        utils.PtrOrNil(req.Comment),
+		Origin:         req.Origin,
+		Category:       req.Category,
+		InProduction:   *req.InProduction,
+		UserID:         req.UserID,
+		Rating:         req.Rating,
+		Scale:          scale,
+		Metadata:       req.Metadata,
 	}
+}
 
-	// Account expiry check
-	if account.AccountExpiryDate.Before(time.Now().UTC()) {
-		log.Printf("Account expired for workspace %s", *account.SlackWorkspace)
-		c.JSON(http.StatusPaymentRequired, models.ErrorResponse{Error: "Account expired"})
+// Handler for POST /feedback
+func (h *FeedbackHandler) PostFeedback(c *gin.Context) {
+	account, ok := h.authorizeAccount(c, models.ScopeWriteFeedback)
+	if !ok {
 		return
 	}
+	h.withIdempotency(c, account, func() { h.submitFeedback(c, account) })
+}
+
+func (h *FeedbackHandler) submitFeedback(c *gin.Context, account *models.Account) {
 	workspace := *account.SlackWorkspace
 
 	// Parse and validate request body
@@ -104,49 +158,210 @@ func (h *FeedbackHandler) PostFeedback(c *gin.Context) {
 		return
 	}
 
-	// Prompt limit check
-	if !h.enforcePromptLimit(workspace, req.Origin, req.Category, req.Prompt) {
-		log.Printf("Prompt limit reached for workspace %s", workspace)
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Prompt limit reached"})
+	feedback := feedbackFromRequest(workspace, &req)
+
+	// Prompt limit and scale check
+	if msg, ok := h.enforcePromptLimit(workspace, req.Origin, req.Category, req.Prompt, feedback.Scale); !ok {
+		log.Printf("Prompt check failed for workspace %s: %s", workspace, msg)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
 		return
 	}
 
 	// Insert prompt
-	if _, err := queries.InsertPrompt(h.DB, workspace, req.Origin, req.Category, req.Prompt); err != nil {
+	if _, err := queries.InsertPrompt(h.DB, workspace, req.Origin, req.Category, req.Prompt, feedback.Scale); err != nil {
 		log.Printf("Failed to insert prompt for workspace %s: %v", workspace, err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
 		return
 	}
 
 	// Insert feedback
-	feedback := models.Feedback{
-		SlackWorkspace: workspace,
-		Prompt:         req.Prompt,
-		ThumbUp:        *req.ThumbUp,
-		Comment:        utils.PtrOrNil(req.Comment),
-		Origin:         req.Origin,
-		Category:       req.Category,
-		InProduction:   *req.InProduction,
-		UserID:         req.UserID,
-	}
-
-	if err := queries.InsertFeedback(h.DB, &feedback); err != nil {
+	if err := queries.InsertFeedback(h.DB, feedback); err != nil {
 		log.Printf("Failed to insert feedback for workspace %s: %v", workspace, err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
 		return
 	}
+	h.Webhooks.Notify()
 
 	log.Printf("Feedback submitted successfully for workspace %s", workspace)
 	c.JSON(http.StatusCreated, gin.H{"message": "Feedback submitted successfully"})
 }
 
-func (h *FeedbackHandler) enforcePromptLimit(workspace, origin, category, prompt string) bool {
-	count, exists, err := queries.GetPromptCountAndExists(h.DB, workspace, origin, category, prompt)
+// Handler for POST /feedback/batch
+func (h *FeedbackHandler) PostFeedbackBatch(c *gin.Context) {
+	account, ok := h.authorizeAccount(c, models.ScopeWriteFeedback)
+	if !ok {
+		return
+	}
+	h.withIdempotency(c, account, func() { h.submitFeedbackBatch(c, account) })
+}
+
+func (h *FeedbackHandler) submitFeedbackBatch(c *gin.Context, account *models.Account) {
+	workspace := *account.SlackWorkspace
+
+	// Parse the request body; items are validated one by one below
+	var reqs []models.FeedbackRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&reqs); err != nil {
+		log.Printf("Invalid batch request body for workspace %s: %v", workspace, err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > config.MaxFeedbackBatchSize {
+		log.Printf("Invalid batch size %d for workspace %s", len(reqs), workspace)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: fmt.Sprintf("Batch must contain between 1 and %d items", config.MaxFeedbackBatchSize),
+		})
+		return
+	}
+
+	// Load existing prompts once, so that prompts new to this batch count towards the limit
+	prompts, err := queries.GetPrompts(h.DB, workspace)
+	if err != nil {
+		log.Printf("Failed to get prompts for workspace %s: %v", workspace, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+	knownPrompts := make(map[models.FeedbackGroup]string, len(prompts)) // prompt -> scale
+	for _, p := range prompts {
+		knownPrompts[models.FeedbackGroup{Origin: p.Origin, Category: p.Category, Prompt: p.Prompt}] = p.Scale
+	}
+
+	results := make([]models.BatchFeedbackResult, len(reqs))
+	var accepted []*models.Feedback
+	var acceptedIdx []int
+	feedbackCount := account.FeedbackCount
+
+	for i := range reqs {
+		req := &reqs[i]
+		results[i].Index = i
+
+		if msg, ok := validateFeedbackRequest(req); !ok {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = msg
+			continue
+		}
+
+		// Monthly feedback limit check
+		if feedbackCount >= h.Config.MonthlyFeedbackLimit {
+			results[i].Status = http.StatusTooManyRequests
+			results[i].Error = "Monthly feedback limit reached"
+			continue
+		}
+
+		// Prompt limit and scale check
+		feedback := feedbackFromRequest(workspace, req)
+		origin, category, prompt := utils.TrimFeedbackFields(req.Origin, req.Category, req.Prompt)
+		key := models.FeedbackGroup{Origin: origin, Category: category, Prompt: prompt}
+		scale, exists := knownPrompts[key]
+		if !exists {
+			if len(knownPrompts) >= h.Config.PromptCountLimit {
+				results[i].Status = http.StatusBadRequest
+				results[i].Error = "Prompt limit reached"
+				continue
+			}
+			knownPrompts[key] = feedback.Scale
+		} else if scale != feedback.Scale {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = fmt.Sprintf("Scale must match the prompt's scale (%s)", scale)
+			continue
+		}
+
+		feedbackCount++
+		accepted = append(accepted, feedback)
+		acceptedIdx = append(acceptedIdx, i)
+	}
+
+	// Insert all accepted items in a single transaction
+	if len(accepted) > 0 {
+		if err := queries.InsertFeedbackBatch(h.DB, workspace, accepted); err != nil {
+			log.Printf("Failed to insert feedback batch for workspace %s: %v", workspace, err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+		h.Webhooks.Notify()
+		for _, i := range acceptedIdx {
+			results[i].Status = http.StatusCreated
+			results[i].Message = "Feedback submitted successfully"
+		}
+	}
+
+	log.Printf("Feedback batch processed for workspace %s: %d/%d items accepted", workspace, len(accepted), len(reqs))
+	c.JSON(http.StatusMultiStatus, gin.H{"results": results})
+}
+
+// Key of the authenticated account in the request context
+const accountContextKey = "account"
+
+// Middleware authenticating the request via X-API-Key, writing the error response on failure.
+// The account and the API key ID are stored in the context, so that the rate limit applies per key.
+func (h *FeedbackHandler) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("X-API-Key")
+		if apiKey == "" {
+			log.Println("Missing X-API-Key header")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+
+		account, err := queries.GetAccount(h.DB, apiKey)
+		if err != nil {
+			log.Printf("Failed to retrieve account for API key %s: %v", utils.RedactApiKey(apiKey), err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+		if account == nil || account.SlackWorkspace == nil {
+			log.Printf("Invalid API key: %s", utils.RedactApiKey(apiKey))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+
+		// Account expiry check
+		if account.AccountExpiryDate.Before(time.Now().UTC()) {
+			log.Printf("Account expired for workspace %s", *account.SlackWorkspace)
+			c.AbortWithStatusJSON(http.StatusPaymentRequired, models.ErrorResponse{Error: "Account expired"})
+			return
+		}
+
+		c.Set(accountContextKey, account)
+		c.Set(utils.APIKeyIDContextKey, account.APIKeyID)
+		c.Next()
+	}
+}
+
+// Authorize the authenticated account with the given scope, writing the error response on failure
+func (h *FeedbackHandler) authorizeAccount(c *gin.Context, scope string) (*models.Account, bool) {
+	value, _ := c.Get(accountContextKey)
+	account, ok := value.(*models.Account)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Unauthorized"})
+		return nil, false
+	}
+
+	// Scope check, where admin grants every scope
+	if !slices.Contains(account.Scopes, scope) && !slices.Contains(account.Scopes, models.ScopeAdmin) {
+		log.Printf("API key %d of workspace %s lacks the %s scope", account.APIKeyID, *account.SlackWorkspace, scope)
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: fmt.Sprintf("API key lacks the %s scope", scope)})
+		return nil, false
+	}
+
+	return account, true
+}
+
+func (h *FeedbackHandler) enforcePromptLimit(workspace, origin, category, prompt, scale string) (string, bool) {
+	count, existingScale, err := queries.GetPromptCountAndScale(h.DB, workspace, origin, category, prompt)
 	if err != nil {
 		log.Printf("Failed to check prompt count/existence for workspace %s: %v", workspace, err)
-		return false
+		return "Prompt limit reached", false
 	}
 
-	// Allow if prompt exists, or if under limit
-	return exists || count < h.Config.PromptCountLimit
+	// Allow if prompt exists with the same scale, or if under limit
+	if existingScale != "" {
+		if existingScale != scale {
+			return fmt.Sprintf("Scale must match the prompt's scale (%s)", existingScale), false
+		}
+		return "", true
+	}
+	if count >= h.Config.PromptCountLimit {
+		return "Prompt limit reached", false
+	}
+	return "", true
 }
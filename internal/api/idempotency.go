@@ -0,0 +1,122 @@
+// File: internal/api/idempotency.go
+
+// This file contains the Idempotency-Key handling for the feedback endpoints.
+// A retried request with the same key returns the stored response instead of inserting feedback twice.
+// The key is bound to the method, path, and body of its first request, and reusing it for another is rejected.
+
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"twothumbs/internal/config"
+	"twothumbs/internal/models"
+	"twothumbs/internal/queries"
+)
+
+// Response writer that keeps a copy of the response body
+type responseCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCapture) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Run the handler at most once per Idempotency-Key and account, replaying the stored response for retries
+func (h *FeedbackHandler) withIdempotency(c *gin.Context, account *models.Account, handle func()) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		handle()
+		return
+	}
+	if len(key) > config.MaxIdempotencyKeyLen {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Idempotency-Key is too long"})
+		return
+	}
+
+	workspace := *account.SlackWorkspace
+	req, err := idempotentRequest(c)
+	if err != nil {
+		log.Printf("Failed to read request body for workspace %s: %v", workspace, err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	reserved, status, body, original, err := queries.ReserveIdempotencyKey(h.DB, account.AccountID, key, req, h.Config.IdempotencyWindow, config.IdempotencyLease)
+	if err != nil {
+		log.Printf("Failed to reserve idempotency key for workspace %s: %v", workspace, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+	if !reserved {
+		// Keys stored before requests were recorded have no hash, and are replayed unchecked
+		if original.BodyHash != "" && original != req {
+			log.Printf("Idempotency key reused for a different request in workspace %s", workspace)
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: "Idempotency-Key was already used for a different request"})
+			return
+		}
+		if body == nil {
+			log.Printf("Request with the same idempotency key is in progress for workspace %s", workspace)
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "A request with this Idempotency-Key is in progress"})
+			return
+		}
+		log.Printf("Replaying stored response for idempotency key in workspace %s", workspace)
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(status, "application/json; charset=utf-8", body)
+		return
+	}
+
+	// Release the key if the handler panics, so that retries do not wait for the lease to expire
+	defer func() {
+		if r := recover(); r != nil {
+			if err := queries.DeleteIdempotencyKey(h.DB, account.AccountID, key); err != nil {
+				log.Printf("Failed to release idempotency key for workspace %s: %v", workspace, err)
+			}
+			panic(r)
+		}
+	}()
+
+	capture := &responseCapture{ResponseWriter: c.Writer}
+	c.Writer = capture
+	handle()
+
+	// Server errors are not stored, so that the client can retry
+	if capture.Status() >= http.StatusInternalServerError {
+		if err := queries.DeleteIdempotencyKey(h.DB, account.AccountID, key); err != nil {
+			log.Printf("Failed to release idempotency key for workspace %s: %v", workspace, err)
+		}
+		return
+	}
+	if err := queries.SaveIdempotentResponse(h.DB, account.AccountID, key, capture.Status(), capture.body.Bytes()); err != nil {
+		log.Printf("Failed to store idempotent response for workspace %s: %v", workspace, err)
+	}
+}
+
+// Describe the request by its method, path, and a hash of its body, restoring the body for the handler
+func idempotentRequest(c *gin.Context) (models.IdempotentRequest, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return models.IdempotentRequest{}, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return models.IdempotentRequest{
+		Method:   c.Request.Method,
+		Path:     c.Request.URL.Path,
+		BodyHash: hex.EncodeToString(sum[:]),
+	}, nil
+}
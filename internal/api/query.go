@@ -0,0 +1,208 @@
+// File: internal/api/query.go
+
+// This file includes the read-only endpoints to query feedback and stats.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"twothumbs/internal/config"
+	"twothumbs/internal/models"
+	"twothumbs/internal/queries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Filters shared by the query endpoints
+type queryFilters struct {
+	from, to                 time.Time // to is exclusive
+	origin, category, prompt string
+	thumb                    string // "Up", "Down", or ""
+	metaKey, metaVal         string
+}
+
+// Handler for GET /feedback
+func (h *FeedbackHandler) GetFeedback(c *gin.Context) {
+	account, ok := h.authorizeAccount(c, models.ScopeReadData)
+	if !ok {
+		return
+	}
+	workspace := *account.SlackWorkspace
+
+	f, msg, ok := parseQueryFilters(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(config.DefaultQueryPageSize)))
+	if err != nil || limit < 1 || limit > config.MaxQueryPageSize {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: fmt.Sprintf("Limit must be between 1 and %d", config.MaxQueryPageSize),
+		})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Offset must be a non-negative integer"})
+		return
+	}
+
+	// Fetch one extra row to tell whether there is a next page
+	rows, err := queries.GetRawFeedbackData(
+		h.DB, workspace, f.from, f.to,
+		f.origin, f.category, f.prompt, f.thumb, f.metaKey, f.metaVal,
+		limit+1, offset,
+	)
+	if err != nil {
+		log.Printf("Failed to get feedback for workspace %s: %v", workspace, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	page := models.FeedbackPage{Data: []models.FeedbackRow{}, Limit: limit, Offset: offset}
+	if len(rows) > limit {
+		next := offset + limit
+		page.NextOffset = &next
+		rows = rows[:limit]
+	}
+	for _, r := range rows {
+		row := models.FeedbackRow{
+			CreatedAt: r.CreatedAt,
+			Origin:    r.Origin,
+			Category:  r.Category,
+			Prompt:    r.Prompt,
+			ThumbUp:   r.ThumbUp,
+			Rating:    r.Rating,
+			Scale:     r.Scale,
+			Comment:   r.Comment,
+			UserID:    r.UserID,
+		}
+		if r.Metadata != nil {
+			row.Metadata = json.RawMessage(*r.Metadata)
+		}
+		page.Data = append(page.Data, row)
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Handler for GET /stats
+func (h *FeedbackHandler) GetStats(c *gin.Context) {
+	account, ok := h.authorizeAccount(c, models.ScopeReadData)
+	if !ok {
+		return
+	}
+	workspace := *account.SlackWorkspace
+
+	f, msg, ok := parseQueryFilters(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: msg})
+		return
+	}
+	groupBy := c.Query("group_by")
+
+	// Compare with the period of the same length right before
+	prevTo := f.from
+	prevFrom := f.from.Add(-f.to.Sub(f.from))
+
+	groups, err := queries.GetFeedbackGroupsWithFilters(
+		h.DB, workspace, f.from, f.to,
+		f.origin, f.category, f.prompt, f.thumb, f.metaKey, f.metaVal, groupBy,
+	)
+	if err != nil {
+		log.Printf("Failed to get feedback groups for workspace %s: %v", workspace, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	resp := models.StatsResponse{
+		From:     f.from.Format("2006-01-02"),
+		To:       f.to.AddDate(0, 0, -1).Format("2006-01-02"),
+		PrevFrom: prevFrom.Format("2006-01-02"),
+		PrevTo:   prevTo.AddDate(0, 0, -1).Format("2006-01-02"),
+		Stats:    []models.GroupStats{},
+	}
+	for _, g := range groups {
+		stats, err := queries.GetFilteredStats(
+			h.DB, workspace, g.Origin, g.Category, g.Prompt,
+			f.from, f.to, prevFrom, prevTo,
+			f.thumb, f.metaKey, f.metaVal, groupBy, g.Dimension,
+		)
+		if err != nil {
+			log.Printf("Failed to get stats for workspace %s, origin %s, category %s, prompt %s: %v",
+				workspace, g.Origin, g.Category, g.Prompt, err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+		resp.Stats = append(resp.Stats, models.GroupStats{
+			Origin:        g.Origin,
+			Category:      g.Category,
+			Prompt:        g.Prompt,
+			Dimension:     g.Dimension,
+			FeedbackStats: stats,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Parse the filters shared by the query endpoints, returning an error message on invalid input
+func parseQueryFilters(c *gin.Context) (*queryFilters, string, bool) {
+	f := &queryFilters{
+		origin:   c.Query("origin"),
+		category: c.Query("category"),
+		prompt:   c.Query("prompt"),
+		metaKey:  c.Query("meta_key"),
+		metaVal:  c.Query("meta_value"),
+	}
+
+	// Date range, both ends inclusive and defaulting to the last 30 days
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, "To must be a date in YYYY-MM-DD format", false
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -config.DefaultQueryRangeDays)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, "From must be a date in YYYY-MM-DD format", false
+		}
+		from = t
+	}
+	if from.After(to) {
+		return nil, "From must not be after to", false
+	}
+	if to.Sub(from) > config.MaxQueryRangeDays*24*time.Hour {
+		return nil, fmt.Sprintf("Date range must be at most %d days", config.MaxQueryRangeDays), false
+	}
+	f.from = from
+	f.to = to.AddDate(0, 0, 1) // adding one day to get data for the full day
+
+	switch strings.ToLower(c.Query("thumb")) {
+	case "":
+	case "up":
+		f.thumb = "Up"
+	case "down":
+		f.thumb = "Down"
+	default:
+		return nil, "Thumb must be up or down", false
+	}
+
+	if f.metaVal != "" && f.metaKey == "" {
+		return nil, "A meta_value requires a meta_key", false
+	}
+
+	return f, "", true
+}
@@ -0,0 +1,148 @@
+// File: internal/api/questions.go
+
+// This file contains the logic to answer questions about feedback asked by @-mentioning the bot or
+// in a direct message. Matching rows from the summaries and feedback tables are handed to the AI API
+// as context, and the answer is posted in a thread.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"twothumbs/internal/integrations"
+	"twothumbs/internal/queries"
+	"twothumbs/internal/utils"
+)
+
+// Time to answer a question, including the AI call and its retries
+const questionTimeout = 60 * time.Second
+
+// Handle an app_mention or direct message event, answering in a thread. Questions are answered by
+// a pool of their own, so that slow AI calls do not hold up the processing of other events.
+func (h *SlackHandler) handleQuestion(workspace string, event map[string]any) {
+	// Ignore messages from bots, including our own replies, and edits or other subtypes
+	if _, isBot := event["bot_id"]; isBot {
+		return
+	}
+	if subtype, _ := event["subtype"].(string); subtype != "" {
+		return
+	}
+	channel, _ := event["channel"].(string)
+	ts, _ := event["ts"].(string)
+	text, _ := event["text"].(string)
+	user, _ := event["user"].(string)
+	if channel == "" || ts == "" {
+		return
+	}
+	threadTS, _ := event["thread_ts"].(string)
+	if threadTS == "" {
+		threadTS = ts
+	}
+	question := utils.StripSlackMentions(text)
+
+	reply := func(answer string) {
+		err := integrations.WithBotToken(h.DB, workspace, func(botToken string) error {
+			return integrations.SendThreadReply(botToken, channel, threadTS, answer)
+		})
+		if err != nil {
+			log.Printf("failed to reply to a question in workspace %s: %v", workspace, err)
+		}
+	}
+	if allowed, retryAfter := h.QuestionLimiter.Allow(workspace + ":" + user); !allowed {
+		log.Printf("question rate limit exceeded by user %s in workspace %s", user, workspace)
+		minutes := int(math.Ceil(retryAfter.Minutes()))
+		reply(fmt.Sprintf("You have asked a lot of questions in a short time. Please try again in %d min.", minutes))
+		return
+	}
+	submitted := h.Questions.Submit(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), questionTimeout)
+		defer cancel()
+		reply(h.answerQuestion(ctx, workspace, question))
+	})
+	if !submitted {
+		log.Printf("question queue is full, turning away a question in workspace %s", workspace)
+		reply("Sorry, I am answering a lot of questions right now. Please try again in a moment.")
+	}
+}
+
+// Answer a question with the feedback of a workspace, returning a message for the user on failure
+func (h *SlackHandler) answerQuestion(ctx context.Context, workspace, question string) string {
+	if question == "" {
+		return "Hi there 👋 Ask me anything about your feedback, e.g. _What are people saying about checkout this week?_"
+	}
+	if h.Config.AI == nil {
+		return "Sorry, answering questions is not enabled for this Two Thumbs instance."
+	}
+
+	active, err := queries.IsActiveAccount(h.DB, workspace)
+	if err != nil {
+		log.Printf("could not check if account is active for workspace %s: %v", workspace, err)
+		return "Something went wrong. Please try again in a moment."
+	}
+	if !active {
+		return "Two Thumbs is not linked to an active account yet. Open the Two Thumbs App Home to get started."
+	}
+
+	// Retrieve the rows matching the question, or the latest ones if nothing matches
+	days := utils.QuestionRangeDays(question)
+	from := time.Now().UTC().AddDate(0, 0, -days)
+	patterns := utils.QuestionPatterns(question)
+	limit := h.Config.AIQuestionInputLimit
+
+	summaries, err := queries.SearchSummaries(h.DB, workspace, from, patterns, limit)
+	if err != nil {
+		log.Printf("failed to search summaries for workspace %s: %v", workspace, err)
+		return "Something went wrong. Please try again in a moment."
+	}
+	comments, err := queries.SearchComments(h.DB, workspace, from, patterns, limit)
+	if err != nil {
+		log.Printf("failed to search comments for workspace %s: %v", workspace, err)
+		return "Something went wrong. Please try again in a moment."
+	}
+	if len(summaries) == 0 && len(comments) == 0 && len(patterns) > 0 {
+		summaries, err = queries.SearchSummaries(h.DB, workspace, from, nil, limit)
+		if err == nil {
+			comments, err = queries.SearchComments(h.DB, workspace, from, nil, limit)
+		}
+		if err != nil {
+			log.Printf("failed to get latest feedback for workspace %s: %v", workspace, err)
+			return "Something went wrong. Please try again in a moment."
+		}
+	}
+	if len(summaries) == 0 && len(comments) == 0 {
+		return fmt.Sprintf("I could not find any feedback comments from the last %d days to answer that.", days)
+	}
+
+	summariesCSV, err := utils.SummariesToCSV(summaries, true)
+	if err != nil {
+		log.Printf("failed to generate summaries CSV for workspace %s: %v", workspace, err)
+		return "Something went wrong. Please try again in a moment."
+	}
+	commentsCSV, err := utils.CommentsToCSV(comments)
+	if err != nil {
+		log.Printf("failed to generate comments CSV for workspace %s: %v", workspace, err)
+		return "Something went wrong. Please try again in a moment."
+	}
+
+	var input strings.Builder
+	fmt.Fprintf(&input, "Question (asked on %s, covering the last %d days):\n%s\n\n", time.Now().UTC().Format("2006-01-02"), days, question)
+	fmt.Fprintf(&input, "Daily summaries:\n%s\n", summariesCSV)
+	fmt.Fprintf(&input, "Comments:\n%s", commentsCSV)
+
+	answer, err := h.Config.AI.Complete(ctx, h.Config.AIQuestionPrompt, input.String())
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("AI question timed out after %s for workspace %s", questionTimeout, workspace)
+		return "Sorry, that took too long to answer. Please try again later, or ask a narrower question."
+	}
+	if err != nil {
+		log.Printf("AI question failed for workspace %s: %v", workspace, err)
+		return "Sorry, I could not come up with an answer right now. Please try again later."
+	}
+	return answer
+}
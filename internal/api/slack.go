@@ -1,44 +1,107 @@
 // File: internal/api/slack.go
 
 // This file provides the Slack integration handler for OAuth callbacks and event handling.
-// It handles the OAuth flow, verifies requests, and processes events like app uninstallation and token revocation.
-// It should also house the logic to handle incoming Slack App commands.
+// It handles the OAuth flow and processes events like app uninstallation and token revocation.
+// Events are deduplicated on their event_id and processed by a bounded worker pool.
+// Slack App commands are handled in commands.go.
 
 package api
 
 import (
 	"crypto/hmac"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"twothumbs/internal/config"
+	"twothumbs/internal/integrations"
 	"twothumbs/internal/interactions"
 	"twothumbs/internal/models"
 	"twothumbs/internal/queries"
 	"twothumbs/internal/templates/home"
+	"twothumbs/internal/utils"
 )
 
 type SlackHandler struct {
-	DB     *sql.DB
-	Config *config.IngestConfig
+	DB              *sql.DB
+	Config          *config.IngestConfig
+	Workers         *utils.WorkerPool  // processes Slack events after they are acknowledged
+	Questions       *utils.WorkerPool  // answers questions to the bot
+	QuestionLimiter *utils.RateLimiter // limits questions per user
 }
 
 func NewSlackHandler(conn *sql.DB, cfg *config.IngestConfig) *SlackHandler {
-	return &SlackHandler{DB: conn, Config: cfg}
+	questionStore := utils.NewRateLimitStore(cfg.RateLimitBackend, conn, cfg.QuestionRateLimitWindow, "questions")
+	return &SlackHandler{
+		DB:        conn,
+		Config:    cfg,
+		Workers:   utils.NewWorkerPool("slack-events", cfg.SlackEventWorkers, cfg.SlackEventQueueSize),
+		Questions: utils.NewWorkerPool("questions", cfg.QuestionWorkers, cfg.QuestionQueueSize),
+		QuestionLimiter: utils.NewRateLimiter(
+			questionStore,
+			"questions",
+			cfg.QuestionRateLimitRequests,
+			cfg.QuestionRateLimitWindow,
+			nil,
+		),
+	}
 }
 
+// Handler starting the install flow, redirecting to Slack with a signed state
+func (h *SlackHandler) Install(c *gin.Context) {
+	state, nonce, err := utils.NewOAuthState(h.Config.OAuthStateSecret, utils.OAuthStateTTL)
+	if err != nil {
+		log.Printf("error creating oauth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start installation"})
+		return
+	}
+
+	// Bind the state to this browser
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(utils.OAuthStateCookie, nonce, int(utils.OAuthStateTTL.Seconds()), "/slack/oauth", "", true, true)
+
+	query := url.Values{}
+	query.Set("client_id", h.Config.SlackAppClientId)
+	query.Set("scope", strings.Join(h.Config.SlackBotScopes, ","))
+	query.Set("redirect_uri", h.Config.SlackOAuthRedirectURI)
+	query.Set("state", state)
+	c.Redirect(http.StatusFound, "https://slack.com/oauth/v2/authorize?"+query.Encode())
+}
+
+// Handler for the OAuth callback of Slack, completing an installation. Callbacks without a valid state
+// and the matching cookie are rejected, so installs must start at Install, which is also the direct
+// install URL to set for the Slack App Directory.
 func (h *SlackHandler) OAuthCallback(c *gin.Context) {
+	// Verify that the callback belongs to an install flow started in this browser
+	nonce, err := utils.VerifyOAuthState(h.Config.OAuthStateSecret, c.Query("state"))
+	if err != nil {
+		log.Printf("rejected oauth callback: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired state, please restart the installation"})
+		return
+	}
+	cookie, err := c.Cookie(utils.OAuthStateCookie)
+	if err != nil || !hmac.Equal([]byte(cookie), []byte(nonce)) {
+		log.Printf("rejected oauth callback: state does not match the browser session")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired state, please restart the installation"})
+		return
+	}
+	c.SetCookie(utils.OAuthStateCookie, "", -1, "/slack/oauth", "", true, true)
+
+	// The user may have cancelled the installation
+	if slackErr := c.Query("error"); slackErr != "" {
+		log.Printf("oauth installation not completed: %s", slackErr)
+		c.Redirect(http.StatusFound, h.Config.SlackAppURI)
+		return
+	}
+
 	code := c.Query("code")
 	if code == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code"})
@@ -59,6 +122,29 @@ func (h *SlackHandler) OAuthCallback(c *gin.Context) {
 	inst := &models.Installation{
 		SlackWorkspace: tokenResp.Team.ID,
 		SlackToken:     tokenResp.AccessToken,
+		Scopes:         strings.Split(tokenResp.Scope, ","),
+		BotUserID:      tokenResp.BotUserID,
+		InstalledBy:    tokenResp.AuthedUser.ID,
+		AppID:          tokenResp.AppID,
+		RefreshToken:   tokenResp.RefreshToken,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+		inst.TokenExpiresAt = &expiresAt
+	}
+	if tokenResp.Enterprise != nil && tokenResp.Enterprise.ID != "" {
+		inst.EnterpriseID = &tokenResp.Enterprise.ID
+	}
+
+	// Org-wide installations of Enterprise Grid are keyed by the enterprise, as the token covers its workspaces
+	if tokenResp.IsEnterpriseInstall {
+		if inst.EnterpriseID == nil {
+			log.Printf("org-wide installation without an enterprise ID")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save installation"})
+			return
+		}
+		inst.SlackWorkspace = *inst.EnterpriseID
+		inst.IsEnterpriseInstall = true
 	}
 
 	err = queries.SaveInstallation(h.DB, inst)
@@ -67,6 +153,24 @@ func (h *SlackHandler) OAuthCallback(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save installation"})
 		return
 	}
+	log.Printf("saved installation for workspace %s, installed by %s", inst.SlackWorkspace, inst.InstalledBy)
+
+	// Record the workspaces of the enterprise, so that their bot token and account links can be resolved
+	if inst.EnterpriseID != nil {
+		workspaces := []string{inst.SlackWorkspace}
+		if inst.IsEnterpriseInstall {
+			// Workspaces missed here are recorded as their events arrive
+			workspaces, err = integrations.ListTeams(inst.SlackToken)
+			if err != nil {
+				log.Printf("error listing workspaces of enterprise %s: %v", *inst.EnterpriseID, err)
+			}
+		}
+		if len(workspaces) > 0 {
+			if err := queries.SaveEnterpriseWorkspaces(h.DB, *inst.EnterpriseID, workspaces); err != nil {
+				log.Printf("error saving workspaces of enterprise %s: %v", *inst.EnterpriseID, err)
+			}
+		}
+	}
 
 	c.Redirect(http.StatusFound, h.Config.SlackAppRedirectURI)
 }
@@ -96,26 +200,13 @@ func exchangeCodeForToken(code, clientID, clientSecret, redirectURI string) (*mo
 	return &oauthResp, nil
 }
 
+// Handler for Slack events, verified by the Slack signature middleware
 func (h *SlackHandler) EventsHandler(c *gin.Context) {
-	signingSecret := h.Config.SlackSigningSecret
-	if signingSecret == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server Misconfiguration"})
-		return
-	}
-
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
 		return
 	}
-	bodyString := string(bodyBytes)
-
-	timestamp := c.GetHeader("X-Slack-Request-Timestamp")
-	slackSignature := c.GetHeader("X-Slack-Signature")
-	if !verifySlackRequest(signingSecret, timestamp, bodyString, slackSignature) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
-		return
-	}
 
 	var payload map[string]any
 	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
@@ -130,85 +221,136 @@ func (h *SlackHandler) EventsHandler(c *gin.Context) {
 	}
 
 	if payload["type"] == "event_callback" {
-		event := payload["event"].(map[string]any)
-		eventType := event["type"].(string)
-
-		switch eventType {
-		case "app_uninstalled":
-			workspace := payload["team_id"].(string)
-			if err := queries.DeleteInstallation(h.DB, workspace); err != nil {
-				log.Printf("error deleting installation for workspace %s: %v", workspace, err)
-			} else {
-				log.Printf("deleted installation for workspace %s", workspace)
+		eventID, _ := payload["event_id"].(string)
+		if eventID != "" {
+			isNew, err := queries.RecordSlackEvent(h.DB, eventID, config.SlackEventDedupWindow)
+			if err != nil {
+				// Fail open, so that a database hiccup does not drop the event
+				log.Printf("error recording Slack event %s: %v", eventID, err)
+			} else if !isNew {
+				// Acknowledge retries and duplicate deliveries without processing them again
+				if retryNum := c.GetHeader("X-Slack-Retry-Num"); retryNum != "" {
+					log.Printf("acknowledged retry %s of Slack event %s (%s)", retryNum, eventID, c.GetHeader("X-Slack-Retry-Reason"))
+				}
+				c.JSON(http.StatusOK, gin.H{"status": "ok"})
+				return
 			}
+		}
 
-		case "tokens_revoked":
-			tokens := event["tokens"].(map[string]any)
-			if botTokens, ok := tokens["bot"].([]any); ok {
-				for _, botToken := range botTokens {
-					tokenStr := botToken.(string)
-					if err := queries.DeleteInstallationByToken(h.DB, tokenStr); err != nil {
-						log.Printf("error deleting revoked bot token installation: %v", err)
-					} else {
-						log.Printf("deleted revoked bot token installation")
-					}
+		if !h.Workers.Submit(func() { h.handleEvent(payload) }) {
+			log.Printf("Slack event queue is full, rejecting event %s", eventID)
+			// Forget the event, so that Slack's retry is processed
+			if eventID != "" {
+				if err := queries.DeleteSlackEvent(h.DB, eventID); err != nil {
+					log.Printf("error deleting record of Slack event %s: %v", eventID, err)
 				}
 			}
-		case "app_home_opened":
-			userID := event["user"].(string)
-			workspace := payload["team_id"].(string)
-			active, err := queries.IsActiveAccount(h.DB, workspace)
-			if err != nil {
-				log.Printf("could not check if account is active for workspace %s: %v", workspace, err)
-				break
-			}
-			botToken, err := queries.GetBotTokenForWorkspace(h.DB, workspace)
-			if err != nil {
-				log.Printf("could not find bot token for workspace %s: %v", workspace, err)
-				break
-			}
-			ctx := &models.InteractionContext{
-				BotToken:  botToken,
-				UserID:    userID,
-				Workspace: workspace,
-			}
-			go func() {
-				if !active {
-					if err := interactions.PublishHomeView(botToken, userID, home.WelcomeBlocks()); err != nil {
-						log.Printf("failed to publish welcome home tab for user %s: %v", userID, err)
-					}
-					return
-				}
-				if err := interactions.HandleTabExplore(ctx, h.DB, false); err != nil {
-					log.Printf("failed to publish home tab for user %s: %v", userID, err)
-				}
-			}()
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many events, try again later"})
+			return
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
-func verifySlackRequest(signingSecret, timestamp, body, slackSignature string) bool {
-	ts, err := strconv.ParseInt(timestamp, 10, 64)
-	if err != nil {
-		return false
+// Process an event_callback payload, run by the event worker pool
+func (h *SlackHandler) handleEvent(payload map[string]any) {
+	event, ok := payload["event"].(map[string]any)
+	if !ok {
+		return
 	}
-	if abs(time.Now().Unix()-ts) > 60*5 {
-		return false
+	eventType, _ := event["type"].(string)
+	workspace, _ := payload["team_id"].(string)
+
+	// Events of org-wide installations tell which workspace of the enterprise they come from
+	enterpriseID, _ := payload["enterprise_id"].(string)
+	isEnterpriseInstall, _ := payload["is_enterprise_install"].(bool)
+	if isEnterpriseInstall && enterpriseID != "" && workspace != "" && eventType != "app_uninstalled" && eventType != "team_access_revoked" {
+		if err := queries.SaveEnterpriseWorkspaces(h.DB, enterpriseID, []string{workspace}); err != nil {
+			log.Printf("error saving workspace %s of enterprise %s: %v", workspace, enterpriseID, err)
+		}
 	}
 
-	basestring := "v0:" + timestamp + ":" + body
-	mac := hmac.New(sha256.New, []byte(signingSecret))
-	mac.Write([]byte(basestring))
-	expectedSig := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	switch eventType {
+	case "app_uninstalled":
+		installation := workspace
+		if isEnterpriseInstall && enterpriseID != "" {
+			installation = enterpriseID
+		}
+		if err := queries.DeleteInstallation(h.DB, installation); err != nil {
+			log.Printf("error deleting installation for workspace %s: %v", installation, err)
+		} else {
+			log.Printf("deleted installation for workspace %s", installation)
+		}
 
-	return hmac.Equal([]byte(expectedSig), []byte(slackSignature))
-}
+	case "team_access_granted", "team_access_revoked":
+		// Workspaces of the enterprise the org-wide installation gained or lost access to
+		var workspaces []string
+		teamIDs, _ := event["team_ids"].([]any)
+		for _, id := range teamIDs {
+			if ws, ok := id.(string); ok {
+				workspaces = append(workspaces, ws)
+			}
+		}
+		if enterpriseID == "" || len(workspaces) == 0 {
+			break
+		}
+		var err error
+		if eventType == "team_access_granted" {
+			err = queries.SaveEnterpriseWorkspaces(h.DB, enterpriseID, workspaces)
+		} else {
+			err = queries.DeleteEnterpriseWorkspaces(h.DB, enterpriseID, workspaces)
+		}
+		if err != nil {
+			log.Printf("error updating workspaces of enterprise %s: %v", enterpriseID, err)
+		} else {
+			log.Printf("%s for %d workspaces of enterprise %s", eventType, len(workspaces), enterpriseID)
+		}
+
+	case "tokens_revoked":
+		tokens, _ := event["tokens"].(map[string]any)
+		if botTokens, ok := tokens["bot"].([]any); ok {
+			for _, botToken := range botTokens {
+				tokenStr, _ := botToken.(string)
+				if err := queries.DeleteInstallationByToken(h.DB, tokenStr); err != nil {
+					log.Printf("error deleting revoked bot token installation: %v", err)
+				} else {
+					log.Printf("deleted revoked bot token installation")
+				}
+			}
+		}
+
+	case "app_mention":
+		h.handleQuestion(workspace, event)
 
-func abs(x int64) int64 {
-	if x < 0 {
-		return -x
+	case "message":
+		// Only direct messages to the bot are questions
+		if channelType, _ := event["channel_type"].(string); channelType == "im" {
+			h.handleQuestion(workspace, event)
+		}
+
+	case "app_home_opened":
+		userID, _ := event["user"].(string)
+		active, err := queries.IsActiveAccount(h.DB, workspace)
+		if err != nil {
+			log.Printf("could not check if account is active for workspace %s: %v", workspace, err)
+			return
+		}
+		if !active {
+			err := integrations.WithBotToken(h.DB, workspace, func(botToken string) error {
+				return interactions.PublishHomeView(botToken, userID, home.WelcomeBlocks())
+			})
+			if err != nil {
+				log.Printf("failed to publish welcome home tab for user %s: %v", userID, err)
+			}
+			return
+		}
+		ctx := &models.InteractionContext{
+			UserID:    userID,
+			Workspace: workspace,
+		}
+		if err := interactions.HandleTabExplore(ctx, h.DB, false); err != nil {
+			log.Printf("failed to publish home tab for user %s: %v", userID, err)
+		}
 	}
-	return x
 }
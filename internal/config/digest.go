@@ -6,22 +6,43 @@ package config
 
 import (
 	"strconv"
+	"time"
+	"twothumbs/internal/integrations"
 	"twothumbs/internal/utils"
 )
 
+const (
+	// Anomaly detection compares yesterday with the days before it
+	AnomalyBaselineDays    = 28
+	AnomalyMinBaselineDays = 7    // days of feedback a prompt needs before it is tested
+	AnomalyMinSamples      = 20   // responses on both sides of a thumbs-up rate test
+	AnomalyMinExpected     = 5    // mean daily responses of the baseline for a volume test
+	AnomalySignificance    = 0.01 // family-wise, over all tests of a workspace
+	AnomalySampleComments  = 5
+)
+
 type DigestConfig struct {
 	DatabaseURL             string
-	AIApiURL                string
-	AIApiKey                string
-	AIModel                 string
-	AICacheInputLimit       int
-	AIDigestInputLimit      int
+	TokenKeyring            *utils.TokenKeyring // master keys for the bot tokens
+	SlackAppClientId        string
+	SlackAppClientSecret    string                 // to refresh rotating bot tokens
+	AIProvider              string                 // "responses", "anthropic", or "chat"
+	AIMaxTokens             int                    // output tokens per request
+	AITimeout               int                    // seconds per attempt
+	AIMaxAttempts           int                    // attempts per call, retrying transient failures
+	AIErrorBudget           int                    // failed calls per run before skipping AI calls, 0 for no limit
+	AI                      *integrations.AIClient // built from the settings above, shared by the whole run
+	AICacheInputLimit       int                    // rows per summary of the cache job
+	AIDigestInputLimit      int                    // rows per summary of the digests
+	AIChunkTokens           int                    // estimated input tokens per AI call
+	AIInputTokenBudget      int                    // estimated input tokens per summary, which caps its cost
 	AIDailyDigestPrompt     string
 	AIWeeklyDigestPrompt    string
 	AIMonthlyDigestPrompt   string
 	AIQuarterlyDigestPrompt string
 	AICommentCachePrompt    string
 	AIIssueCachePrompt      string
+	IdempotencyWindow       int // seconds, after which the cleanup job deletes idempotency keys
 }
 
 func LoadDigestConfig() *DigestConfig {
@@ -35,19 +56,71 @@ func LoadDigestConfig() *DigestConfig {
 	if err != nil || digest_ilim <= 0 {
 		panic("Invalid AI_DIGEST_INPUT_LIMIT: must be a positive integer")
 	}
+	aiMaxTokens, err := strconv.Atoi(utils.GetEnvOrDefault("AI_MAX_TOKENS", "4096"))
+	if err != nil || aiMaxTokens <= 0 {
+		panic("Invalid AI_MAX_TOKENS: must be a positive integer")
+	}
+	aiProvider := utils.GetEnvOrDefault("AI_PROVIDER", integrations.AIProviderResponses)
+	aiApiKey := utils.GetEnvOrDefault("AI_API_KEY", "")
+	if aiApiKey == "" && aiProvider != integrations.AIProviderChat {
+		panic("AI_API_KEY is required unless AI_PROVIDER is chat")
+	}
+	provider, err := integrations.NewAIProvider(aiProvider, utils.GetEnv("AI_API_URL"), aiApiKey, utils.GetEnv("AI_MODEL"), aiMaxTokens)
+	if err != nil {
+		panic("Invalid AI_PROVIDER: " + err.Error())
+	}
+	aiTimeout, err := strconv.Atoi(utils.GetEnvOrDefault("AI_TIMEOUT_SECS", "120"))
+	if err != nil || aiTimeout <= 0 {
+		panic("Invalid AI_TIMEOUT_SECS: must be a positive integer")
+	}
+	aiMaxAttempts, err := strconv.Atoi(utils.GetEnvOrDefault("AI_MAX_ATTEMPTS", "4"))
+	if err != nil || aiMaxAttempts <= 0 {
+		panic("Invalid AI_MAX_ATTEMPTS: must be a positive integer")
+	}
+	aiErrorBudget, err := strconv.Atoi(utils.GetEnvOrDefault("AI_ERROR_BUDGET", "10"))
+	if err != nil || aiErrorBudget < 0 {
+		panic("Invalid AI_ERROR_BUDGET: must be a non-negative integer")
+	}
+	ai := integrations.NewAIClient(provider, time.Duration(aiTimeout)*time.Second, aiMaxAttempts, aiErrorBudget)
+	aiChunkTokens, err := strconv.Atoi(utils.GetEnvOrDefault("AI_CHUNK_TOKENS", "16000"))
+	if err != nil || aiChunkTokens <= 0 {
+		panic("Invalid AI_CHUNK_TOKENS: must be a positive integer")
+	}
+	aiInputTokenBudget, err := strconv.Atoi(utils.GetEnvOrDefault("AI_INPUT_TOKEN_BUDGET", "128000"))
+	if err != nil || aiInputTokenBudget < aiChunkTokens {
+		panic("Invalid AI_INPUT_TOKEN_BUDGET: must be an integer of at least AI_CHUNK_TOKENS")
+	}
+	idempotencyWindow, err := strconv.Atoi(utils.GetEnvOrDefault("IDEMPOTENCY_WINDOW_SECS", "86400"))
+	if err != nil || idempotencyWindow <= 0 {
+		panic("Invalid IDEMPOTENCY_WINDOW_SECS: must be a positive integer")
+	}
+	tokenKeyring, err := utils.ParseTokenKeyring(utils.GetEnv("TOKEN_MASTER_KEYS"))
+	if err != nil {
+		panic("Invalid TOKEN_MASTER_KEYS: " + err.Error())
+	}
+
 	cfg := &DigestConfig{
 		DatabaseURL:             utils.GetEnv("DATABASE_URL"),
-		AIApiURL:                utils.GetEnv("AI_API_URL"),
-		AIApiKey:                utils.GetEnv("AI_API_KEY"),
-		AIModel:                 utils.GetEnv("AI_MODEL"),
+		TokenKeyring:            tokenKeyring,
+		SlackAppClientId:        utils.GetEnv("SLACK_CLIENT_ID"),
+		SlackAppClientSecret:    utils.GetEnv("SLACK_CLIENT_SECRET"),
+		AIProvider:              aiProvider,
+		AIMaxTokens:             aiMaxTokens,
+		AITimeout:               aiTimeout,
+		AIMaxAttempts:           aiMaxAttempts,
+		AIErrorBudget:           aiErrorBudget,
+		AI:                      ai,
 		AICacheInputLimit:       cache_ilim,
 		AIDigestInputLimit:      digest_ilim,
+		AIChunkTokens:           aiChunkTokens,
+		AIInputTokenBudget:      aiInputTokenBudget,
 		AIDailyDigestPrompt:     utils.GetEnv("AI_DAILY_DIGEST_PROMPT"),
 		AIWeeklyDigestPrompt:    utils.GetEnv("AI_WEEKLY_DIGEST_PROMPT"),
 		AIMonthlyDigestPrompt:   utils.GetEnv("AI_MONTHLY_DIGEST_PROMPT"),
 		AIQuarterlyDigestPrompt: utils.GetEnv("AI_QUARTERLY_DIGEST_PROMPT"),
 		AICommentCachePrompt:    utils.GetEnv("AI_COMMENT_CACHE_PROMPT"),
 		AIIssueCachePrompt:      utils.GetEnv("AI_ISSUE_CACHE_PROMPT"),
+		IdempotencyWindow:       idempotencyWindow,
 	}
 
 	return cfg
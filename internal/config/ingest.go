@@ -6,6 +6,9 @@ package config
 
 import (
 	"strconv"
+	"strings"
+	"time"
+	"twothumbs/internal/integrations"
 	"twothumbs/internal/utils"
 )
 
@@ -16,22 +19,77 @@ const (
 	MaxCategoryLen = 32
 	MaxCommentLen  = 256
 	MaxUserIDLen   = 64
+
+	// Feedback metadata limits
+	MaxMetadataKeys     = 20
+	MaxMetadataKeyLen   = 64
+	MaxMetadataValueLen = 256
+
+	// Feedback batch size limit
+	MaxFeedbackBatchSize = 100
+
+	// Idempotency-Key header char limit
+	MaxIdempotencyKeyLen = 255
+	IdempotencyLease     = 60 // seconds before a key whose request did not finish can be reserved again
+
+	// Page size of the feedback query endpoint
+	DefaultQueryPageSize = 100
+	MaxQueryPageSize     = 1000
+
+	// Date range of the query endpoints
+	DefaultQueryRangeDays = 30
+	MaxQueryRangeDays     = 366
+
+	// Comments shown by the /twothumbs comments command
+	MaxCommandComments = 10
+
+	// Live alerts posted per run, and comments shown per alert
+	MaxLiveAlertsPerRun  = 500
+	MaxLiveAlertComments = 10
+	LiveAlertLease       = 300 // seconds before a claimed alert that was not sent is claimed again
+
+	// Window in which Slack event deliveries with the same event_id are ignored
+	SlackEventDedupWindow = 3600 // seconds
 )
 
 type IngestConfig struct {
 	DatabaseURL               string
+	TokenKeyring              *utils.TokenKeyring // master keys for the bot tokens
 	SlackAppURI               string
 	SlackAppClientId          string
 	SlackAppClientSecret      string
 	SlackAppRedirectURI       string
 	SlackOAuthRedirectURI     string
 	SlackSigningSecret        string
-	PromptCountLimit          int // prompts per workspace
-	MonthlyFeedbackLimit      int // requests
-	FeedbackRateLimitRequests int // requests
-	FeedbackRateLimitWindow   int // seconds
+	SlackBotScopes            []string               // requested when installing the app
+	OAuthStateSecret          string                 // signs the state of the install flow
+	PromptCountLimit          int                    // prompts per workspace
+	MonthlyFeedbackLimit      int                    // requests
+	FeedbackRateLimitRequests int                    // requests
+	FeedbackRateLimitWindow   int                    // seconds
+	IPRateLimitRequests       int                    // requests per window and client IP, before authentication, 0 disables the limit
+	RateLimitBackend          string                 // "memory" or "postgres"
+	IdempotencyWindow         int                    // seconds
+	AI                        *integrations.AIClient // nil if questions are disabled
+	AIQuestionPrompt          string
+	AIQuestionInputLimit      int // summary and comment rows each
+	SlackEventWorkers         int // workers processing Slack events
+	SlackEventQueueSize       int // Slack events waiting for a worker
+	QuestionWorkers           int // workers answering questions to the bot
+	QuestionQueueSize         int // questions waiting for a worker
+	QuestionRateLimitRequests int // questions per window and user
+	QuestionRateLimitWindow   int // seconds
+	WebhookMaxAttempts        int // delivery attempts before giving up
+	WebhookAllowPrivateURLs   bool
+	LiveAlertInterval         int // seconds between live alert runs
 }
 
+// Instructions for answering questions to the bot, unless AI_QUESTION_PROMPT is set
+const defaultAIQuestionPrompt = "You answer questions about user feedback collected by Two Thumbs. " +
+	"The input holds the question, daily summaries of comments, and individual comments, both as CSV. " +
+	"Answer concisely, based only on that data, and say so if it does not answer the question. " +
+	"Format the answer as Slack mrkdwn."
+
 func LoadIngestConfig() *IngestConfig {
 	promptCountLimit, err := strconv.Atoi(utils.GetEnv("PROMPT_COUNT_LIMIT"))
 	if err != nil {
@@ -49,19 +107,124 @@ func LoadIngestConfig() *IngestConfig {
 	if err != nil {
 		panic("Invalid RATE_LIMIT_WINDOW_SECS: must be an integer")
 	}
+	ipRateLimitRequests, err := strconv.Atoi(utils.GetEnvOrDefault("RATE_LIMIT_IP_REQUESTS", "1000"))
+	if err != nil || ipRateLimitRequests < 0 {
+		panic("Invalid RATE_LIMIT_IP_REQUESTS: must be a non-negative integer")
+	}
+	rateLimitBackend := utils.GetEnvOrDefault("RATE_LIMIT_BACKEND", "memory")
+	if rateLimitBackend != "memory" && rateLimitBackend != "postgres" {
+		panic("Invalid RATE_LIMIT_BACKEND: must be memory or postgres")
+	}
+	idempotencyWindow, err := strconv.Atoi(utils.GetEnvOrDefault("IDEMPOTENCY_WINDOW_SECS", "86400"))
+	if err != nil || idempotencyWindow <= 0 {
+		panic("Invalid IDEMPOTENCY_WINDOW_SECS: must be a positive integer")
+	}
+
+	aiQuestionInputLimit, err := strconv.Atoi(utils.GetEnvOrDefault("AI_QUESTION_INPUT_LIMIT", "200"))
+	if err != nil || aiQuestionInputLimit <= 0 {
+		panic("Invalid AI_QUESTION_INPUT_LIMIT: must be a positive integer")
+	}
+
+	aiProvider := utils.GetEnvOrDefault("AI_PROVIDER", integrations.AIProviderResponses)
+	aiMaxTokens, err := strconv.Atoi(utils.GetEnvOrDefault("AI_MAX_TOKENS", "4096"))
+	if err != nil || aiMaxTokens <= 0 {
+		panic("Invalid AI_MAX_TOKENS: must be a positive integer")
+	}
+	aiApiURL := utils.GetEnvOrDefault("AI_API_URL", "")
+	aiApiKey := utils.GetEnvOrDefault("AI_API_KEY", "")
+	aiTimeout, err := strconv.Atoi(utils.GetEnvOrDefault("AI_TIMEOUT_SECS", "120"))
+	if err != nil || aiTimeout <= 0 {
+		panic("Invalid AI_TIMEOUT_SECS: must be a positive integer")
+	}
+	aiMaxAttempts, err := strconv.Atoi(utils.GetEnvOrDefault("AI_MAX_ATTEMPTS", "4"))
+	if err != nil || aiMaxAttempts <= 0 {
+		panic("Invalid AI_MAX_ATTEMPTS: must be a positive integer")
+	}
+	var ai *integrations.AIClient
+	if aiApiURL != "" {
+		provider, err := integrations.NewAIProvider(aiProvider, aiApiURL, aiApiKey, utils.GetEnvOrDefault("AI_MODEL", ""), aiMaxTokens)
+		if err != nil {
+			panic("Invalid AI_PROVIDER: " + err.Error())
+		}
+		// The service runs indefinitely, so there is no run to give up on
+		ai = integrations.NewAIClient(provider, time.Duration(aiTimeout)*time.Second, aiMaxAttempts, 0)
+	}
+
+	slackEventWorkers, err := strconv.Atoi(utils.GetEnvOrDefault("SLACK_EVENT_WORKERS", "8"))
+	if err != nil || slackEventWorkers <= 0 {
+		panic("Invalid SLACK_EVENT_WORKERS: must be a positive integer")
+	}
+	slackEventQueueSize, err := strconv.Atoi(utils.GetEnvOrDefault("SLACK_EVENT_QUEUE_SIZE", "100"))
+	if err != nil || slackEventQueueSize < 0 {
+		panic("Invalid SLACK_EVENT_QUEUE_SIZE: must be a non-negative integer")
+	}
+
+	questionWorkers, err := strconv.Atoi(utils.GetEnvOrDefault("QUESTION_WORKERS", "2"))
+	if err != nil || questionWorkers <= 0 {
+		panic("Invalid QUESTION_WORKERS: must be a positive integer")
+	}
+	questionQueueSize, err := strconv.Atoi(utils.GetEnvOrDefault("QUESTION_QUEUE_SIZE", "20"))
+	if err != nil || questionQueueSize < 0 {
+		panic("Invalid QUESTION_QUEUE_SIZE: must be a non-negative integer")
+	}
+	questionRateLimitRequests, err := strconv.Atoi(utils.GetEnvOrDefault("QUESTION_RATE_LIMIT_REQUESTS", "20"))
+	if err != nil || questionRateLimitRequests <= 0 {
+		panic("Invalid QUESTION_RATE_LIMIT_REQUESTS: must be a positive integer")
+	}
+	questionRateLimitWindow, err := strconv.Atoi(utils.GetEnvOrDefault("QUESTION_RATE_LIMIT_WINDOW_SECS", "3600"))
+	if err != nil || questionRateLimitWindow <= 0 {
+		panic("Invalid QUESTION_RATE_LIMIT_WINDOW_SECS: must be a positive integer")
+	}
+
+	webhookMaxAttempts, err := strconv.Atoi(utils.GetEnvOrDefault("WEBHOOK_MAX_ATTEMPTS", "8"))
+	if err != nil || webhookMaxAttempts <= 0 {
+		panic("Invalid WEBHOOK_MAX_ATTEMPTS: must be a positive integer")
+	}
+	webhookAllowPrivateURLs, err := strconv.ParseBool(utils.GetEnvOrDefault("WEBHOOK_ALLOW_PRIVATE_URLS", "false"))
+	if err != nil {
+		panic("Invalid WEBHOOK_ALLOW_PRIVATE_URLS: must be a boolean")
+	}
+
+	liveAlertInterval, err := strconv.Atoi(utils.GetEnvOrDefault("LIVE_ALERT_INTERVAL_SECS", "60"))
+	if err != nil || liveAlertInterval <= 0 {
+		panic("Invalid LIVE_ALERT_INTERVAL_SECS: must be a positive integer")
+	}
+
+	tokenKeyring, err := utils.ParseTokenKeyring(utils.GetEnv("TOKEN_MASTER_KEYS"))
+	if err != nil {
+		panic("Invalid TOKEN_MASTER_KEYS: " + err.Error())
+	}
 
 	cfg := &IngestConfig{
 		DatabaseURL:               utils.GetEnv("DATABASE_URL"),
+		TokenKeyring:              tokenKeyring,
 		SlackAppURI:               utils.GetEnv("SLACK_APP_URI"),
 		SlackAppClientId:          utils.GetEnv("SLACK_CLIENT_ID"),
 		SlackAppClientSecret:      utils.GetEnv("SLACK_CLIENT_SECRET"),
 		SlackAppRedirectURI:       utils.GetEnv("SLACK_APP_REDIRECT_URI"),
 		SlackOAuthRedirectURI:     utils.GetEnv("SLACK_OAUTH_REDIRECT_URI"),
 		SlackSigningSecret:        utils.GetEnv("SLACK_SIGNING_SECRET"),
+		SlackBotScopes:            strings.Split(utils.GetEnvOrDefault("SLACK_BOT_SCOPES", "app_mentions:read,chat:write,commands,files:write,im:history,im:write"), ","),
+		OAuthStateSecret:          utils.GetEnv("OAUTH_STATE_SECRET"),
 		PromptCountLimit:          promptCountLimit,
 		MonthlyFeedbackLimit:      monthlyLimit,
 		FeedbackRateLimitRequests: rateLimitRequests,
 		FeedbackRateLimitWindow:   rateLimitWindow,
+		IPRateLimitRequests:       ipRateLimitRequests,
+		RateLimitBackend:          rateLimitBackend,
+		IdempotencyWindow:         idempotencyWindow,
+		AI:                        ai,
+		AIQuestionPrompt:          utils.GetEnvOrDefault("AI_QUESTION_PROMPT", defaultAIQuestionPrompt),
+		AIQuestionInputLimit:      aiQuestionInputLimit,
+		SlackEventWorkers:         slackEventWorkers,
+		SlackEventQueueSize:       slackEventQueueSize,
+		QuestionWorkers:           questionWorkers,
+		QuestionQueueSize:         questionQueueSize,
+		QuestionRateLimitRequests: questionRateLimitRequests,
+		QuestionRateLimitWindow:   questionRateLimitWindow,
+		WebhookMaxAttempts:        webhookMaxAttempts,
+		WebhookAllowPrivateURLs:   webhookAllowPrivateURLs,
+		LiveAlertInterval:         liveAlertInterval,
 	}
 
 	return cfg
@@ -9,8 +9,22 @@ import (
 	"twothumbs/internal/utils"
 )
 
+const (
+	// API key limits per account
+	MaxAPIKeys       = 10
+	MaxAPIKeyNameLen = 32
+
+	// Webhook limits per account
+	MaxWebhooks      = 5
+	MaxWebhookURLLen = 512
+)
+
 type InteractConfig struct {
 	DatabaseURL          string
+	SlackSigningSecret   string
+	TokenKeyring         *utils.TokenKeyring // master keys for the bot tokens
+	SlackAppClientId     string
+	SlackAppClientSecret string // to refresh rotating bot tokens
 	SMTPHost             string
 	SMTPPort             string
 	SMTPUser             string
@@ -19,6 +33,7 @@ type InteractConfig struct {
 	NComments            int
 	PromptCountLimit     int // prompts per workspace
 	MonthlyFeedbackLimit int // requests
+	APIKeyRotationGrace  int // seconds the old key stays valid after a rotation
 }
 
 func LoadInteractConfig() *InteractConfig {
@@ -34,9 +49,22 @@ func LoadInteractConfig() *InteractConfig {
 	if err != nil {
 		panic("Invalid MONTHLY_FEEDBACK_LIMIT: must be an integer")
 	}
+	apiKeyRotationGrace, err := strconv.Atoi(utils.GetEnvOrDefault("API_KEY_ROTATION_GRACE_SECS", "604800"))
+	if err != nil || apiKeyRotationGrace < 0 {
+		panic("Invalid API_KEY_ROTATION_GRACE_SECS: must be a non-negative integer")
+	}
+
+	tokenKeyring, err := utils.ParseTokenKeyring(utils.GetEnv("TOKEN_MASTER_KEYS"))
+	if err != nil {
+		panic("Invalid TOKEN_MASTER_KEYS: " + err.Error())
+	}
 
 	cfg := &InteractConfig{
 		DatabaseURL:          utils.GetEnv("DATABASE_URL"),
+		SlackSigningSecret:   utils.GetEnv("SLACK_SIGNING_SECRET"),
+		TokenKeyring:         tokenKeyring,
+		SlackAppClientId:     utils.GetEnv("SLACK_CLIENT_ID"),
+		SlackAppClientSecret: utils.GetEnv("SLACK_CLIENT_SECRET"),
 		SMTPHost:             utils.GetEnv("SMTP_HOST"),
 		SMTPPort:             utils.GetEnv("SMTP_PORT"),
 		SMTPUser:             utils.GetEnv("SMTP_USERNAME"),
@@ -45,6 +73,7 @@ func LoadInteractConfig() *InteractConfig {
 		NComments:            nComments,
 		PromptCountLimit:     promptCountLimit,
 		MonthlyFeedbackLimit: monthlyLimit,
+		APIKeyRotationGrace:  apiKeyRotationGrace,
 	}
 
 	return cfg
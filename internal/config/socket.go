@@ -0,0 +1,24 @@
+// File: internal/config/socket.go
+
+// This file contains the configuration for the Socket Mode service.
+// The service also loads the ingest and interact configurations, whose handlers it runs.
+
+package config
+
+import (
+	"twothumbs/internal/utils"
+)
+
+type SocketConfig struct {
+	SlackAppToken      string // app-level token with the connections:write scope
+	SlackSocketOpenURL string // apps.connections.open, or a local stand-in for testing
+}
+
+func LoadSocketConfig() *SocketConfig {
+	cfg := &SocketConfig{
+		SlackAppToken:      utils.GetEnv("SLACK_APP_TOKEN"),
+		SlackSocketOpenURL: utils.GetEnvOrDefault("SLACK_SOCKET_OPEN_URL", "https://slack.com/api/apps.connections.open"),
+	}
+
+	return cfg
+}
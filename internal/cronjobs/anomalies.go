@@ -0,0 +1,160 @@
+// File: internal/cronjobs/anomalies.go
+
+// This file contains the anomaly detection job. Each prompt's thumbs-up rate and volume of
+// yesterday are tested against its own baseline of the days before, and significant shifts are
+// posted to the output channel of the workspace along with sample comments behind them.
+//
+// The thumbs-up rate is compared with a two-proportion z-test, and the volume with a z-test of an
+// overdispersed Poisson count, whose variance is estimated from the daily counts of the baseline.
+// As every prompt is tested daily, the significance level is split over all tests of a workspace
+// (Bonferroni correction), to keep false alarms rare.
+
+package cronjobs
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+
+	"twothumbs/internal/config"
+	"twothumbs/internal/integrations"
+	"twothumbs/internal/models"
+	"twothumbs/internal/queries"
+	"twothumbs/internal/templates/digests"
+	"twothumbs/internal/utils"
+)
+
+func RunAnomalyDetection(conn *sql.DB) error {
+	workspaces, err := queries.GetActiveWorkspacesAndChannels(conn, models.Daily)
+	if err != nil {
+		return fmt.Errorf("failed to get active workspaces: %w", err)
+	}
+
+	log.Printf("Detecting anomalies for %d workspaces", len(workspaces))
+
+	failed := 0
+	for _, ws := range workspaces {
+		anomalies, err := detectAnomalies(conn, ws.Workspace)
+		if err != nil {
+			log.Printf("failed to detect anomalies for workspace %s: %v", ws.Workspace, err)
+			failed++
+			continue
+		}
+		if len(anomalies) == 0 {
+			continue
+		}
+
+		blocks := digests.BuildAnomalyAlertBlocks(anomalies)
+		err = integrations.WithBotToken(conn, ws.Workspace, func(botToken string) error {
+			return integrations.SendBlockKitMessage(botToken, ws.Channel, blocks)
+		})
+		if err != nil {
+			log.Printf("failed to send anomaly alert to workspace %s: %v", ws.Workspace, err)
+			failed++
+			continue
+		}
+		log.Printf("Sent anomaly alert with %d prompts to workspace %s, channel %s", len(anomalies), ws.Workspace, ws.Channel)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to detect or send the anomaly alerts of %d of %d workspaces", failed, len(workspaces))
+	}
+	return nil
+}
+
+// Test each prompt of a workspace, returning those with a significant shift
+func detectAnomalies(conn *sql.DB, workspace string) ([]models.Anomaly, error) {
+	counts, err := queries.GetPromptDailyCounts(conn, workspace, config.AnomalyBaselineDays+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily counts: %w", err)
+	}
+
+	// Collect the counts of each prompt by day, where day 1 is yesterday
+	byGroup := make(map[models.FeedbackGroup]map[int]models.PromptDailyCount)
+	for _, c := range counts {
+		if byGroup[c.Group] == nil {
+			byGroup[c.Group] = make(map[int]models.PromptDailyCount)
+		}
+		byGroup[c.Group][c.DaysAgo] = c
+	}
+
+	// Run the tests, counting them for the correction
+	var candidates []models.Anomaly
+	nTests := 0
+	for group, days := range byGroup {
+		a, tests := testPrompt(group, days)
+		if tests == 0 {
+			continue
+		}
+		nTests += tests
+		candidates = append(candidates, a)
+	}
+
+	alpha := config.AnomalySignificance / float64(max(nTests, 1))
+	var anomalies []models.Anomaly
+	for _, a := range candidates {
+		a.RateShift = a.RateShift && a.RateP < alpha
+		a.VolumeShift = a.VolumeShift && a.VolumeP < alpha
+		if !a.RateShift && !a.VolumeShift {
+			continue
+		}
+
+		// Show the comments behind the shift: thumbs-down ones for a drop, thumbs-up ones for a rise
+		var thumbUp *bool
+		if a.RateShift {
+			up := a.RateZ > 0
+			thumbUp = &up
+		}
+		a.Comments, err = queries.GetYesterdaysComments(conn, workspace, a.Group, thumbUp, config.AnomalySampleComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get comments: %w", err)
+		}
+		anomalies = append(anomalies, a)
+	}
+
+	// Strongest shifts first
+	sort.Slice(anomalies, func(i, j int) bool {
+		return min(anomalies[i].RateP, anomalies[i].VolumeP) < min(anomalies[j].RateP, anomalies[j].VolumeP)
+	})
+	return anomalies, nil
+}
+
+// Test the thumbs-up rate and volume of a prompt's yesterday against its baseline, returning the
+// results (RateShift and VolumeShift mark the tests that were run) and the number of tests
+func testPrompt(group models.FeedbackGroup, days map[int]models.PromptDailyCount) (models.Anomaly, int) {
+	a := models.Anomaly{Group: group, RateP: 1, VolumeP: 1}
+
+	// The baseline starts on the first day with feedback, so that new prompts are not compared
+	// with the days before they existed
+	first := 0
+	for d := range days {
+		first = max(first, d)
+	}
+	a.BaselineDays = first - 1
+	if a.BaselineDays < config.AnomalyMinBaselineDays {
+		return a, 0
+	}
+
+	a.RecentN, a.RecentUp = days[1].N, days[1].NUp
+	daily := make([]int, a.BaselineDays)
+	for d := 2; d <= first; d++ {
+		daily[d-2] = days[d].N
+		a.BaselineN += days[d].N
+		a.BaselineUp += days[d].NUp
+	}
+
+	tests := 0
+	if a.RecentN >= config.AnomalyMinSamples && a.BaselineN >= config.AnomalyMinSamples {
+		a.RateZ, a.RateP = utils.TwoProportionZTest(a.RecentUp, a.RecentN, a.BaselineUp, a.BaselineN)
+		a.RateShift = true
+		tests++
+	}
+	expected, z, p := utils.CountZTest(a.RecentN, daily)
+	if expected >= config.AnomalyMinExpected {
+		a.ExpectedN, a.VolumeZ, a.VolumeP = expected, z, p
+		a.VolumeShift = true
+		tests++
+	}
+	return a, tests
+}
@@ -5,9 +5,11 @@
 package cronjobs
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
-	"math/rand"
 
 	"twothumbs/internal/config"
 	"twothumbs/internal/integrations"
@@ -16,7 +18,9 @@ import (
 	"twothumbs/internal/utils"
 )
 
-func RunDailyCacheJob(conn *sql.DB, cfg *config.DigestConfig) error {
+// Cache the daily summaries and issue reports of each active workspace. A failing feedback group or
+// workspace is skipped, and the job stops early only if the run is cancelled or the AI error budget is spent.
+func RunDailyCacheJob(ctx context.Context, conn *sql.DB, cfg *config.DigestConfig) error {
 	// Truncate the issues table to keep things tidy
 	if err := queries.TruncateIssuesTable(conn); err != nil {
 		log.Printf("Failed to truncate issues table: %v", err)
@@ -28,16 +32,28 @@ func RunDailyCacheJob(conn *sql.DB, cfg *config.DigestConfig) error {
 		log.Printf("Failed to get active workspaces: %v", err)
 		return err
 	}
+	failed := 0
 	for _, workspace := range workspaces {
-		if err := processWorkspace(conn, cfg, workspace); err != nil {
+		if err := processWorkspace(ctx, conn, cfg, workspace); err != nil {
 			log.Printf("Error processing workspace %s: %v", workspace, err)
-			return err
+			if isFatalAIError(ctx, err) {
+				return err
+			}
+			failed++
 		}
 	}
+	if failed > 0 {
+		return fmt.Errorf("failed to process %d of %d workspaces", failed, len(workspaces))
+	}
 	return nil
 }
 
-func processWorkspace(conn *sql.DB, cfg *config.DigestConfig, workspace string) error {
+// Whether an error should stop the run, rather than just the feedback group it occurred in
+func isFatalAIError(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, integrations.ErrAIErrorBudgetExhausted)
+}
+
+func processWorkspace(ctx context.Context, conn *sql.DB, cfg *config.DigestConfig, workspace string) error {
 	log.Printf("Processing workspace: %s", workspace)
 	feedbacks, err := queries.GetCommentsForCaching(conn, workspace)
 	if err != nil {
@@ -49,18 +65,25 @@ func processWorkspace(conn *sql.DB, cfg *config.DigestConfig, workspace string)
 	groups := GroupFeedback(feedbacks)
 	log.Printf("Formed %d feedback groups for workspace %s", len(groups), workspace)
 
+	failed := 0
 	for key, group := range groups {
-		if err := processFeedbackGroup(conn, cfg, workspace, key, group); err != nil {
+		if err := processFeedbackGroup(ctx, conn, cfg, workspace, key, group); err != nil {
 			log.Printf("Error processing feedback group for workspace %s (prompt=%q, origin=%q, category=%q): %v", workspace, key.Prompt, key.Origin, key.Category, err)
-			return err
+			if isFatalAIError(ctx, err) {
+				return err
+			}
+			failed++
 		}
 	}
 
-	if err := prepareIssueReports(conn, cfg, workspace); err != nil {
+	if err := prepareIssueReports(ctx, conn, cfg, workspace); err != nil {
 		log.Printf("Error preparing issue reports for workspace %s: %v", workspace, err)
 		return err
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("failed to process %d of %d feedback groups", failed, len(groups))
+	}
 	return nil
 }
 
@@ -73,36 +96,21 @@ func GroupFeedback(feedbacks []*models.Feedback) map[models.FeedbackGroup][]*mod
 	return groups
 }
 
-func processFeedbackGroup(conn *sql.DB, cfg *config.DigestConfig, workspace string, key models.FeedbackGroup, group []*models.Feedback) error {
-	maxRows := cfg.AICacheInputLimit
-	originalLen := len(group)
-	if originalLen > maxRows {
-		log.Printf("Encountered %d feedback rows (while the limit was %d) for workspace %s", originalLen, maxRows, workspace)
-		rand.Shuffle(originalLen, func(i, j int) {
-			group[i], group[j] = group[j], group[i]
-		})
-		group = group[:maxRows]
-	}
-
-	csvData, err := utils.FeedbackToCSV(group)
-	if err != nil {
-		log.Printf("Failed to encode feedback to CSV for workspace %s: %v", workspace, err)
-		return err
-	}
-
-	summary, err := integrations.GetAISummary(
-		cfg.AIApiURL,
-		cfg.AIApiKey,
-		cfg.AIModel,
-		cfg.AICommentCachePrompt,
-		csvData,
-	)
+func processFeedbackGroup(ctx context.Context, conn *sql.DB, cfg *config.DigestConfig, workspace string, key models.FeedbackGroup, group []*models.Feedback) error {
+	// Comments are summarized in chunks, most recent first, up to the input limits
+	summary, coverage, err := integrations.SummarizeRows(ctx, cfg.AI, cfg.AICommentCachePrompt, group, utils.FeedbackToCSV,
+		cfg.AICacheInputLimit, cfg.AIChunkTokens, cfg.AIInputTokenBudget)
 	if err != nil {
 		log.Printf("AI summary failed for workspace %s (prompt=%q, origin=%q, category=%q): %v", workspace, key.Prompt, key.Origin, key.Category, err)
 		return err
 	}
+	if coverage.IncludedRows < coverage.Rows {
+		log.Printf("Summarized %d of %d comments in %d chunks (the limits are %d rows and %d tokens) for workspace %s (prompt=%q, origin=%q, category=%q)", coverage.IncludedRows, coverage.Rows, coverage.Chunks, cfg.AICacheInputLimit, cfg.AIInputTokenBudget, workspace, key.Prompt, key.Origin, key.Category)
+	} else if coverage.Chunks > 1 {
+		log.Printf("Summarized %d comments in %d chunks for workspace %s (prompt=%q, origin=%q, category=%q)", coverage.Rows, coverage.Chunks, workspace, key.Prompt, key.Origin, key.Category)
+	}
 
-	if err := queries.InsertSummary(conn, workspace, key.Prompt, key.Origin, key.Category, len(group), summary); err != nil {
+	if err := queries.InsertSummary(conn, workspace, key.Prompt, key.Origin, key.Category, len(group), coverage.IncludedRows, summary); err != nil {
 		log.Printf("Failed to insert summary for workspace %s (prompt=%q, origin=%q, category=%q): %v", workspace, key.Prompt, key.Origin, key.Category, err)
 		return err
 	}
@@ -111,7 +119,7 @@ func processFeedbackGroup(conn *sql.DB, cfg *config.DigestConfig, workspace stri
 	return nil
 }
 
-func prepareIssueReports(conn *sql.DB, cfg *config.DigestConfig, workspace string) error {
+func prepareIssueReports(ctx context.Context, conn *sql.DB, cfg *config.DigestConfig, workspace string) error {
 	// Fetch distinct origins from the summaries table
 	origins, err := queries.GetDistinctOrigins(conn, workspace)
 	if err != nil {
@@ -129,35 +137,30 @@ func prepareIssueReports(conn *sql.DB, cfg *config.DigestConfig, workspace strin
 			return err
 		}
 
-		if len(summaries) > cfg.AICacheInputLimit {
-			log.Printf("Encountered %d summary rows (while the limit is %d) for workspace %s and origin %s", len(summaries), cfg.AICacheInputLimit, workspace, origin)
-			summaries = summaries[:cfg.AICacheInputLimit]
-		}
-
 		// Convert []*models.SummaryRow to []models.SummaryRow
 		summaryValues := make([]models.SummaryRow, len(summaries))
 		for i, summary := range summaries {
 			summaryValues[i] = *summary
 		}
 
-		// Convert summaries to CSV
-		csvData, err := utils.SummariesToCSV(summaryValues, true)
-		if err != nil {
-			log.Printf("Failed to convert summaries to CSV for workspace %s and origin %s: %v", workspace, origin, err)
-			return err
+		// Summaries are sent to AI in chunks, most recent first, up to the input limits
+		toCSV := func(rows []models.SummaryRow) (string, error) {
+			return utils.SummariesToCSV(rows, true)
 		}
-
-		// Send CSV data to AI for issue report generation
-		aiReport, err := integrations.GetAISummary(
-			cfg.AIApiURL,
-			cfg.AIApiKey,
-			cfg.AIModel,
-			cfg.AIIssueCachePrompt,
-			csvData,
-		)
+		aiReport, coverage, err := integrations.SummarizeRows(ctx, cfg.AI, cfg.AIIssueCachePrompt, summaryValues, toCSV,
+			cfg.AICacheInputLimit, cfg.AIChunkTokens, cfg.AIInputTokenBudget)
 		if err != nil {
 			log.Printf("AI issue report generation failed for workspace %s and origin %s: %v", workspace, origin, err)
-			return err
+			if isFatalAIError(ctx, err) {
+				return err
+			}
+			// Keep the reports of the other origins
+			continue
+		}
+		if coverage.IncludedRows < coverage.Rows {
+			log.Printf("Reported on %d of %d summary rows in %d chunks (the limits are %d rows and %d tokens) for workspace %s and origin %s", coverage.IncludedRows, coverage.Rows, coverage.Chunks, cfg.AICacheInputLimit, cfg.AIInputTokenBudget, workspace, origin)
+		} else if coverage.Chunks > 1 {
+			log.Printf("Reported on %d summary rows in %d chunks for workspace %s and origin %s", coverage.Rows, coverage.Chunks, workspace, origin)
 		}
 
 		// Skip insertion if the AI report is empty
@@ -7,9 +7,11 @@ package cronjobs
 import (
 	"database/sql"
 	"log"
+
+	"twothumbs/internal/config"
 )
 
-func RunCleanup(conn *sql.DB) error {
+func RunCleanup(conn *sql.DB, cfg *config.DigestConfig) error {
 	// Reset feedback_count values to zero
 	if _, err := conn.Exec(`UPDATE accounts SET feedback_count = 0`); err != nil {
 		log.Printf("Failed to reset feedback_count: %v", err)
@@ -32,8 +34,53 @@ func RunCleanup(conn *sql.DB) error {
 	m, _ := ress.RowsAffected()
 	log.Printf("Deleted %d rows of old cache data.", m)
 
+	// Delete idempotency keys whose window has passed, as the ingest service would no longer replay them
+	resi, err := conn.Exec(`DELETE FROM idempotency_keys WHERE created_at < (NOW() - make_interval(secs => $1))`, cfg.IdempotencyWindow)
+	if err != nil {
+		log.Printf("Failed to delete old idempotency keys: %v", err)
+		return err
+	}
+	k, _ := resi.RowsAffected()
+	log.Printf("Deleted %d old idempotency keys.", k)
+
+	// Delete Slack event records older than one day
+	rese, err := conn.Exec(`DELETE FROM slack_events WHERE received_at < (NOW() - INTERVAL '1 day')`)
+	if err != nil {
+		log.Printf("Failed to delete old Slack event records: %v", err)
+		return err
+	}
+	e, _ := rese.RowsAffected()
+	log.Printf("Deleted %d old Slack event records.", e)
+
+	// Delete webhook deliveries older than one month
+	resw, err := conn.Exec(`DELETE FROM webhook_deliveries WHERE created_at < (NOW() - INTERVAL '1 month')`)
+	if err != nil {
+		log.Printf("Failed to delete old webhook deliveries: %v", err)
+		return err
+	}
+	w, _ := resw.RowsAffected()
+	log.Printf("Deleted %d old webhook deliveries.", w)
+
+	// Delete API keys revoked or expired more than one month ago
+	resk, err := conn.Exec(`
+        DELETE FROM api_keys
+        WHERE revoked_at < (NOW() - INTERVAL '1 month')
+           OR expires_at < (NOW() - INTERVAL '1 month')
+    `)
+	if err != nil {
+		log.Printf("Failed to delete old API keys: %v", err)
+		return err
+	}
+	l, _ := resk.RowsAffected()
+	log.Printf("Deleted %d old API keys.", l)
+
 	// Delete expired accounts and their data
-	rows, err := conn.Query(`SELECT slack_workspace FROM accounts WHERE DATE(acccount_expiry_date) < (CURRENT_DATE - INTERVAL '1 month')`)
+	rows, err := conn.Query(`
+        SELECT w.slack_workspace
+        FROM account_workspaces w
+        JOIN accounts a ON a.account_id = w.account_id
+        WHERE DATE(a.account_expiry_date) < (CURRENT_DATE - INTERVAL '1 month')
+    `)
 	if err != nil {
 		log.Printf("Failed to get expired accounts: %v", err)
 		return err
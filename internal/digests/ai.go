@@ -0,0 +1,56 @@
+// File: internal/digests/ai.go
+
+// This file contains the logic shared by the digests to summarize feedback with the AI API.
+
+package digests
+
+import (
+	"context"
+	"log"
+
+	"twothumbs/internal/config"
+	"twothumbs/internal/integrations"
+	"twothumbs/internal/models"
+	"twothumbs/internal/utils"
+)
+
+// Shown instead of a summary that could not be generated, so that the rest of the digest is still sent
+const summaryUnavailable = "_The summary is unavailable, as the AI API could not be reached._"
+
+// Summarize daily summaries with the given instructions, most recent first, falling back to a placeholder
+// if the AI call fails. Rows beyond the input limits are left out, as reported by the coverage, along with
+// the comments behind the rows. The group describes the summarized feedback in the logs.
+func summarize(
+	ctx context.Context,
+	cfg *config.DigestConfig,
+	instructions string,
+	summaries []models.SummaryRow,
+	withDates bool,
+	group string,
+) (string, models.InputCoverage) {
+	toCSV := func(rows []models.SummaryRow) (string, error) {
+		return utils.SummariesToCSV(rows, withDates)
+	}
+	digest, coverage, err := integrations.SummarizeRows(ctx, cfg.AI, instructions, summaries, toCSV,
+		cfg.AIDigestInputLimit, cfg.AIChunkTokens, cfg.AIInputTokenBudget)
+	if err != nil {
+		log.Printf("AI job failed for %s, sending the digest without its summary: %v", group, err)
+		coverage = models.InputCoverage{Rows: len(summaries), Failed: true}
+	}
+	for i, s := range summaries {
+		coverage.Comments += s.NComments
+		if i < coverage.IncludedRows {
+			coverage.IncludedComments += s.NCommentsSummarized
+		}
+	}
+	if coverage.Failed {
+		return summaryUnavailable, coverage
+	}
+
+	if coverage.IncludedRows < coverage.Rows {
+		log.Printf("Summarized %d of %d summary rows in %d chunks (the limits are %d rows and %d tokens) for %s", coverage.IncludedRows, coverage.Rows, coverage.Chunks, cfg.AIDigestInputLimit, cfg.AIInputTokenBudget, group)
+	} else if coverage.Chunks > 1 {
+		log.Printf("Summarized %d summary rows in %d chunks for %s", coverage.Rows, coverage.Chunks, group)
+	}
+	return digest, coverage
+}
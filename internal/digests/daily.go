@@ -5,10 +5,10 @@
 package digests
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
-	"math/rand"
 	"sort"
 
 	"twothumbs/internal/config"
@@ -16,10 +16,10 @@ import (
 	"twothumbs/internal/models"
 	"twothumbs/internal/queries"
 	"twothumbs/internal/templates/digests"
-	"twothumbs/internal/utils"
 )
 
 func SendDailyDigests(
+	ctx context.Context,
 	conn *sql.DB,
 	cfg *config.DigestConfig,
 ) error {
@@ -32,17 +32,23 @@ func SendDailyDigests(
 
 	var messages []models.DigestMessage
 
+	failed := 0
 	for _, ws := range workspaces {
-		blocks, botToken, err := processDailyDigest(conn, ws, cfg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		blocks, err := processDailyDigest(ctx, conn, ws, cfg)
 		if err != nil {
-			return fmt.Errorf("failed to process daily digest for workspace %s: %v", ws.Workspace, err)
+			// Carry on with the other workspaces
+			log.Printf("failed to process daily digest for workspace %s: %v", ws.Workspace, err)
+			failed++
+			continue
 		}
 		if len(blocks) == 0 {
 			log.Printf("No digest data for workspace %s", ws.Workspace)
 			continue
 		}
 		messages = append(messages, models.DigestMessage{
-			BotToken:  botToken,
 			Channel:   ws.Channel,
 			Blocks:    blocks,
 			Workspace: ws.Workspace,
@@ -51,49 +57,54 @@ func SendDailyDigests(
 
 	// Send messages
 	for _, msg := range messages {
-		err := integrations.SendBlockKitMessage(msg.BotToken, msg.Channel, msg.Blocks)
+		// Fetch the token only now, as it may have expired while the digests were prepared
+		err := integrations.WithBotToken(conn, msg.Workspace, func(botToken string) error {
+			return integrations.SendBlockKitMessage(botToken, msg.Channel, msg.Blocks)
+		})
 		if err != nil {
 			log.Printf("failed to send daily digest to workspace %s: %v", msg.Workspace, err)
+			failed++
 			continue
 		} else {
 			log.Printf("Sent daily digest to workspace %s, channel %s", msg.Workspace, msg.Channel)
 		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("failed to process or send the daily digests of %d of %d workspaces", failed, len(workspaces))
+	}
 	return nil
 }
 
 func processDailyDigest(
+	ctx context.Context,
 	conn *sql.DB,
 	ws models.WorkspaceChannel,
 	cfg *config.DigestConfig,
-) ([]map[string]any, string, error) {
+) ([]map[string]any, error) {
 	summaries, err := queries.GetSummariesByWorkspace(conn, ws.Workspace, models.Daily)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get summaries: %w", err)
+		return nil, fmt.Errorf("failed to get summaries: %w", err)
 	}
 	if len(summaries) == 0 {
 		log.Printf("No summary data for workspace %s", ws.Workspace)
 	}
 
-	digestBlocks, err := prepareDailyDigestData(cfg, summaries, ws.Workspace)
+	digestBlocks, err := prepareDailyDigestData(ctx, cfg, summaries, ws.Workspace)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	if len(digestBlocks) == 0 {
 		log.Printf("No digest data for workspace %s", ws.Workspace)
-		return nil, "", nil
+		return nil, nil
 	}
 
 	blocks := digests.BuildDailyDigestBlocks(digestBlocks)
-	botToken, err := queries.GetBotTokenForWorkspace(conn, ws.Workspace)
-	if err != nil {
-		return nil, "", fmt.Errorf("no bot token found for workspace %s: %w", ws.Workspace, err)
-	}
-	return blocks, botToken, nil
+	return blocks, nil
 }
 
 func prepareDailyDigestData(
+	ctx context.Context,
 	cfg *config.DigestConfig,
 	summaries []models.SummaryRow,
 	workspace string,
@@ -105,27 +116,9 @@ func prepareDailyDigestData(
 	}
 
 	var digestBlocks []models.DailyDigestData
-	maxRows := cfg.AIDigestInputLimit
 
 	for origin, group := range originMap {
-		originalLen := len(group)
-		if originalLen > maxRows {
-			log.Printf("Encountered %d summary rows (while the limit is %d) for workspace %s", originalLen, maxRows, workspace)
-			rand.Shuffle(originalLen, func(i, j int) {
-				group[i], group[j] = group[j], group[i]
-			})
-			group = group[:maxRows]
-		}
-
-		csvData, err := utils.SummariesToCSV(group, false)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate CSV for workspace %s, origin %s: %w", workspace, origin, err)
-		}
-
-		digest, err := integrations.GetAISummary(cfg.AIApiURL, cfg.AIApiKey, cfg.AIModel, cfg.AIDailyDigestPrompt, csvData)
-		if err != nil {
-			return nil, fmt.Errorf("AI job failed for workspace %s, origin %s: %w", workspace, origin, err)
-		}
+		digest, coverage := summarize(ctx, cfg, cfg.AIDailyDigestPrompt, group, false, fmt.Sprintf("workspace %s, origin %s", workspace, origin))
 
 		nComments := 0
 		for _, s := range group {
@@ -135,6 +128,7 @@ func prepareDailyDigestData(
 			Origin:    origin,
 			NComments: nComments,
 			Digest:    digest,
+			Coverage:  coverage,
 		})
 	}
 
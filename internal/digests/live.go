@@ -0,0 +1,102 @@
+// File: internal/digests/live.go
+
+// This file contains the logic to send live alerts. New thumbs-down comments on live prompts are
+// claimed every interval and posted to the output channel of their workspace, one message per
+// origin, so that a burst of complaints does not flood the channel. The comments of a message are
+// marked as alerted once it is sent, and comments of messages that fail are claimed again by a
+// later run once their lease ends.
+
+package digests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"twothumbs/internal/config"
+	"twothumbs/internal/integrations"
+	"twothumbs/internal/models"
+	"twothumbs/internal/queries"
+	"twothumbs/internal/templates/digests"
+)
+
+// Send live alerts every interval until the context is done
+func RunLiveAlerts(ctx context.Context, conn *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := SendLiveAlerts(conn); err != nil {
+				log.Printf("Failed to send live alerts: %v", err)
+			}
+		}
+	}
+}
+
+// Post the pending thumbs-down comments on live prompts, batched per workspace and origin
+func SendLiveAlerts(conn *sql.DB) error {
+	alerts, err := queries.ClaimLiveAlerts(conn, config.MaxLiveAlertsPerRun, config.LiveAlertLease)
+	if err != nil {
+		return fmt.Errorf("failed to claim live alerts: %w", err)
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	// Group the alerts per workspace and origin
+	type alertGroup struct {
+		Workspace string
+		Channel   string
+		Origin    string
+	}
+	groups := make(map[alertGroup][]models.LiveAlert)
+	var keys []alertGroup
+	for _, a := range alerts {
+		key := alertGroup{Workspace: a.Workspace, Channel: a.Channel, Origin: a.Origin}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], a)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Workspace != keys[j].Workspace {
+			return keys[i].Workspace < keys[j].Workspace
+		}
+		return keys[i].Origin < keys[j].Origin
+	})
+
+	// Send messages
+	failed := 0
+	for _, key := range keys {
+		group := groups[key]
+		blocks := digests.BuildLiveAlertBlocks(key.Origin, group, config.MaxLiveAlertComments)
+		err := integrations.WithBotToken(conn, key.Workspace, func(botToken string) error {
+			return integrations.SendBlockKitMessage(botToken, key.Channel, blocks)
+		})
+		if err != nil {
+			log.Printf("failed to send live alert to workspace %s: %v", key.Workspace, err)
+			failed++
+			continue
+		}
+
+		ids := make([]int64, len(group))
+		for i, a := range group {
+			ids[i] = a.FeedbackID
+		}
+		if err := queries.MarkLiveAlertsSent(conn, ids); err != nil {
+			log.Printf("failed to mark live alerts of workspace %s as sent: %v", key.Workspace, err)
+		}
+		log.Printf("Sent live alert with %d comments on %s to workspace %s, channel %s", len(group), key.Origin, key.Workspace, key.Channel)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to send %d of %d live alerts", failed, len(keys))
+	}
+	return nil
+}
@@ -5,10 +5,10 @@
 package digests
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"sort"
 	"time"
@@ -22,6 +22,7 @@ import (
 )
 
 func SendMonthlyDigests(
+	ctx context.Context,
 	conn *sql.DB,
 	cfg *config.DigestConfig,
 ) error {
@@ -34,17 +35,23 @@ func SendMonthlyDigests(
 
 	var messages []models.DigestMessage
 
+	failed := 0
 	for _, ws := range workspaces {
-		blocks, botToken, err := processMonthlyDigest(conn, ws, cfg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		blocks, err := processMonthlyDigest(ctx, conn, ws, cfg)
 		if err != nil {
-			return fmt.Errorf("failed to process monthly digest for workspace %s: %v", ws.Workspace, err)
+			// Carry on with the other workspaces
+			log.Printf("failed to process monthly digest for workspace %s: %v", ws.Workspace, err)
+			failed++
+			continue
 		}
 		if len(blocks) == 0 {
 			log.Printf("No digest data for workspace %s", ws.Workspace)
 			continue
 		}
 		messages = append(messages, models.DigestMessage{
-			BotToken:  botToken,
 			Channel:   ws.Channel,
 			Blocks:    blocks,
 			Workspace: ws.Workspace,
@@ -57,64 +64,68 @@ func SendMonthlyDigests(
 
 	// Send messages
 	for _, msg := range messages {
-		err := integrations.SendBlockKitMessage(msg.BotToken, msg.Channel, msg.Blocks)
+		// Fetch the token only now, as it may have expired while the digests were prepared
+		err := integrations.WithBotToken(conn, msg.Workspace, func(botToken string) error {
+			return integrations.SendBlockKitMessage(botToken, msg.Channel, msg.Blocks)
+		})
 		if err != nil {
 			log.Printf("failed to send monthly digest to workspace %s: %v", msg.Workspace, err)
+			failed++
 			continue
 		} else {
 			log.Printf("Sent monthly digest to workspace %s, channel %s", msg.Workspace, msg.Channel)
 		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("failed to process or send the monthly digests of %d of %d workspaces", failed, len(workspaces))
+	}
 	return nil
 }
 
 func processMonthlyDigest(
+	ctx context.Context,
 	conn *sql.DB,
 	ws models.WorkspaceChannel,
 	cfg *config.DigestConfig,
-) ([]map[string]any, string, error) {
+) ([]map[string]any, error) {
 	groups, err := queries.GetFeedbackGroups(conn, ws.Workspace, models.Monthly)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get feedback groups: %w", err)
+		return nil, fmt.Errorf("failed to get feedback groups: %w", err)
 	}
 	if len(groups) == 0 {
 		log.Printf("No feedback groups for workspace %s", ws.Workspace)
-		return nil, "", nil
+		return nil, nil
 	}
 
 	summaries, err := queries.GetSummariesByWorkspace(conn, ws.Workspace, models.Monthly)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get summaries: %w", err)
+		return nil, fmt.Errorf("failed to get summaries: %w", err)
 	}
 	if len(summaries) == 0 {
 		log.Printf("No summary data for workspace %s", ws.Workspace)
 	}
 
-	botToken, err := queries.GetBotTokenForWorkspace(conn, ws.Workspace)
+	digestBlocks, err := prepareMonthlyDigestData(ctx, conn, cfg, groups, summaries, ws.Workspace, ws.Channel)
 	if err != nil {
-		return nil, "", fmt.Errorf("no bot token found for workspace %s: %w", ws.Workspace, err)
-	}
-
-	digestBlocks, err := prepareMonthlyDigestData(conn, cfg, groups, summaries, ws.Workspace, botToken, ws.Channel)
-	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	if len(digestBlocks) == 0 {
 		log.Printf("No digest data for workspace %s", ws.Workspace)
-		return nil, botToken, nil
+		return nil, nil
 	}
 
 	blocks := digests.BuildMonthlyDigestBlocks(digestBlocks, utils.MonthLabel())
-	return blocks, botToken, nil
+	return blocks, nil
 }
 
 func prepareMonthlyDigestData(
+	ctx context.Context,
 	conn *sql.DB,
 	cfg *config.DigestConfig,
 	groups []models.FeedbackGroup, // Only origin and category are used
 	summaries []models.SummaryRow,
-	workspace, botToken, channel string,
+	workspace, channel string,
 ) ([]models.MonthlyDigestData, error) {
 	type groupKey struct {
 		Origin   string
@@ -128,31 +139,16 @@ func prepareMonthlyDigestData(
 	}
 
 	var digestBlocks []models.MonthlyDigestData
-	maxRows := cfg.AIDigestInputLimit
 
 	for _, g := range groups {
 		key := groupKey{Origin: g.Origin, Category: g.Category}
 		groupSummaries := summaryMap[key]
 		groupLen := len(groupSummaries)
 
-		if groupLen > maxRows {
-			log.Printf("Encountered %d summary rows (while the limit is %d) for workspace %s", groupLen, maxRows, workspace)
-			rand.Shuffle(groupLen, func(i, j int) {
-				groupSummaries[i], groupSummaries[j] = groupSummaries[j], groupSummaries[i]
-			})
-			groupSummaries = groupSummaries[:maxRows]
-		}
-
 		digest := "No comments to summarize"
+		var coverage models.InputCoverage
 		if groupLen > 0 {
-			csvData, err := utils.SummariesToCSV(groupSummaries, true)
-			if err != nil {
-				return nil, fmt.Errorf("failed to generate CSV for workspace %s, origin %s, category %s: %w", workspace, g.Origin, g.Category, err)
-			}
-			digest, err = integrations.GetAISummary(cfg.AIApiURL, cfg.AIApiKey, cfg.AIModel, cfg.AIMonthlyDigestPrompt, csvData)
-			if err != nil {
-				return nil, fmt.Errorf("AI job failed for workspace %s, origin %s, category %s: %w", workspace, g.Origin, g.Category, err)
-			}
+			digest, coverage = summarize(ctx, cfg, cfg.AIMonthlyDigestPrompt, groupSummaries, true, fmt.Sprintf("workspace %s, origin %s, category %s", workspace, g.Origin, g.Category))
 		}
 
 		stats, err := queries.GetMonthlyFeedbackStats(conn, workspace, g.Origin, g.Category)
@@ -160,25 +156,24 @@ func prepareMonthlyDigestData(
 			return nil, fmt.Errorf("failed to get stats for workspace %s, origin %s, category %s: %w", workspace, g.Origin, g.Category, err)
 		}
 
-		graphURL, err := GenerateAndUploadMonthlyGraph(conn, botToken, channel, workspace, g.Origin, g.Category)
+		graphURL, err := GenerateAndUploadMonthlyGraph(conn, channel, workspace, g.Origin, g.Category)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate or upload graph for %s/%s of workspace %s: %w", g.Origin, g.Category, workspace, err)
 		}
 
-		scoreDelta := "-"
-		if stats.PrevNFeedback > 0 {
-			scoreDelta = utils.FormatDelta(stats.ThumbsUpPct - stats.PrevThumbsUpPct)
-		}
+		score := utils.ScoreFromStats(stats)
 		digestBlocks = append(digestBlocks, models.MonthlyDigestData{
 			Origin:          g.Origin,
 			Category:        g.Category,
-			Score:           utils.RoundFloat(stats.ThumbsUpPct),
-			ScoreDelta:      scoreDelta,
+			Scale:           stats.Scale,
+			MeanRating:      stats.MeanRating,
+			Score:           score,
 			NResponses:      stats.NFeedback,
 			NResponsesDelta: utils.FormatDelta(stats.PrevNFeedbackDl),
 			NComments:       stats.NComments,
 			NCommentsDelta:  utils.FormatDelta(stats.PrevCommentsDl),
 			Digest:          digest,
+			Coverage:        coverage,
 			GraphURL:        graphURL,
 		})
 	}
@@ -197,7 +192,7 @@ func prepareMonthlyDigestData(
 // Generate and upload a monthly graph, returning the Slack file URL
 func GenerateAndUploadMonthlyGraph(
 	conn *sql.DB,
-	botToken, channel, workspace, origin, category string,
+	channel, workspace, origin, category string,
 ) (string, error) {
 	// Get plot stats
 	stats, err := queries.GetMonthlyDigestPlotStats(conn, workspace, origin, category)
@@ -221,7 +216,12 @@ func GenerateAndUploadMonthlyGraph(
 	}
 
 	// Upload the file to Slack
-	url, err := integrations.UploadFileToSlack(botToken, channel, filePath, fmt.Sprintf("Quarterly graph for %s", origin), "")
+	var url string
+	err = integrations.WithBotToken(conn, workspace, func(botToken string) error {
+		var err error
+		url, err = integrations.UploadFileToSlack(botToken, channel, filePath, fmt.Sprintf("Quarterly graph for %s", origin), "")
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to Slack: %w", err)
 	}
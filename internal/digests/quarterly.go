@@ -5,10 +5,10 @@
 package digests
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"sort"
 	"time"
@@ -22,6 +22,7 @@ import (
 )
 
 func SendQuarterlyDigests(
+	ctx context.Context,
 	conn *sql.DB,
 	cfg *config.DigestConfig,
 ) error {
@@ -34,17 +35,23 @@ func SendQuarterlyDigests(
 
 	var messages []models.DigestMessage
 
+	failed := 0
 	for _, ws := range workspaces {
-		blocks, botToken, err := processQuarterlyDigest(conn, ws, cfg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		blocks, err := processQuarterlyDigest(ctx, conn, ws, cfg)
 		if err != nil {
-			return fmt.Errorf("failed to process quarterly digest for workspace %s: %v", ws.Workspace, err)
+			// Carry on with the other workspaces
+			log.Printf("failed to process quarterly digest for workspace %s: %v", ws.Workspace, err)
+			failed++
+			continue
 		}
 		if len(blocks) == 0 {
 			log.Printf("No digest data for workspace %s", ws.Workspace)
 			continue
 		}
 		messages = append(messages, models.DigestMessage{
-			BotToken:  botToken,
 			Channel:   ws.Channel,
 			Blocks:    blocks,
 			Workspace: ws.Workspace,
@@ -57,64 +64,68 @@ func SendQuarterlyDigests(
 
 	// Send messages
 	for _, msg := range messages {
-		err := integrations.SendBlockKitMessage(msg.BotToken, msg.Channel, msg.Blocks)
+		// Fetch the token only now, as it may have expired while the digests were prepared
+		err := integrations.WithBotToken(conn, msg.Workspace, func(botToken string) error {
+			return integrations.SendBlockKitMessage(botToken, msg.Channel, msg.Blocks)
+		})
 		if err != nil {
 			log.Printf("failed to send quarterly digest to workspace %s: %v", msg.Workspace, err)
+			failed++
 			continue
 		} else {
 			log.Printf("Sent quarterly digest to workspace %s, channel %s", msg.Workspace, msg.Channel)
 		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("failed to process or send the quarterly digests of %d of %d workspaces", failed, len(workspaces))
+	}
 	return nil
 }
 
 func processQuarterlyDigest(
+	ctx context.Context,
 	conn *sql.DB,
 	ws models.WorkspaceChannel,
 	cfg *config.DigestConfig,
-) ([]map[string]any, string, error) {
+) ([]map[string]any, error) {
 	groups, err := queries.GetFeedbackGroups(conn, ws.Workspace, models.Quarterly)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get feedback groups: %w", err)
+		return nil, fmt.Errorf("failed to get feedback groups: %w", err)
 	}
 	if len(groups) == 0 {
 		log.Printf("No feedback groups for workspace %s", ws.Workspace)
-		return nil, "", nil
+		return nil, nil
 	}
 
 	summaries, err := queries.GetSummariesByWorkspace(conn, ws.Workspace, models.Quarterly)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get summaries: %w", err)
+		return nil, fmt.Errorf("failed to get summaries: %w", err)
 	}
 	if len(summaries) == 0 {
 		log.Printf("No summary data for workspace %s", ws.Workspace)
 	}
 
-	botToken, err := queries.GetBotTokenForWorkspace(conn, ws.Workspace)
-	if err != nil {
-		return nil, "", fmt.Errorf("no bot token found for workspace %s: %w", ws.Workspace, err)
-	}
-
-	digestBlocks, err := prepareQuarterlyDigestData(conn, cfg, groups, summaries, ws.Workspace, botToken, ws.Channel)
+	digestBlocks, err := prepareQuarterlyDigestData(ctx, conn, cfg, groups, summaries, ws.Workspace, ws.Channel)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	if len(digestBlocks) == 0 {
 		log.Printf("No digest data for workspace %s", ws.Workspace)
-		return nil, botToken, nil
+		return nil, nil
 	}
 
 	blocks := digests.BuildQuarterlyDigestBlocks(digestBlocks, utils.QuarterLabel())
-	return blocks, botToken, nil
+	return blocks, nil
 }
 
 func prepareQuarterlyDigestData(
+	ctx context.Context,
 	conn *sql.DB,
 	cfg *config.DigestConfig,
 	groups []models.FeedbackGroup, // Only origin is used
 	summaries []models.SummaryRow,
-	workspace, botToken, channel string,
+	workspace, channel string,
 ) ([]models.QuarterlyDigestData, error) {
 	// Group summaries by origin
 	summaryMap := make(map[string][]models.SummaryRow)
@@ -123,33 +134,18 @@ func prepareQuarterlyDigestData(
 	}
 
 	var digestBlocks []models.QuarterlyDigestData
-	maxRows := cfg.AIDigestInputLimit
 
 	for _, g := range groups {
 		groupSummaries := summaryMap[g.Origin]
 		groupLen := len(groupSummaries)
 
-		if groupLen > maxRows {
-			log.Printf("Encountered %d summary rows (while the limit is %d) for workspace %s", groupLen, maxRows, workspace)
-			rand.Shuffle(groupLen, func(i, j int) {
-				groupSummaries[i], groupSummaries[j] = groupSummaries[j], groupSummaries[i]
-			})
-			groupSummaries = groupSummaries[:maxRows]
-		}
-
 		digest := "No comments to summarize"
+		var coverage models.InputCoverage
 		if groupLen > 0 {
-			csvData, err := utils.SummariesToCSV(groupSummaries, true)
-			if err != nil {
-				return nil, fmt.Errorf("failed to generate CSV for workspace %s, origin %s: %w", workspace, g.Origin, err)
-			}
-			digest, err = integrations.GetAISummary(cfg.AIApiURL, cfg.AIApiKey, cfg.AIModel, cfg.AIQuarterlyDigestPrompt, csvData)
-			if err != nil {
-				return nil, fmt.Errorf("AI job failed for workspace %s, origin %s: %w", workspace, g.Origin, err)
-			}
+			digest, coverage = summarize(ctx, cfg, cfg.AIQuarterlyDigestPrompt, groupSummaries, true, fmt.Sprintf("workspace %s, origin %s", workspace, g.Origin))
 		}
 
-		graphURL, err := GenerateAndUploadQuarterlyGraph(conn, botToken, channel, workspace, g.Origin)
+		graphURL, err := GenerateAndUploadQuarterlyGraph(conn, channel, workspace, g.Origin)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate or upload graph for %s of workspace %s: %w", g.Origin, workspace, err)
 		}
@@ -157,6 +153,7 @@ func prepareQuarterlyDigestData(
 		digestBlocks = append(digestBlocks, models.QuarterlyDigestData{
 			Origin:   g.Origin,
 			Digest:   digest,
+			Coverage: coverage,
 			GraphURL: graphURL,
 		})
 	}
@@ -172,7 +169,7 @@ func prepareQuarterlyDigestData(
 // Generate and upload a quarterly graph, returning the Slack file URL
 func GenerateAndUploadQuarterlyGraph(
 	conn *sql.DB,
-	botToken, channel, workspace, origin string,
+	channel, workspace, origin string,
 ) (string, error) {
 	// Get plot stats
 	stats, err := queries.GetQuarterlyDigestPlotStats(conn, workspace, origin)
@@ -196,7 +193,12 @@ func GenerateAndUploadQuarterlyGraph(
 	}
 
 	// Upload the file to Slack
-	url, err := integrations.UploadFileToSlack(botToken, channel, filePath, fmt.Sprintf("Quarterly graph for %s", origin), "")
+	var url string
+	err = integrations.WithBotToken(conn, workspace, func(botToken string) error {
+		var err error
+		url, err = integrations.UploadFileToSlack(botToken, channel, filePath, fmt.Sprintf("Quarterly graph for %s", origin), "")
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to Slack: %w", err)
 	}
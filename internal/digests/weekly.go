@@ -5,10 +5,10 @@
 package digests
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
-	"math/rand"
 	"sort"
 
 	"twothumbs/internal/config"
@@ -20,6 +20,7 @@ import (
 )
 
 func SendWeeklyDigests(
+	ctx context.Context,
 	conn *sql.DB,
 	cfg *config.DigestConfig,
 ) error {
@@ -32,17 +33,23 @@ func SendWeeklyDigests(
 
 	var messages []models.DigestMessage
 
+	failed := 0
 	for _, ws := range workspaces {
-		blocks, botToken, err := processWeeklyDigest(conn, ws, cfg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		blocks, err := processWeeklyDigest(ctx, conn, ws, cfg)
 		if err != nil {
-			return fmt.Errorf("failed to process weekly digest for workspace %s: %v", ws.Workspace, err)
+			// Carry on with the other workspaces
+			log.Printf("failed to process weekly digest for workspace %s: %v", ws.Workspace, err)
+			failed++
+			continue
 		}
 		if len(blocks) == 0 {
 			log.Printf("No digest data for workspace %s", ws.Workspace)
 			continue
 		}
 		messages = append(messages, models.DigestMessage{
-			BotToken:  botToken,
 			Channel:   ws.Channel,
 			Blocks:    blocks,
 			Workspace: ws.Workspace,
@@ -51,57 +58,63 @@ func SendWeeklyDigests(
 
 	// Send messages
 	for _, msg := range messages {
-		err := integrations.SendBlockKitMessage(msg.BotToken, msg.Channel, msg.Blocks)
+		// Fetch the token only now, as it may have expired while the digests were prepared
+		err := integrations.WithBotToken(conn, msg.Workspace, func(botToken string) error {
+			return integrations.SendBlockKitMessage(botToken, msg.Channel, msg.Blocks)
+		})
 		if err != nil {
-			return fmt.Errorf("failed to send weekly digest to workspace %s: %v", msg.Workspace, err)
+			log.Printf("failed to send weekly digest to workspace %s: %v", msg.Workspace, err)
+			failed++
+			continue
 		} else {
 			log.Printf("Sent weekly digest to workspace %s, channel %s", msg.Workspace, msg.Channel)
 		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("failed to process or send the weekly digests of %d of %d workspaces", failed, len(workspaces))
+	}
 	return nil
 }
 
 func processWeeklyDigest(
+	ctx context.Context,
 	conn *sql.DB,
 	ws models.WorkspaceChannel,
 	cfg *config.DigestConfig,
-) ([]map[string]any, string, error) {
+) ([]map[string]any, error) {
 	groups, err := queries.GetFeedbackGroups(conn, ws.Workspace, models.Weekly)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get feedback groups: %w", err)
+		return nil, fmt.Errorf("failed to get feedback groups: %w", err)
 	}
 	if len(groups) == 0 {
 		log.Printf("No feedback groups for workspace %s", ws.Workspace)
-		return nil, "", nil
+		return nil, nil
 	}
 
 	summaries, err := queries.GetSummariesByWorkspace(conn, ws.Workspace, models.Weekly)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get summaries: %w", err)
+		return nil, fmt.Errorf("failed to get summaries: %w", err)
 	}
 	if len(summaries) == 0 {
 		log.Printf("No summary data for workspace %s", ws.Workspace)
 	}
 
-	digestBlocks, err := prepareWeeklyDigestData(conn, cfg, groups, summaries, ws.Workspace)
+	digestBlocks, err := prepareWeeklyDigestData(ctx, conn, cfg, groups, summaries, ws.Workspace)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	if len(digestBlocks) == 0 {
 		log.Printf("No digest data for workspace %s", ws.Workspace)
-		return nil, "", nil
+		return nil, nil
 	}
 
 	blocks := digests.BuildWeeklyDigestBlocks(digestBlocks)
-	botToken, err := queries.GetBotTokenForWorkspace(conn, ws.Workspace)
-	if err != nil {
-		return nil, "", fmt.Errorf("no bot token found for workspace %s: %w", ws.Workspace, err)
-	}
-	return blocks, botToken, nil
+	return blocks, nil
 }
 
 func prepareWeeklyDigestData(
+	ctx context.Context,
 	conn *sql.DB,
 	cfg *config.DigestConfig,
 	groups []models.FeedbackGroup,
@@ -120,7 +133,6 @@ func prepareWeeklyDigestData(
 	}
 
 	var digestBlocks []models.WeeklyDigestData
-	maxRows := cfg.AIDigestInputLimit
 
 	for _, g := range groups {
 		key := models.FeedbackGroup{
@@ -131,24 +143,10 @@ func prepareWeeklyDigestData(
 		groupSummaries := summaryMap[key]
 		groupLen := len(groupSummaries)
 
-		if groupLen > maxRows {
-			log.Printf("Encountered %d summary rows (while the limit is %d) for workspace %s", groupLen, maxRows, workspace)
-			rand.Shuffle(groupLen, func(i, j int) {
-				groupSummaries[i], groupSummaries[j] = groupSummaries[j], groupSummaries[i]
-			})
-			groupSummaries = groupSummaries[:maxRows]
-		}
-
 		digest := "No comments to summarize"
+		var coverage models.InputCoverage
 		if groupLen > 0 {
-			csvData, err := utils.SummariesToCSV(groupSummaries, false)
-			if err != nil {
-				return nil, fmt.Errorf("failed to generate CSV for workspace %s, origin %s, category %s, prompt %s: %w", workspace, g.Origin, g.Category, g.Prompt, err)
-			}
-			digest, err = integrations.GetAISummary(cfg.AIApiURL, cfg.AIApiKey, cfg.AIModel, cfg.AIWeeklyDigestPrompt, csvData)
-			if err != nil {
-				return nil, fmt.Errorf("AI job failed for workspace %s, origin %s, category %s, prompt %s: %w", workspace, g.Origin, g.Category, g.Prompt, err)
-			}
+			digest, coverage = summarize(ctx, cfg, cfg.AIWeeklyDigestPrompt, groupSummaries, false, fmt.Sprintf("workspace %s, origin %s, category %s, prompt %s", workspace, g.Origin, g.Category, g.Prompt))
 		}
 
 		stats, err := queries.GetWeeklyFeedbackStats(conn, workspace, g.Origin, g.Category, g.Prompt)
@@ -156,21 +154,20 @@ func prepareWeeklyDigestData(
 			return nil, fmt.Errorf("failed to get stats for workspace %s, origin %s, category %s, prompt %s: %w", workspace, g.Origin, g.Category, g.Prompt, err)
 		}
 
-		scoreDelta := "-"
-		if stats.PrevNFeedback > 0 {
-			scoreDelta = utils.FormatDelta(stats.ThumbsUpPct - stats.PrevThumbsUpPct)
-		}
+		score := utils.ScoreFromStats(stats)
 		digestBlocks = append(digestBlocks, models.WeeklyDigestData{
 			Origin:          g.Origin,
 			Category:        g.Category,
 			Prompt:          g.Prompt,
-			Score:           utils.RoundFloat(stats.ThumbsUpPct),
-			ScoreDelta:      scoreDelta,
+			Scale:           stats.Scale,
+			MeanRating:      stats.MeanRating,
+			Score:           score,
 			NResponses:      stats.NFeedback,
 			NResponsesDelta: utils.FormatDelta(stats.PrevNFeedbackDl),
 			NComments:       stats.NComments,
 			NCommentsDelta:  utils.FormatDelta(stats.PrevCommentsDl),
 			Digest:          digest,
+			Coverage:        coverage,
 		})
 	}
 
@@ -1,51 +1,96 @@
 // File: internal/integrations/aiapi.go
 
 // This file contains the logic to interact with the AI API for generating digests.
+// The API is reached through a provider, so that digests can also run against Anthropic or
+// a self-hosted model behind an OpenAI-compatible chat completions API (e.g. Ollama or vLLM).
 
 package integrations
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-func GetAISummary(apiURL, apiKey, aiModel, aiPrompt, csvData string) (string, error) {
+// Supported AI providers, selected with AI_PROVIDER
+const (
+	AIProviderResponses = "responses" // OpenAI Responses API
+	AIProviderAnthropic = "anthropic" // Anthropic Messages API
+	AIProviderChat      = "chat"      // OpenAI-compatible chat completions API
+)
+
+const anthropicVersion = "2023-06-01"
+
+// Returned if the output was cut off at the max tokens, as a partial summary would read as a complete one
+var ErrAIOutputTruncated = errors.New("AI output truncated at the max tokens")
+
+// A language model generating text from instructions and input
+type AIProvider interface {
+	Complete(ctx context.Context, instructions, input string) (string, error)
+}
+
+// An error response of an AI API
+type AIAPIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, 0 if absent
+}
+
+func (e *AIAPIError) Error() string {
+	return fmt.Sprintf("AI API error %d: %s", e.StatusCode, e.Body)
+}
+
+// Create the provider of the given kind. maxTokens limits the length of the output,
+// and is required by the Anthropic API only.
+func NewAIProvider(kind, apiURL, apiKey, model string, maxTokens int) (AIProvider, error) {
+	switch kind {
+	case AIProviderResponses:
+		return &responsesProvider{apiURL: apiURL, apiKey: apiKey, model: model, maxTokens: maxTokens}, nil
+	case AIProviderAnthropic:
+		if maxTokens <= 0 {
+			return nil, fmt.Errorf("the %s provider requires a positive max tokens", kind)
+		}
+		return &anthropicProvider{apiURL: apiURL, apiKey: apiKey, model: model, maxTokens: maxTokens}, nil
+	case AIProviderChat:
+		return &chatProvider{apiURL: apiURL, apiKey: apiKey, model: model, maxTokens: maxTokens}, nil
+	}
+	return nil, fmt.Errorf("unsupported AI provider: %s", kind)
+}
+
+// *Responses API*
+
+type responsesProvider struct {
+	apiURL    string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+func (p *responsesProvider) Complete(ctx context.Context, instructions, input string) (string, error) {
 	payload := map[string]any{
-		"model":        aiModel,
-		"instructions": aiPrompt,
-		"input":        csvData,
+		"model":        p.model,
+		"instructions": instructions,
+		"input":        input,
 		"store":        false,
 		"temperature":  0.0,
 		"tool_choice":  "none",
 	}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %w", err)
-	}
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("call error: %w", err)
+	if p.maxTokens > 0 {
+		payload["max_output_tokens"] = p.maxTokens
 	}
-	defer resp.Body.Close()
+	headers := map[string]string{"Authorization": "Bearer " + p.apiKey}
 
-	respBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("read error: %w", err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("AI API error %d: %s", resp.StatusCode, string(respBytes))
-	}
 	var result struct {
+		Status            string `json:"status"`
+		IncompleteDetails struct {
+			Reason string `json:"reason"`
+		} `json:"incomplete_details"`
 		Output []struct {
 			Type    string `json:"type"`
 			ID      string `json:"id"`
@@ -57,9 +102,14 @@ func GetAISummary(apiURL, apiKey, aiModel, aiPrompt, csvData string) (string, er
 			} `json:"content"`
 		} `json:"output"`
 	}
-
-	if err := json.Unmarshal(respBytes, &result); err != nil {
-		return "", fmt.Errorf("unmarshal error: %w", err)
+	if err := postAIRequest(ctx, p.apiURL, headers, payload, &result); err != nil {
+		return "", err
+	}
+	if result.Status == "incomplete" {
+		if result.IncompleteDetails.Reason == "max_output_tokens" {
+			return "", ErrAIOutputTruncated
+		}
+		return "", fmt.Errorf("incomplete response (reason: %s)", result.IncompleteDetails.Reason)
 	}
 
 	for _, msg := range result.Output {
@@ -75,3 +125,157 @@ func GetAISummary(apiURL, apiKey, aiModel, aiPrompt, csvData string) (string, er
 
 	return "", fmt.Errorf("no output_text segment found")
 }
+
+// *Anthropic Messages API*
+
+type anthropicProvider struct {
+	apiURL    string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+func (p *anthropicProvider) Complete(ctx context.Context, instructions, input string) (string, error) {
+	payload := map[string]any{
+		"model":       p.model,
+		"system":      instructions,
+		"max_tokens":  p.maxTokens,
+		"temperature": 0.0,
+		"messages": []map[string]any{
+			{"role": "user", "content": input},
+		},
+	}
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+	}
+	if err := postAIRequest(ctx, p.apiURL, headers, payload, &result); err != nil {
+		return "", err
+	}
+	if result.StopReason == "max_tokens" {
+		return "", ErrAIOutputTruncated
+	}
+
+	var text string
+	for _, part := range result.Content {
+		if part.Type == "text" {
+			text += part.Text
+		}
+	}
+	if text == "" {
+		return "", fmt.Errorf("no text content found (stop reason: %s)", result.StopReason)
+	}
+	return text, nil
+}
+
+// *Chat completions API*
+
+type chatProvider struct {
+	apiURL    string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+func (p *chatProvider) Complete(ctx context.Context, instructions, input string) (string, error) {
+	payload := map[string]any{
+		"model":       p.model,
+		"temperature": 0.0,
+		"stream":      false,
+		"messages": []map[string]any{
+			{"role": "system", "content": instructions},
+			{"role": "user", "content": input},
+		},
+	}
+	if p.maxTokens > 0 {
+		payload["max_tokens"] = p.maxTokens
+	}
+	// Self-hosted servers often run without a key
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := postAIRequest(ctx, p.apiURL, headers, payload, &result); err != nil {
+		return "", err
+	}
+
+	for _, choice := range result.Choices {
+		if choice.FinishReason == "length" {
+			return "", ErrAIOutputTruncated
+		}
+		if choice.Message.Content != "" {
+			return choice.Message.Content, nil
+		}
+	}
+	return "", fmt.Errorf("no message content found")
+}
+
+// Post a JSON payload to an AI API and decode the JSON response into result
+func postAIRequest(ctx context.Context, apiURL string, headers map[string]string, payload map[string]any, result any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("call error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &AIAPIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if err := json.Unmarshal(respBytes, result); err != nil {
+		return fmt.Errorf("unmarshal error: %w", err)
+	}
+	return nil
+}
+
+// Parse a Retry-After header, given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
@@ -0,0 +1,159 @@
+// File: internal/integrations/aiclient.go
+
+// This file contains a client that calls an AI provider with a timeout per attempt, and retries transient
+// failures (timeouts, 429s, and 5xx responses) with exponential backoff and jitter, honoring Retry-After.
+// Calls that still fail count against an error budget, after which the client fails fast, so that an
+// unavailable AI API does not hold up a whole run.
+
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	aiRetryBaseDelay  = 2 * time.Second
+	aiRetryMaxDelay   = 60 * time.Second
+	aiMaxRetryAfter   = 5 * time.Minute // longer Retry-After responses are not waited for
+	aiMaxErrorBodyLen = 500
+)
+
+var ErrAIErrorBudgetExhausted = errors.New("AI error budget exhausted")
+
+type AIClient struct {
+	provider    AIProvider
+	timeout     time.Duration
+	maxAttempts int
+	errorBudget int // failed calls before failing fast, 0 for no limit
+
+	mu       sync.Mutex
+	failures int
+}
+
+// Create a client calling the provider with a timeout per attempt, up to maxAttempts times per call.
+// After errorBudget failed calls (0 for no limit), further calls fail with ErrAIErrorBudgetExhausted.
+func NewAIClient(provider AIProvider, timeout time.Duration, maxAttempts, errorBudget int) *AIClient {
+	return &AIClient{
+		provider:    provider,
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
+		errorBudget: errorBudget,
+	}
+}
+
+// Generate text from instructions and input, retrying transient failures
+func (c *AIClient) Complete(ctx context.Context, instructions, input string) (string, error) {
+	if c.budgetExhausted() {
+		return "", ErrAIErrorBudgetExhausted
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var text string
+		text, err = c.attempt(ctx, instructions, input)
+		if err == nil {
+			return text, nil
+		}
+		if ctx.Err() != nil {
+			// The run is shutting down, which is not the fault of the API
+			return "", ctx.Err()
+		}
+
+		delay, retryable := retryDelayFor(err, attempt)
+		if !retryable || attempt >= c.maxAttempts {
+			break
+		}
+		log.Printf("AI call failed (attempt %d/%d), retrying in %s: %v", attempt, c.maxAttempts, delay.Round(time.Millisecond), truncateError(err))
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	c.recordFailure()
+	return "", err
+}
+
+// Call the provider once, within the timeout
+func (c *AIClient) attempt(ctx context.Context, instructions, input string) (string, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	text, err := c.provider.Complete(attemptCtx, instructions, input)
+	if err != nil && attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return "", fmt.Errorf("AI call timed out after %s: %w", c.timeout, err)
+	}
+	return text, err
+}
+
+func (c *AIClient) budgetExhausted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.errorBudget > 0 && c.failures >= c.errorBudget
+}
+
+func (c *AIClient) recordFailure() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures++
+	if c.errorBudget > 0 && c.failures == c.errorBudget {
+		log.Printf("AI error budget of %d failed calls exhausted, skipping further AI calls", c.errorBudget)
+	}
+}
+
+// Delay before retrying a failed attempt, and whether the failure is transient. Retry-After is honored
+// if it is not longer than aiMaxRetryAfter, and the delay doubles with each attempt otherwise.
+func retryDelayFor(err error, attempt int) (time.Duration, bool) {
+	var apiErr *AIAPIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode == http.StatusConflict,
+			apiErr.StatusCode == http.StatusTooManyRequests,
+			apiErr.StatusCode >= 500:
+		default:
+			return 0, false
+		}
+		if apiErr.RetryAfter > aiMaxRetryAfter {
+			return 0, false
+		}
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter + jitter(apiErr.RetryAfter/10), true
+		}
+	}
+	var netErr net.Error
+	transient := errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+	if apiErr == nil && !transient {
+		// Invalid requests and malformed responses would fail the same way again
+		return 0, false
+	}
+
+	delay := aiRetryBaseDelay
+	for i := 1; i < attempt && delay < aiRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, aiRetryMaxDelay)
+	return delay/2 + jitter(delay/2), true
+}
+
+func jitter(upTo time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(upTo) + 1))
+}
+
+// Shorten API error bodies for the logs
+func truncateError(err error) string {
+	msg := err.Error()
+	if len(msg) > aiMaxErrorBodyLen {
+		return msg[:aiMaxErrorBodyLen] + "…"
+	}
+	return msg
+}
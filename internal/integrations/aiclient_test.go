@@ -0,0 +1,45 @@
+// File: internal/integrations/aiclient_test.go
+
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRetryDelayFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		attempt   int
+		retryable bool
+		min, max  time.Duration
+	}{
+		{"bad request", &AIAPIError{StatusCode: 400}, 1, false, 0, 0},
+		{"unauthorized", &AIAPIError{StatusCode: 401}, 1, false, 0, 0},
+		{"server error", &AIAPIError{StatusCode: 500}, 1, true, time.Second, 2 * time.Second},
+		{"server error, third attempt", &AIAPIError{StatusCode: 503}, 3, true, 4 * time.Second, 8 * time.Second},
+		{"server error, capped", &AIAPIError{StatusCode: 502}, 10, true, 30 * time.Second, 60 * time.Second},
+		{"rate limited with Retry-After", &AIAPIError{StatusCode: 429, RetryAfter: 10 * time.Second}, 1, true, 10 * time.Second, 11 * time.Second},
+		{"Retry-After too long", &AIAPIError{StatusCode: 429, RetryAfter: 10 * time.Minute}, 1, false, 0, 0},
+		{"timeout", fmt.Errorf("AI call timed out: %w", context.DeadlineExceeded), 1, true, time.Second, 2 * time.Second},
+		{"connection cut", fmt.Errorf("read error: %w", io.ErrUnexpectedEOF), 2, true, 2 * time.Second, 4 * time.Second},
+		{"malformed response", errors.New("unmarshal error"), 1, false, 0, 0},
+		{"truncated output", ErrAIOutputTruncated, 1, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retryable := retryDelayFor(tt.err, tt.attempt)
+			if retryable != tt.retryable {
+				t.Fatalf("retryable = %v, want %v", retryable, tt.retryable)
+			}
+			if retryable && (delay < tt.min || delay > tt.max) {
+				t.Errorf("delay = %s, want between %s and %s", delay, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// File: internal/integrations/aisummary.go
+
+// This file contains the logic to summarize more rows than fit in one AI call. The rows are split into
+// chunks that fit, each chunk is summarized, and the partial summaries are then summarized in turn (map-reduce).
+// The input of a summary is capped by a budget of tokens, so that its cost stays bounded however much feedback there is.
+
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"twothumbs/internal/models"
+)
+
+// Prepended to the instructions when combining partial summaries
+const reducePreamble = "The input holds partial results, each produced with the instructions below from one part " +
+	"of a larger data set. Combine them into a single result that follows the same instructions, as if it had been " +
+	"produced from the whole data set at once. Merge duplicate points, and weigh points by how often they occur."
+
+// Rough number of tokens of a text, erring on the high side for English
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// Summarize up to maxRows rows with the given instructions, in chunks of about chunkTokens tokens of CSV
+// each, and about budgetTokens tokens of CSV in total. Rows are taken in the order given, so they should
+// be sorted by priority, and the rows beyond the limits are left out. The coverage reports how many rows
+// were included.
+func SummarizeRows[T any](
+	ctx context.Context,
+	ai *AIClient,
+	instructions string,
+	rows []T,
+	toCSV func([]T) (string, error),
+	maxRows, chunkTokens, budgetTokens int,
+) (string, models.InputCoverage, error) {
+	coverage := models.InputCoverage{Rows: len(rows)}
+	if len(rows) > maxRows {
+		rows = rows[:maxRows]
+	}
+	chunks, err := chunkRows(rows, toCSV, chunkTokens, budgetTokens)
+	if err != nil {
+		return "", coverage, err
+	}
+
+	inputs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		if inputs[i], err = toCSV(chunk); err != nil {
+			return "", coverage, err
+		}
+		coverage.IncludedRows += len(chunk)
+	}
+	coverage.Chunks = len(chunks)
+
+	summary, err := ai.SummarizeChunks(ctx, instructions, inputs, chunkTokens)
+	if err != nil {
+		return "", coverage, err
+	}
+	return summary, coverage, nil
+}
+
+// Summarize each chunk with the instructions, and combine the partial summaries into one. Partial summaries
+// that do not fit in one call of about maxTokens tokens are combined in rounds.
+func (c *AIClient) SummarizeChunks(ctx context.Context, instructions string, chunks []string, maxTokens int) (string, error) {
+	if len(chunks) == 0 {
+		return "", fmt.Errorf("nothing to summarize")
+	}
+	if len(chunks) == 1 {
+		return c.Complete(ctx, instructions, chunks[0])
+	}
+
+	// Map
+	partials := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		partial, err := c.Complete(ctx, instructions, chunk)
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize part %d of %d: %w", i+1, len(chunks), err)
+		}
+		partials[i] = partial
+	}
+
+	// Reduce, halving the number of partial summaries at least with each round
+	reduceInstructions := reducePreamble + "\n\nInstructions:\n" + instructions
+	for len(partials) > 1 {
+		var next []string
+		for _, batch := range batchPartials(partials, maxTokens) {
+			combined, err := c.Complete(ctx, reduceInstructions, joinPartials(batch))
+			if err != nil {
+				return "", fmt.Errorf("failed to combine %d partial summaries: %w", len(batch), err)
+			}
+			next = append(next, combined)
+		}
+		partials = next
+	}
+	return partials[0], nil
+}
+
+// Split rows into consecutive chunks of about maxTokens tokens of CSV each, leaving out the rows after
+// budgetTokens tokens in total. A row that is larger than maxTokens on its own gets a chunk of its own.
+func chunkRows[T any](rows []T, toCSV func([]T) (string, error), maxTokens, budgetTokens int) ([][]T, error) {
+	var chunks [][]T
+	start, tokens, total := 0, 0, 0
+	end := len(rows)
+	for i := range rows {
+		// The CSV of a single row includes the header, so this overestimates slightly
+		csv, err := toCSV(rows[i : i+1])
+		if err != nil {
+			return nil, err
+		}
+		rowTokens := EstimateTokens(csv)
+		if total+rowTokens > budgetTokens {
+			end = i
+			break
+		}
+		if i > start && tokens+rowTokens > maxTokens {
+			chunks = append(chunks, rows[start:i])
+			start, tokens = i, 0
+		}
+		tokens += rowTokens
+		total += rowTokens
+	}
+	if start < end {
+		chunks = append(chunks, rows[start:end])
+	}
+	return chunks, nil
+}
+
+// Group partial summaries into batches of about maxTokens tokens, with at least two per batch
+func batchPartials(partials []string, maxTokens int) [][]string {
+	var batches [][]string
+	var batch []string
+	tokens := 0
+	for _, p := range partials {
+		t := EstimateTokens(p)
+		if len(batch) >= 2 && tokens+t > maxTokens {
+			batches = append(batches, batch)
+			batch, tokens = nil, 0
+		}
+		batch = append(batch, p)
+		tokens += t
+	}
+	if len(batch) == 1 && len(batches) > 0 {
+		// Fold a lone leftover into the previous batch
+		batches[len(batches)-1] = append(batches[len(batches)-1], batch[0])
+	} else if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func joinPartials(partials []string) string {
+	var b strings.Builder
+	for i, p := range partials {
+		fmt.Fprintf(&b, "--- Part %d of %d ---\n%s\n\n", i+1, len(partials), strings.TrimSpace(p))
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+// File: internal/integrations/aisummary_test.go
+
+package integrations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Rows of 8 characters, about 2 tokens each
+func testRows(n int) []string {
+	rows := make([]string, n)
+	for i := range rows {
+		rows[i] = strings.Repeat("r", 8)
+	}
+	return rows
+}
+
+func chunkSizes[T any](chunks [][]T) []int {
+	var sizes []int
+	for _, c := range chunks {
+		sizes = append(sizes, len(c))
+	}
+	return sizes
+}
+
+func TestChunkRows(t *testing.T) {
+	toCSV := func(rows []string) (string, error) { return strings.Join(rows, "\n"), nil }
+	large := strings.Repeat("x", 40) // about 10 tokens
+
+	tests := []struct {
+		name         string
+		rows         []string
+		maxTokens    int
+		budgetTokens int
+		want         []int
+	}{
+		{"no rows", nil, 4, 100, nil},
+		{"one chunk", testRows(3), 100, 100, []int{3}},
+		{"split into chunks", testRows(5), 4, 100, []int{2, 2, 1}},
+		{"rows beyond the budget", testRows(5), 4, 7, []int{2, 1}},
+		{"budget below the first row", testRows(2), 4, 1, nil},
+		{"large row on its own", []string{"small", large, "small"}, 4, 100, []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := chunkRows(tt.rows, toCSV, tt.maxTokens, tt.budgetTokens)
+			if err != nil {
+				t.Fatalf("chunkRows: %v", err)
+			}
+			if got := chunkSizes(chunks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunk sizes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchPartials(t *testing.T) {
+	tests := []struct {
+		name      string
+		partials  []string
+		maxTokens int
+		want      []int
+	}{
+		{"single partial", testRows(1), 4, []int{1}},
+		{"all fit", testRows(4), 100, []int{4}},
+		{"at least two per batch", testRows(2), 1, []int{2}},
+		{"split into batches", testRows(4), 4, []int{2, 2}},
+		{"lone leftover folded in", testRows(5), 4, []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkSizes(batchPartials(tt.partials, tt.maxTokens)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("batch sizes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
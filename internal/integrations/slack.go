@@ -37,11 +37,49 @@ func SendBlockKitMessage(botToken, channel string, blocks []map[string]any) erro
 
 // Send a single Block Kit message to Slack
 func sendSingleMessage(botToken, channel string, blocks []map[string]any) error {
-	payload := map[string]any{
+	return postMessage(botToken, map[string]any{
 		"channel": channel,
 		"blocks":  blocks,
+	})
+}
+
+// Reply to a message in its thread with mrkdwn text
+func SendThreadReply(botToken, channel, threadTS, text string) error {
+	return postMessage(botToken, map[string]any{
+		"channel":   channel,
+		"thread_ts": threadTS,
+		"text":      text,
+	})
+}
+
+// Reply to a slash command through its response_url, which takes a message payload without a token
+func SendResponseURLMessage(responseURL string, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack payload: %w", err)
 	}
 
+	req, err := http.NewRequest("POST", responseURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Slack request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("slack response_url returned status: %s: %s", resp.Status, respBody)
+	}
+	return nil
+}
+
+// Post a message with chat.postMessage
+func postMessage(botToken string, payload map[string]any) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Slack payload: %w", err)
@@ -80,7 +118,7 @@ func sendSingleMessage(botToken, channel string, blocks []map[string]any) error
 		if len(respBody.ResponseMetadata.Messages) > 0 {
 			metaMsg = fmt.Sprintf(" Details: %v", respBody.ResponseMetadata.Messages)
 		}
-		return fmt.Errorf("slack API error: %s.%s", respBody.Error, metaMsg)
+		return slackAPIError("slack API error", respBody.Error, metaMsg)
 	}
 
 	return nil
@@ -151,7 +189,7 @@ func UploadFileToSlack(botToken, channel, filePath, title, initialComment string
 		if len(uploadURLResp.ResponseMetadata.Messages) > 0 {
 			metaMsg = fmt.Sprintf(" Details: %v", uploadURLResp.ResponseMetadata.Messages)
 		}
-		return "", fmt.Errorf("slack getUploadURL error: %s.%s", uploadURLResp.Error, metaMsg)
+		return "", slackAPIError("slack getUploadURL error", uploadURLResp.Error, metaMsg)
 	}
 
 	uploadURL := uploadURLResp.UploadURL
@@ -233,7 +271,7 @@ func UploadFileToSlack(botToken, channel, filePath, title, initialComment string
 		if len(completeUploadResp.ResponseMetadata.Messages) > 0 {
 			metaMsg = fmt.Sprintf(" Details: %v", completeUploadResp.ResponseMetadata.Messages)
 		}
-		return "", fmt.Errorf("slack completeUpload error: %s.%s", completeUploadResp.Error, metaMsg)
+		return "", slackAPIError("slack completeUpload error", completeUploadResp.Error, metaMsg)
 	}
 
 	if len(completeUploadResp.Files) == 0 {
@@ -296,7 +334,7 @@ func PushSlackView(triggerID string, modal map[string]any, botToken string) erro
 				metaMsg = fmt.Sprintf(" Details: %v", msgs)
 			}
 		}
-		return fmt.Errorf("slack API error: %v.%s", respData["error"], metaMsg)
+		return slackAPIError("slack API error", respData["error"], metaMsg)
 	}
 
 	return nil
@@ -352,7 +390,7 @@ func OpenSlackModal(triggerID string, modal map[string]any, botToken string) err
 				metaMsg = fmt.Sprintf(" Details: %v", msgs)
 			}
 		}
-		return fmt.Errorf("slack API error: %v.%s", respData["error"], metaMsg)
+		return slackAPIError("slack API error", respData["error"], metaMsg)
 	}
 
 	return nil
@@ -389,7 +427,57 @@ func GetAppHomeChannelID(botToken, userID string) (string, error) {
 		if len(respBody.ResponseMetadata.Messages) > 0 {
 			metaMsg = fmt.Sprintf(" Details: %v", respBody.ResponseMetadata.Messages)
 		}
-		return "", fmt.Errorf("conversations.open error: %s.%s", respBody.Error, metaMsg)
+		return "", slackAPIError("conversations.open error", respBody.Error, metaMsg)
 	}
 	return respBody.Channel.ID, nil
 }
+
+// List the workspaces an org-wide bot token can access, using auth.teams.list
+func ListTeams(botToken string) ([]string, error) {
+	var teams []string
+	cursor := ""
+	for {
+		form := url.Values{}
+		form.Set("limit", "200")
+		if cursor != "" {
+			form.Set("cursor", cursor)
+		}
+		req, err := http.NewRequest("POST", "https://slack.com/api/auth.teams.list", bytes.NewBufferString(form.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create auth.teams.list request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+botToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send auth.teams.list request: %w", err)
+		}
+		var respBody struct {
+			OK    bool `json:"ok"`
+			Teams []struct {
+				ID string `json:"id"`
+			} `json:"teams"`
+			Error            string `json:"error"`
+			ResponseMetadata struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"response_metadata"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&respBody)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode auth.teams.list response: %w", err)
+		}
+		if !respBody.OK {
+			return nil, slackAPIError("auth.teams.list error", respBody.Error, "")
+		}
+
+		for _, t := range respBody.Teams {
+			teams = append(teams, t.ID)
+		}
+		cursor = respBody.ResponseMetadata.NextCursor
+		if cursor == "" {
+			return teams, nil
+		}
+	}
+}
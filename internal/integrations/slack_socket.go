@@ -0,0 +1,363 @@
+// File: internal/integrations/slack_socket.go
+
+// This file contains a Slack Socket Mode client, which receives events, interactions, and slash commands
+// over a WebSocket instead of the public HTTP endpoints. Each envelope is turned into the request Slack
+// would otherwise send over HTTP and served by the given handler, and the response is returned in the
+// acknowledgement of the envelope.
+
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"twothumbs/internal/utils"
+)
+
+const (
+	socketWorkers      = 8
+	socketQueueSize    = 100
+	socketMinBackoff   = time.Second
+	socketMaxBackoff   = 30 * time.Second
+	socketDialTimeout  = 10 * time.Second
+	socketPingInterval = 30 * time.Second // keepalive pings, whose pongs extend the read deadline
+	socketReadTimeout  = 90 * time.Second // reconnect if nothing is received for this long
+)
+
+type SocketModeClient struct {
+	appToken     string
+	openURL      string
+	handler      http.Handler
+	workers      *utils.WorkerPool
+	pingInterval time.Duration
+	readTimeout  time.Duration
+}
+
+// An envelope received over the WebSocket
+type socketEnvelope struct {
+	EnvelopeID             string          `json:"envelope_id"`
+	Type                   string          `json:"type"`
+	Payload                json.RawMessage `json:"payload"`
+	AcceptsResponsePayload bool            `json:"accepts_response_payload"`
+	RetryAttempt           int             `json:"retry_attempt"`
+	RetryReason            string          `json:"retry_reason"`
+	Reason                 string          `json:"reason"` // of disconnect envelopes
+}
+
+// The acknowledgement of an envelope
+type socketAck struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// A WebSocket connection, safe for concurrent acknowledgements
+type socketConn struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+func (sc *socketConn) send(ack socketAck) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return websocket.JSON.Send(sc.ws, ack)
+}
+
+// Send a ping frame, as the payload type of the connection is set to pings
+func (sc *socketConn) ping() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	_, err := sc.ws.Write([]byte("keepalive"))
+	return err
+}
+
+// A network connection whose read deadline is extended whenever data arrives. This includes ping and
+// pong frames, which the websocket package handles without returning them.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	return n, err
+}
+
+// Create a Socket Mode client with an app-level token (connections:write scope).
+// The openURL is that of apps.connections.open, or of a local stand-in for testing.
+// The handler serves POST /slack/events, /slack/interact, and /slack/commands.
+func NewSocketModeClient(appToken, openURL string, handler http.Handler) *SocketModeClient {
+	return &SocketModeClient{
+		appToken:     appToken,
+		openURL:      openURL,
+		handler:      handler,
+		workers:      utils.NewWorkerPool("socket-mode", socketWorkers, socketQueueSize),
+		pingInterval: socketPingInterval,
+		readTimeout:  socketReadTimeout,
+	}
+}
+
+// Receive envelopes until the context is done, reconnecting when Slack asks to or the connection drops
+func (c *SocketModeClient) Run(ctx context.Context) error {
+	backoff := socketMinBackoff
+	for {
+		err := c.runConnection(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			// Slack asked to reconnect
+			backoff = socketMinBackoff
+			continue
+		}
+
+		log.Printf("Socket Mode connection failed, reconnecting in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, socketMaxBackoff)
+	}
+}
+
+// Open a connection and read its envelopes, returning nil if Slack sent a disconnect envelope. The
+// connection is pinged regularly, and given up on if nothing is received within the read timeout.
+func (c *SocketModeClient) runConnection(ctx context.Context) error {
+	wsURL, err := c.openConnection(ctx)
+	if err != nil {
+		return err
+	}
+	wsConfig, err := websocket.NewConfig(wsURL, c.openURL)
+	if err != nil {
+		return fmt.Errorf("invalid Socket Mode URL: %w", err)
+	}
+	ws, err := c.dial(ctx, wsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to dial Socket Mode URL: %w", err)
+	}
+	defer ws.Close()
+	ws.PayloadType = websocket.PingFrame
+	conn := &socketConn{ws: ws}
+
+	// Ping the connection, and close it when the context is done to unblock the read
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				ws.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.ping(); err != nil {
+					log.Printf("failed to ping Socket Mode connection: %v", err)
+				}
+			}
+		}
+	}()
+
+	for {
+		var env socketEnvelope
+		if err := websocket.JSON.Receive(ws, &env); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return fmt.Errorf("nothing received on the Socket Mode connection for %s", c.readTimeout)
+			}
+			return fmt.Errorf("failed to read Socket Mode envelope: %w", err)
+		}
+
+		switch env.Type {
+		case "hello":
+			log.Printf("Socket Mode connection established")
+		case "disconnect":
+			log.Printf("Socket Mode disconnect requested: %s", env.Reason)
+			return nil
+		case "events_api", "interactive", "slash_commands":
+			if !c.workers.Submit(func() { c.handleEnvelope(conn, env) }) {
+				// Unacknowledged envelopes are redelivered by Slack
+				log.Printf("Socket Mode queue is full, dropping envelope %s", env.EnvelopeID)
+			}
+		default:
+			log.Printf("unsupported Socket Mode envelope type: %s", env.Type)
+		}
+	}
+}
+
+// Dial a WebSocket over a connection that times out after the read timeout without data
+func (c *SocketModeClient) dial(ctx context.Context, wsConfig *websocket.Config) (*websocket.Conn, error) {
+	addr := wsConfig.Location.Host
+	dialer := &net.Dialer{Timeout: socketDialTimeout}
+	var netConn net.Conn
+	var err error
+	switch wsConfig.Location.Scheme {
+	case "wss":
+		if wsConfig.Location.Port() == "" {
+			addr = net.JoinHostPort(addr, "443")
+		}
+		netConn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", addr)
+	case "ws":
+		if wsConfig.Location.Port() == "" {
+			addr = net.JoinHostPort(addr, "80")
+		}
+		netConn, err = dialer.DialContext(ctx, "tcp", addr)
+	default:
+		return nil, fmt.Errorf("unsupported scheme: %s", wsConfig.Location.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The deadline also bounds the handshake
+	netConn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	ws, err := websocket.NewClient(wsConfig, &deadlineConn{Conn: netConn, timeout: c.readTimeout})
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Get the WebSocket URL of a new connection with apps.connections.open
+func (c *SocketModeClient) openConnection(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.openURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create apps.connections.open request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+c.appToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send apps.connections.open request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("apps.connections.open returned status: %s", resp.Status)
+	}
+
+	var respBody struct {
+		Ok    bool   `json:"ok"`
+		URL   string `json:"url"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return "", fmt.Errorf("failed to decode apps.connections.open response: %w", err)
+	}
+	if !respBody.Ok {
+		return "", fmt.Errorf("apps.connections.open error: %s", respBody.Error)
+	}
+	return respBody.URL, nil
+}
+
+// Serve an envelope as the HTTP request Slack would send, and acknowledge it with the response
+func (c *SocketModeClient) handleEnvelope(conn *socketConn, env socketEnvelope) {
+	ack := socketAck{EnvelopeID: env.EnvelopeID}
+
+	req, err := envelopeRequest(env)
+	if err != nil {
+		// Acknowledge anyway, a redelivery would fail the same way
+		log.Printf("failed to convert Socket Mode envelope %s: %v", env.EnvelopeID, err)
+	} else {
+		w := &ackWriter{header: http.Header{}, status: http.StatusOK}
+		c.handler.ServeHTTP(w, req)
+		if w.status >= 500 {
+			// Leave it unacknowledged, so that Slack redelivers it
+			log.Printf("Socket Mode envelope %s failed with status %d", env.EnvelopeID, w.status)
+			return
+		}
+		body := bytes.TrimSpace(w.body.Bytes())
+		if env.AcceptsResponsePayload && w.status == http.StatusOK && len(body) > 0 && json.Valid(body) {
+			ack.Payload = body
+		}
+	}
+
+	if err := conn.send(ack); err != nil {
+		log.Printf("failed to acknowledge Socket Mode envelope %s: %v", env.EnvelopeID, err)
+	}
+}
+
+// Build the HTTP request of an envelope, as sent to the Events API, interactivity, or slash command URL
+func envelopeRequest(env socketEnvelope) (*http.Request, error) {
+	switch env.Type {
+	case "events_api":
+		req, err := http.NewRequest("POST", "/slack/events", bytes.NewReader(env.Payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if env.RetryAttempt > 0 {
+			req.Header.Set("X-Slack-Retry-Num", strconv.Itoa(env.RetryAttempt))
+			req.Header.Set("X-Slack-Retry-Reason", env.RetryReason)
+		}
+		return req, nil
+
+	case "interactive":
+		form := url.Values{}
+		form.Set("payload", string(env.Payload))
+		return formRequest("/slack/interact", form)
+
+	case "slash_commands":
+		var fields map[string]any
+		if err := json.Unmarshal(env.Payload, &fields); err != nil {
+			return nil, fmt.Errorf("invalid slash command payload: %w", err)
+		}
+		form := url.Values{}
+		for k, v := range fields {
+			if s, ok := v.(string); ok {
+				form.Set(k, s)
+			}
+		}
+		return formRequest("/slack/commands", form)
+	}
+	return nil, fmt.Errorf("unsupported envelope type: %s", env.Type)
+}
+
+func formRequest(path string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// A response writer capturing the response to an envelope
+type ackWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *ackWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *ackWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *ackWriter) WriteHeader(status int) {
+	w.status = status
+}
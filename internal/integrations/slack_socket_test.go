@@ -0,0 +1,187 @@
+// File: internal/integrations/slack_socket_test.go
+
+// This file contains tests of the Socket Mode client against a local stand-in for Slack, which serves
+// apps.connections.open and the WebSocket it points to.
+
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const testAppToken = "xapp-test"
+
+// Start a stand-in for Slack whose WebSocket connections are served by serve, returning the URL of
+// apps.connections.open and the number of connections opened
+func startSocketStandIn(t *testing.T, serve func(ws *websocket.Conn)) (string, *atomic.Int32) {
+	t.Helper()
+	opens := &atomic.Int32{}
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/api/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Authorization") != "Bearer "+testAppToken {
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": "invalid_auth"})
+			return
+		}
+		opens.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"ok":  true,
+			"url": "ws" + strings.TrimPrefix(srv.URL, "http") + "/link",
+		})
+	})
+	mux.Handle("/link", websocket.Handler(serve))
+	return srv.URL + "/api/apps.connections.open", opens
+}
+
+// Run a client until the test ends, checking that it stops when its context is done
+func runSocketClient(t *testing.T, client *SocketModeClient) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- client.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-stopped:
+			if err != context.Canceled {
+				t.Errorf("Run returned %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Run did not return after the context was done")
+		}
+	})
+}
+
+func TestSocketModeEnvelopes(t *testing.T) {
+	type served struct {
+		path string
+		body string
+	}
+	var mu sync.Mutex
+	var requests []served
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, served{path: r.URL.Path, body: string(body)})
+		mu.Unlock()
+		if r.URL.Path == "/slack/commands" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"text":"Pong"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	acks := make(chan socketAck, 10)
+	openURL, _ := startSocketStandIn(t, func(ws *websocket.Conn) {
+		envelopes := []string{
+			`{"type": "hello"}`,
+			`{"envelope_id": "e1", "type": "events_api", "payload": {"type": "event_callback", "event_id": "Ev1"}}`,
+			`{"envelope_id": "e2", "type": "slash_commands", "accepts_response_payload": true, "payload": {"command": "/twothumbs", "text": "ping"}}`,
+			`{"envelope_id": "e3", "type": "interactive", "payload": {"type": "block_actions"}}`,
+		}
+		for _, env := range envelopes {
+			if err := websocket.Message.Send(ws, env); err != nil {
+				return
+			}
+		}
+		for {
+			var ack socketAck
+			if err := websocket.JSON.Receive(ws, &ack); err != nil {
+				return
+			}
+			acks <- ack
+		}
+	})
+
+	runSocketClient(t, NewSocketModeClient(testAppToken, openURL, handler))
+
+	got := make(map[string]string)
+	for len(got) < 3 {
+		select {
+		case ack := <-acks:
+			got[ack.EnvelopeID] = string(ack.Payload)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for acknowledgements, got %v", got)
+		}
+	}
+
+	tests := []struct {
+		envelopeID string
+		path       string
+		body       string
+		ackPayload string
+	}{
+		{"e1", "/slack/events", `"event_id": "Ev1"`, ""},
+		{"e2", "/slack/commands", "text=ping", `{"text":"Pong"}`},
+		{"e3", "/slack/interact", "payload=%7B%22type%22", ""},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, tt := range tests {
+		if payload := got[tt.envelopeID]; payload != tt.ackPayload {
+			t.Errorf("envelope %s acknowledged with payload %q, want %q", tt.envelopeID, payload, tt.ackPayload)
+		}
+		found := false
+		for _, r := range requests {
+			if r.path == tt.path && strings.Contains(r.body, tt.body) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("envelope %s was not served as POST %s with a body containing %q, got %v", tt.envelopeID, tt.path, tt.body, requests)
+		}
+	}
+}
+
+func TestSocketModeReadTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		readPings bool // reading lets the stand-in answer the pings of the client
+		keepsConn bool // the first connection is kept
+	}{
+		{"pongs keep the connection", true, true},
+		{"silent connection is reopened", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openURL, opens := startSocketStandIn(t, func(ws *websocket.Conn) {
+				websocket.Message.Send(ws, `{"type": "hello"}`)
+				if !tt.readPings {
+					time.Sleep(5 * time.Second)
+					return
+				}
+				for {
+					var msg string
+					if err := websocket.Message.Receive(ws, &msg); err != nil {
+						return
+					}
+				}
+			})
+
+			client := NewSocketModeClient(testAppToken, openURL, http.NotFoundHandler())
+			client.pingInterval = 50 * time.Millisecond
+			client.readTimeout = 250 * time.Millisecond
+			runSocketClient(t, client)
+
+			// Long enough for a timeout and the minimum backoff before reconnecting
+			time.Sleep(socketMinBackoff + 750*time.Millisecond)
+			if n := opens.Load(); (n == 1) != tt.keepsConn {
+				t.Errorf("opened %d connections", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// File: internal/integrations/slack_tokens.go
+
+// This file contains the handling of Slack bot tokens, including Slack's token rotation.
+// With token rotation, bot tokens expire after twelve hours and are exchanged for new ones
+// using the refresh token. Tokens are refreshed shortly before they expire, and once more
+// if Slack still reports them as expired.
+
+package integrations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"twothumbs/internal/models"
+	"twothumbs/internal/queries"
+)
+
+const (
+	tokenRefreshMargin  = 5 * time.Minute  // refresh tokens expiring within this margin before using them
+	tokenRefreshTimeout = 10 * time.Second // the token row is locked while refreshing
+)
+
+// Returned by Slack API calls if the bot token has expired
+var ErrTokenExpired = errors.New("token_expired")
+
+var slackClientID, slackClientSecret string
+
+var tokenRefreshClient = &http.Client{Timeout: tokenRefreshTimeout}
+
+// Set the Slack app credentials used to refresh bot tokens
+func SetSlackAppCredentials(clientID, clientSecret string) {
+	slackClientID = clientID
+	slackClientSecret = clientSecret
+}
+
+// Get the bot token of a workspace, refreshing it first if it is about to expire
+func GetBotToken(conn *sql.DB, workspace string) (string, error) {
+	tokens, err := queries.GetSlackTokens(conn, workspace)
+	if err != nil {
+		return "", err
+	}
+	if !expiresSoon(tokens) {
+		return tokens.AccessToken, nil
+	}
+	return RefreshBotToken(conn, workspace, tokens.AccessToken)
+}
+
+// Refresh the bot token of a workspace, unless another caller already replaced the given token
+func RefreshBotToken(conn *sql.DB, workspace, oldToken string) (string, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	tokens, err := queries.LockSlackTokens(tx, workspace)
+	if err != nil {
+		return "", err
+	}
+	if tokens.AccessToken != oldToken && !expiresSoon(tokens) {
+		return tokens.AccessToken, tx.Commit()
+	}
+	if tokens.RefreshToken == "" {
+		return "", fmt.Errorf("bot token of workspace %s expired and cannot be refreshed without token rotation", workspace)
+	}
+
+	resp, err := refreshSlackToken(tokens.RefreshToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh bot token of workspace %s: %w", workspace, err)
+	}
+	newTokens := &models.SlackTokens{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+	}
+	if newTokens.RefreshToken == "" {
+		newTokens.RefreshToken = tokens.RefreshToken
+	}
+	if resp.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+		newTokens.ExpiresAt = &expiresAt
+	}
+	if err := queries.UpdateSlackTokens(tx, workspace, newTokens); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return newTokens.AccessToken, nil
+}
+
+// Call a function with the bot token of a workspace, retrying once with a refreshed token if Slack
+// reports the token as expired
+func WithBotToken(conn *sql.DB, workspace string, fn func(botToken string) error) error {
+	botToken, err := GetBotToken(conn, workspace)
+	if err != nil {
+		return fmt.Errorf("no bot token found for workspace %s: %w", workspace, err)
+	}
+	err = fn(botToken)
+	if !errors.Is(err, ErrTokenExpired) {
+		return err
+	}
+	botToken, err = RefreshBotToken(conn, workspace, botToken)
+	if err != nil {
+		return err
+	}
+	return fn(botToken)
+}
+
+func expiresSoon(tokens *models.SlackTokens) bool {
+	return tokens.ExpiresAt != nil && time.Until(*tokens.ExpiresAt) < tokenRefreshMargin
+}
+
+// Exchange a refresh token for a new bot token
+func refreshSlackToken(refreshToken string) (*models.SlackOAuthResponse, error) {
+	form := url.Values{}
+	form.Add("grant_type", "refresh_token")
+	form.Add("refresh_token", refreshToken)
+	form.Add("client_id", slackClientID)
+	form.Add("client_secret", slackClientSecret)
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://slack.com/api/oauth.v2.access", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := tokenRefreshClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var oauthResp models.SlackOAuthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&oauthResp); err != nil {
+		return nil, err
+	}
+	if !oauthResp.OK {
+		return nil, errors.New("slack oauth error: " + oauthResp.Error)
+	}
+	return &oauthResp, nil
+}
+
+// Build the error of a failed Slack API call, wrapping ErrTokenExpired if the token has expired
+func slackAPIError(prefix string, code any, metaMsg string) error {
+	if code == ErrTokenExpired.Error() {
+		return fmt.Errorf("%s: %w.%s", prefix, ErrTokenExpired, metaMsg)
+	}
+	return fmt.Errorf("%s: %v.%s", prefix, code, metaMsg)
+}
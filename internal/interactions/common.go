@@ -64,9 +64,9 @@ func HandleViewInteraction(c *gin.Context, payload map[string]any, conn *sql.DB,
 	case "home-prompts":
 		err = HandleTabPrompts(ctx, conn, cfg)
 	case "home-settings":
-		err = handleTabSettings(ctx, conn)
+		err = handleTabSettings(ctx, conn, cfg)
 	case "home-support":
-		err = integrations.OpenSlackModal(ctx.TriggerID, modals.SupportModal(), ctx.BotToken)
+		err = openModal(ctx, conn, modals.SupportModal())
 
 	// Home / quick actions
 	case "home-comments":
@@ -79,7 +79,8 @@ func HandleViewInteraction(c *gin.Context, payload map[string]any, conn *sql.DB,
 		err = handleStats("30-Day Stats", models.Last30d, queries.GetLast30DayStats, false)(ctx, conn, cfg)
 
 	// Home / filter actions
-	case "select-origin", "select-category", "select-prompt", "select-thumb", "stats-date-from", "stats-date-to":
+	case "select-origin", "select-category", "select-prompt", "select-thumb", "stats-date-from", "stats-date-to",
+		"select-meta-key", "select-meta-value", "select-group-by":
 		err = handleStatsFilterAction(ctx, conn, payload)
 	case "clear-origin":
 		err = handleClearFilter(ctx, conn, payload, actionID)
@@ -91,6 +92,10 @@ func HandleViewInteraction(c *gin.Context, payload map[string]any, conn *sql.DB,
 		err = handleClearFilter(ctx, conn, payload, actionID)
 	case "clear-dates":
 		err = handleClearFilter(ctx, conn, payload, actionID)
+	case "clear-meta":
+		err = handleClearFilter(ctx, conn, payload, actionID)
+	case "clear-group-by":
+		err = handleClearFilter(ctx, conn, payload, actionID)
 
 	// Home / query actions
 	case "view-stats":
@@ -101,6 +106,8 @@ func HandleViewInteraction(c *gin.Context, payload map[string]any, conn *sql.DB,
 		err = handleRawDataViewAction(ctx, conn, payload)
 
 	// Home / prompt actions
+	case "toggle-live-prompt":
+		err = handleToggleLivePrompt(ctx, conn, cfg, payload)
 	case "modal-delete-prompt":
 		err = handleModalDeletePrompt(ctx, conn, payload)
 
@@ -116,17 +123,25 @@ func HandleViewInteraction(c *gin.Context, payload map[string]any, conn *sql.DB,
 
 	// Settings actions
 	case "set-channel":
-		err = handleSetChannel(ctx, conn, payload)
+		err = handleSetChannel(ctx, conn, cfg, payload)
 	case "clear-channel":
-		err = handleClearChannel(ctx, conn)
+		err = handleClearChannel(ctx, conn, cfg)
 	case "rotate-api-key":
-		err = handleRotateApiKey(ctx, conn)
+		err = handleRotateApiKey(ctx, conn, cfg, payload)
+	case "revoke-api-key":
+		err = handleRevokeApiKey(ctx, conn, cfg, payload)
+	case "create-api-key":
+		err = openModal(ctx, conn, modals.CreateAPIKeyModal())
+	case "delete-webhook":
+		err = handleDeleteWebhook(ctx, conn, cfg, payload)
+	case "create-webhook":
+		err = openModal(ctx, conn, modals.CreateWebhookModal())
 	case "view-test-data":
 		err = handleViewTestData(ctx, conn)
 
 	// Link workspace action
 	case "link-workspace":
-		handleLinkWorkspace(ctx, c, payload, conn)
+		handleLinkWorkspace(ctx, c, payload, conn, cfg)
 		return
 
 	default:
@@ -153,16 +168,6 @@ func ExtractInteractionContext(payload map[string]any, conn *sql.DB) (*models.In
 		return nil, fmt.Errorf("missing or invalid trigger_id")
 	}
 
-	// Extract workspace ID
-	team, ok := payload["team"].(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("missing or invalid team in payload")
-	}
-	workspace, ok := team["id"].(string)
-	if !ok || workspace == "" {
-		return nil, fmt.Errorf("missing or invalid workspace ID")
-	}
-
 	// Extract user_id
 	user, ok := payload["user"].(map[string]any)
 	if !ok {
@@ -173,20 +178,64 @@ func ExtractInteractionContext(payload map[string]any, conn *sql.DB) (*models.In
 		return nil, fmt.Errorf("missing or invalid user ID")
 	}
 
-	// Fetch bot token for the workspace
-	botToken, err := queries.GetBotTokenForWorkspace(conn, workspace)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch bot token for workspace %s: %w", workspace, err)
+	// Extract workspace ID, which org-wide installations may only give as the team of the user
+	var workspace string
+	if team, ok := payload["team"].(map[string]any); ok {
+		workspace, _ = team["id"].(string)
+	}
+	if workspace == "" {
+		workspace, _ = user["team_id"].(string)
+	}
+	if workspace == "" {
+		return nil, fmt.Errorf("missing or invalid workspace ID")
+	}
+
+	// Interactions of org-wide installations tell which workspace of the enterprise they come from
+	if isEnterpriseInstall, _ := payload["is_enterprise_install"].(bool); isEnterpriseInstall {
+		if enterprise, ok := payload["enterprise"].(map[string]any); ok {
+			if enterpriseID, _ := enterprise["id"].(string); enterpriseID != "" {
+				if err := queries.SaveEnterpriseWorkspaces(conn, enterpriseID, []string{workspace}); err != nil {
+					log.Printf("failed to save workspace %s of enterprise %s: %v", workspace, enterpriseID, err)
+				}
+			}
+		}
 	}
 
 	return &models.InteractionContext{
 		TriggerID: triggerID,
 		Workspace: workspace,
-		BotToken:  botToken,
 		UserID:    userID,
 	}, nil
 }
 
+// Call Slack with the bot token of the workspace of an interaction, retrying once with a refreshed token
+// if Slack reports the token as expired
+func withBotToken(ctx *models.InteractionContext, conn *sql.DB, fn func(botToken string) error) error {
+	return integrations.WithBotToken(conn, ctx.Workspace, fn)
+}
+
+// Publish the App Home view of the user of an interaction
+func publishHomeView(ctx *models.InteractionContext, conn *sql.DB, blocks []map[string]any) error {
+	return withBotToken(ctx, conn, func(botToken string) error {
+		return PublishHomeView(botToken, ctx.UserID, blocks)
+	})
+}
+
+// Open a modal in response to an interaction
+func openModal(ctx *models.InteractionContext, conn *sql.DB, modal map[string]any) error {
+	return showModal(ctx, conn, modal, false)
+}
+
+// Push a modal onto the open modal of an interaction if push is set, or open it otherwise
+func showModal(ctx *models.InteractionContext, conn *sql.DB, modal map[string]any, push bool) error {
+	return withBotToken(ctx, conn, func(botToken string) error {
+		if push {
+			return integrations.PushSlackView(ctx.TriggerID, modal, botToken)
+		}
+		return integrations.OpenSlackModal(ctx.TriggerID, modal, botToken)
+	})
+}
+
 // Publish the App Home view
 func PublishHomeView(botToken, userID string, blocks []map[string]any) error {
 	view := map[string]any{
@@ -222,6 +271,9 @@ func PublishHomeView(botToken, userID string, blocks []map[string]any) error {
 		return fmt.Errorf("failed to decode Slack response: %w", err)
 	}
 	if ok, exists := respBody["ok"].(bool); !exists || !ok {
+		if respBody["error"] == integrations.ErrTokenExpired.Error() {
+			return fmt.Errorf("slack API error: %w", integrations.ErrTokenExpired)
+		}
 		return fmt.Errorf("slack API error: %v", respBody)
 	}
 
@@ -243,16 +295,14 @@ func FetchStats(conn *sql.DB, workspace string, groups []models.FeedbackGroup, f
 				workspace, group.Origin, group.Category, group.Prompt)
 			continue
 		}
-		scoreDelta := "-"
-		if stat.PrevNFeedback > 0 {
-			scoreDelta = utils.FormatDelta(stat.ThumbsUpPct - stat.PrevThumbsUpPct)
-		}
+		score := utils.ScoreFromStats(stat)
 		stats = append(stats, models.StatsData{
 			Origin:          group.Origin,
 			Category:        group.Category,
 			Prompt:          group.Prompt,
-			Score:           utils.RoundFloat(stat.ThumbsUpPct),
-			ScoreDelta:      scoreDelta,
+			Scale:           stat.Scale,
+			MeanRating:      stat.MeanRating,
+			Score:           score,
 			NResponses:      stat.NFeedback,
 			NResponsesDelta: utils.FormatDelta(stat.PrevNFeedbackDl),
 			NComments:       stat.NComments,
@@ -276,12 +326,15 @@ func FetchStatsWithRange(
 	workspace string,
 	groups []models.FeedbackGroup,
 	from, to, prevFrom, prevTo time.Time,
-	thumb string,
-	fetchFunc func(*sql.DB, string, string, string, string, time.Time, time.Time, time.Time, time.Time, string) (*models.FeedbackStats, error),
+	thumb, metaKey, metaVal, groupBy string,
+	fetchFunc func(*sql.DB, string, string, string, string, time.Time, time.Time, time.Time, time.Time, string, string, string, string, string) (*models.FeedbackStats, error),
 ) []models.StatsData {
 	var stats []models.StatsData
 	for _, group := range groups {
-		stat, err := fetchFunc(conn, workspace, group.Origin, group.Category, group.Prompt, from, to, prevFrom, prevTo, thumb)
+		stat, err := fetchFunc(
+			conn, workspace, group.Origin, group.Category, group.Prompt, from, to, prevFrom, prevTo,
+			thumb, metaKey, metaVal, groupBy, group.Dimension,
+		)
 		if err != nil {
 			log.Printf("failed to get stats for workspace %s, origin %s, category %s, prompt %s: %v",
 				workspace, group.Origin, group.Category, group.Prompt, err)
@@ -292,16 +345,19 @@ func FetchStatsWithRange(
 				workspace, group.Origin, group.Category, group.Prompt)
 			continue
 		}
-		scoreDelta := "-"
-		if stat.PrevNFeedback > 0 {
-			scoreDelta = utils.FormatDelta(stat.ThumbsUpPct - stat.PrevThumbsUpPct)
+		score := utils.ScoreFromStats(stat)
+		dimension := ""
+		if groupBy != "" {
+			dimension = metadataLabel(groupBy, group.Dimension)
 		}
 		stats = append(stats, models.StatsData{
 			Origin:          group.Origin,
 			Category:        group.Category,
 			Prompt:          group.Prompt,
-			Score:           utils.RoundFloat(stat.ThumbsUpPct),
-			ScoreDelta:      scoreDelta,
+			Dimension:       dimension,
+			Scale:           stat.Scale,
+			MeanRating:      stat.MeanRating,
+			Score:           score,
 			NResponses:      stat.NFeedback,
 			NResponsesDelta: utils.FormatDelta(stat.PrevNFeedbackDl),
 			NComments:       stat.NComments,
@@ -309,13 +365,27 @@ func FetchStatsWithRange(
 		})
 	}
 
-	// Sort stats by origin and category
+	// Sort stats by origin, category, prompt, and dimension
 	sort.Slice(stats, func(i, j int) bool {
 		if stats[i].Origin != stats[j].Origin {
 			return stats[i].Origin < stats[j].Origin
 		}
-		return stats[i].Category < stats[j].Category
+		if stats[i].Category != stats[j].Category {
+			return stats[i].Category < stats[j].Category
+		}
+		if stats[i].Prompt != stats[j].Prompt {
+			return stats[i].Prompt < stats[j].Prompt
+		}
+		return stats[i].Dimension < stats[j].Dimension
 	})
 
 	return stats
 }
+
+// Label a metadata dimension as "key: value", marking feedback without the key
+func metadataLabel(key, value string) string {
+	if value == "" {
+		value = "(none)"
+	}
+	return fmt.Sprintf("%s: %s", key, value)
+}
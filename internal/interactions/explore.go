@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -48,6 +49,11 @@ func HandleTabExplore(ctx *models.InteractionContext, conn *sql.DB, expanded boo
 	to := utils.ParseDate(values.DateTo)
 	dateInitial := utils.IsDefaultDateRange(from, to)
 
+	metaKeys, metaVals, err := loadMetadataOptions(conn, ctx.Workspace, from, to.AddDate(0, 0, 1), &values)
+	if err != nil {
+		return err
+	}
+
 	origins, categories, prompts, feedbackCount, commentCount, err := queries.GetHomeTabData(
 		conn, ctx.Workspace,
 		from, to.AddDate(0, 0, 1), // adding one day to get data for the full day
@@ -55,6 +61,8 @@ func HandleTabExplore(ctx *models.InteractionContext, conn *sql.DB, expanded boo
 		values.Category,
 		values.Prompt,
 		values.Thumb,
+		values.MetaKey,
+		values.MetaVal,
 	)
 	if err != nil {
 		return err
@@ -95,10 +103,15 @@ func HandleTabExplore(ctx *models.InteractionContext, conn *sql.DB, expanded boo
 		selectedCategory,
 		selectedPrompt,
 		selectedThumb,
+		metaKeys,
+		metaVals,
+		values.MetaKey,
+		values.MetaVal,
+		values.GroupBy,
 		expanded,
 	)
 
-	return PublishHomeView(ctx.BotToken, ctx.UserID, blocks)
+	return publishHomeView(ctx, conn, blocks)
 }
 
 func handleStatsFilterAction(ctx *models.InteractionContext, conn *sql.DB, payload map[string]any) error {
@@ -115,6 +128,11 @@ func handleStatsFilterAction(ctx *models.InteractionContext, conn *sql.DB, paylo
 	to := utils.ParseDate(values.DateTo)
 	dateInitial := utils.IsDefaultDateRange(from, to)
 
+	metaKeys, metaVals, err := loadMetadataOptions(conn, ctx.Workspace, from, to.AddDate(0, 0, 1), &values)
+	if err != nil {
+		return err
+	}
+
 	originsOut, categoriesOut, promptsOut, feedbackCount, commentCount, err := queries.GetHomeTabData(
 		conn, ctx.Workspace,
 		from, to.AddDate(0, 0, 1),
@@ -122,6 +140,8 @@ func handleStatsFilterAction(ctx *models.InteractionContext, conn *sql.DB, paylo
 		values.Category,
 		values.Prompt,
 		values.Thumb,
+		values.MetaKey,
+		values.MetaVal,
 	)
 	if err != nil {
 		return err
@@ -162,10 +182,42 @@ func handleStatsFilterAction(ctx *models.InteractionContext, conn *sql.DB, paylo
 		selectedCategory,
 		selectedPrompt,
 		selectedThumb,
+		metaKeys,
+		metaVals,
+		values.MetaKey,
+		values.MetaVal,
+		values.GroupBy,
 		true,
 	)
 
-	return PublishHomeView(ctx.BotToken, ctx.UserID, blocks)
+	return publishHomeView(ctx, conn, blocks)
+}
+
+// Load the metadata keys and values for the filters, resetting any metadata selection that is no longer valid
+func loadMetadataOptions(conn *sql.DB, workspace string, from, to time.Time, values *models.StatsFilterValues) ([]string, []string, error) {
+	keys, err := queries.GetMetadataKeys(conn, workspace, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !slices.Contains(keys, values.MetaKey) {
+		values.MetaKey = ""
+		values.MetaVal = ""
+	}
+	if !slices.Contains(keys, values.GroupBy) {
+		values.GroupBy = ""
+	}
+
+	var vals []string
+	if values.MetaKey != "" {
+		vals, err = queries.GetMetadataValues(conn, workspace, from, to, values.MetaKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !slices.Contains(vals, values.MetaVal) {
+			values.MetaVal = ""
+		}
+	}
+	return keys, vals, nil
 }
 
 func extractStatsFilterValues(payload map[string]any) models.StatsFilterValues {
@@ -210,6 +262,24 @@ func extractStatsFilterValues(payload map[string]any) models.StatsFilterValues {
 						values.Thumb = v
 					}
 				}
+			case "select-meta-key":
+				if sel, ok := actionMap["selected_option"].(map[string]any); ok && sel != nil {
+					if v, ok := sel["value"].(string); ok {
+						values.MetaKey = v
+					}
+				}
+			case "select-meta-value":
+				if sel, ok := actionMap["selected_option"].(map[string]any); ok && sel != nil {
+					if v, ok := sel["value"].(string); ok {
+						values.MetaVal = v
+					}
+				}
+			case "select-group-by":
+				if sel, ok := actionMap["selected_option"].(map[string]any); ok && sel != nil {
+					if v, ok := sel["value"].(string); ok {
+						values.GroupBy = v
+					}
+				}
 			case "stats-date-from":
 				if v, ok := actionMap["selected_date"].(string); ok {
 					values.DateFrom = v
@@ -236,6 +306,11 @@ func handleClearFilter(ctx *models.InteractionContext, conn *sql.DB, payload map
 		values.Prompt = ""
 	case "clear-thumb":
 		values.Thumb = ""
+	case "clear-meta":
+		values.MetaKey = ""
+		values.MetaVal = ""
+	case "clear-group-by":
+		values.GroupBy = ""
 	case "clear-dates":
 		values.DateFrom = time.Now().UTC().AddDate(0, 0, -30).Format("2006-01-02")
 		values.DateTo = time.Now().UTC().Format("2006-01-02")
@@ -245,6 +320,11 @@ func handleClearFilter(ctx *models.InteractionContext, conn *sql.DB, payload map
 	to := utils.ParseDate(values.DateTo)
 	dateInitial := utils.IsDefaultDateRange(from, to)
 
+	metaKeys, metaVals, err := loadMetadataOptions(conn, ctx.Workspace, from, to.AddDate(0, 0, 1), &values)
+	if err != nil {
+		return err
+	}
+
 	origins, categories, prompts, feedbackCount, commentCount, err := queries.GetHomeTabData(
 		conn, ctx.Workspace,
 		from, to.AddDate(0, 0, 1),
@@ -252,6 +332,8 @@ func handleClearFilter(ctx *models.InteractionContext, conn *sql.DB, payload map
 		values.Category,
 		values.Prompt,
 		values.Thumb,
+		values.MetaKey,
+		values.MetaVal,
 	)
 	if err != nil {
 		return err
@@ -292,10 +374,15 @@ func handleClearFilter(ctx *models.InteractionContext, conn *sql.DB, payload map
 		selectedCategory,
 		selectedPrompt,
 		selectedThumb,
+		metaKeys,
+		metaVals,
+		values.MetaKey,
+		values.MetaVal,
+		values.GroupBy,
 		true,
 	)
 
-	return PublishHomeView(ctx.BotToken, ctx.UserID, blocks)
+	return publishHomeView(ctx, conn, blocks)
 }
 
 func handleStatsViewAction(ctx *models.InteractionContext, conn *sql.DB, payload map[string]any) error {
@@ -314,17 +401,22 @@ func handleStatsViewAction(ctx *models.InteractionContext, conn *sql.DB, payload
 		values.Category,
 		values.Prompt,
 		values.Thumb,
+		values.MetaKey,
+		values.MetaVal,
+		values.GroupBy,
 	)
 	if err != nil {
 		return err
 	}
 
 	stats := FetchStatsWithRange(
-		conn, ctx.Workspace, groups, from, to.AddDate(0, 0, 1), prevFrom, prevTo, values.Thumb, queries.GetFilteredStats,
+		conn, ctx.Workspace, groups, from, to.AddDate(0, 0, 1), prevFrom, prevTo,
+		values.Thumb, values.MetaKey, values.MetaVal, values.GroupBy,
+		queries.GetFilteredStats,
 	)
 
 	modal := modals.StatsModal("Stats 📊", stats)
-	return integrations.OpenSlackModal(ctx.TriggerID, modal, ctx.BotToken)
+	return openModal(ctx, conn, modal)
 }
 
 func handleCommentsViewAction(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig, payload map[string]any) error {
@@ -339,14 +431,27 @@ func handleCommentsViewAction(ctx *models.InteractionContext, conn *sql.DB, cfg
 		values.Category,
 		values.Prompt,
 		values.Thumb,
+		values.MetaKey,
+		values.MetaVal,
+		values.GroupBy,
 		cfg.NComments,
 	)
 	if err != nil {
 		return err
 	}
 
+	// Keep the most recent comments, but list them by dimension when grouping
+	if values.GroupBy != "" {
+		for i := range results {
+			results[i].Dimension = metadataLabel(values.GroupBy, results[i].Dimension)
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Dimension < results[j].Dimension
+		})
+	}
+
 	modal := modals.ExploreCommentsModal("Comments  💬", cfg, results)
-	return integrations.OpenSlackModal(ctx.TriggerID, modal, ctx.BotToken)
+	return openModal(ctx, conn, modal)
 }
 
 func handleRawDataViewAction(ctx *models.InteractionContext, conn *sql.DB, payload map[string]any) error {
@@ -362,6 +467,9 @@ func handleRawDataViewAction(ctx *models.InteractionContext, conn *sql.DB, paylo
 		values.Category,
 		values.Prompt,
 		values.Thumb,
+		values.MetaKey,
+		values.MetaVal,
+		0, 0, // all rows
 	)
 	if err != nil {
 		return err
@@ -385,26 +493,23 @@ func handleRawDataViewAction(ctx *models.InteractionContext, conn *sql.DB, paylo
 	}
 	tmpFile.Close()
 
-	// Upload to Slack (send as DM to user)
-	appHomeChannel, err := integrations.GetAppHomeChannelID(ctx.BotToken, ctx.UserID)
-	if err != nil {
-		return err
-	}
-
 	msg := fmt.Sprintf(
 		"Attached is your feedback export from %s to %s.",
 		from.Format("2006-01-02"),
 		to.Format("2006-01-02"),
 	)
 
-	_, err = integrations.UploadFileToSlack(
-		ctx.BotToken, appHomeChannel, tmpFile.Name(),
-		"Feedback Export",
-		msg,
-	)
-	if err != nil {
+	// Upload to Slack (send as DM to user)
+	return withBotToken(ctx, conn, func(botToken string) error {
+		appHomeChannel, err := integrations.GetAppHomeChannelID(botToken, ctx.UserID)
+		if err != nil {
+			return err
+		}
+		_, err = integrations.UploadFileToSlack(
+			botToken, appHomeChannel, tmpFile.Name(),
+			"Feedback Export",
+			msg,
+		)
 		return err
-	}
-
-	return nil
+	})
 }
@@ -0,0 +1,58 @@
+// File: internal/interactions/handler.go
+
+// This file contains the entry point for Slack interactions, which dispatches each payload
+// to the handler of its interaction type.
+
+package interactions
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"twothumbs/internal/config"
+)
+
+// Interaction handler for Slack interactions
+func SlackInteractHandler(cfg *config.InteractConfig, conn *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload map[string]any
+		if err := json.Unmarshal([]byte(c.PostForm("payload")), &payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			log.Printf("failed to unmarshal payload: %v", err)
+			return
+		}
+
+		// Detect interaction type
+		switch payload["type"] {
+		case "view_submission":
+			// Modal submission
+			log.Printf("processing a modal submission")
+			HandleModalSubmission(c, payload, conn, cfg)
+			return
+
+		case "block_actions":
+			// Detect source from container type
+			container, ok := payload["container"].(map[string]any)
+			if ok {
+				switch container["type"] {
+				case "view":
+					log.Printf("processing a view interaction")
+					HandleViewInteraction(c, payload, conn, cfg)
+					return
+				case "message":
+					log.Printf("processing a message interaction")
+					HandleMessageInteraction(c, payload, conn, cfg)
+					return
+				}
+			}
+		}
+
+		// Unsupported payload
+		log.Printf("unsupported payload: %v", payload)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported payload"})
+	}
+}
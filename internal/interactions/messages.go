@@ -13,7 +13,6 @@ import (
 
 	"twothumbs/internal/config"
 	"twothumbs/internal/integrations"
-	"twothumbs/internal/queries"
 	"twothumbs/internal/templates/modals"
 )
 
@@ -44,7 +43,7 @@ func HandleMessageInteraction(c *gin.Context, payload map[string]any, conn *sql.
 		return
 	}
 
-	// Extract workspace and bot token
+	// Extract workspace
 	team, ok := payload["team"].(map[string]any)
 	if !ok {
 		log.Printf("missing or invalid team in payload: %v", payload)
@@ -53,13 +52,6 @@ func HandleMessageInteraction(c *gin.Context, payload map[string]any, conn *sql.
 	}
 	workspace := team["id"].(string)
 
-	botToken, err := queries.GetBotTokenForWorkspace(conn, workspace)
-	if err != nil || botToken == "" {
-		log.Printf("could not find bot token for workspace %s: %v", workspace, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not find bot token for workspace"})
-		return
-	}
-
 	// Dispatch to the appropriate handler
 	var modal map[string]any
 	switch actionID {
@@ -74,7 +66,9 @@ func HandleMessageInteraction(c *gin.Context, payload map[string]any, conn *sql.
 	}
 
 	// Open the modal using Slack's views.open API
-	err = integrations.OpenSlackModal(triggerID, modal, botToken)
+	err := integrations.WithBotToken(conn, workspace, func(botToken string) error {
+		return integrations.OpenSlackModal(triggerID, modal, botToken)
+	})
 	if err != nil {
 		log.Printf("failed to handle message action %s: %v", actionID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to handle action"})
@@ -22,7 +22,7 @@ import (
 // Display the "comments" modal with the latest comments
 func handleComments(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig, isModal bool) error {
 	// Fetch the latest comments
-	results, err := queries.GetLatestComments(conn, ctx.Workspace, cfg.NComments)
+	results, err := queries.GetLatestComments(conn, ctx.Workspace, "", cfg.NComments)
 	if err != nil {
 		log.Printf("failed to get latest comments for workspace %s: %v", ctx.Workspace, err)
 		return err
@@ -32,10 +32,7 @@ func handleComments(ctx *models.InteractionContext, conn *sql.DB, cfg *config.In
 	modal := modals.ExploreCommentsModal("Latest Comments  💬", cfg, results)
 
 	// Push or open the modal based on the isModal flag
-	if isModal {
-		return integrations.PushSlackView(ctx.TriggerID, modal, ctx.BotToken)
-	}
-	return integrations.OpenSlackModal(ctx.TriggerID, modal, ctx.BotToken)
+	return showModal(ctx, conn, modal, isModal)
 }
 
 // Push the "top issues" modal
@@ -51,10 +48,7 @@ func handleTopIssues(ctx *models.InteractionContext, conn *sql.DB, isModal bool)
 	modal := modals.TopIssuesModal(issues)
 
 	// Push or open the modal based on the isModal flag
-	if isModal {
-		return integrations.PushSlackView(ctx.TriggerID, modal, ctx.BotToken)
-	}
-	return integrations.OpenSlackModal(ctx.TriggerID, modal, ctx.BotToken)
+	return showModal(ctx, conn, modal, isModal)
 }
 
 // Generic handler for stats modals
@@ -79,10 +73,7 @@ func handleStats(
 		modal := modals.StatsModal(title, stats)
 
 		// Push or open the modal based on the isModal flag
-		if isModal {
-			return integrations.PushSlackView(ctx.TriggerID, modal, ctx.BotToken)
-		}
-		return integrations.OpenSlackModal(ctx.TriggerID, modal, ctx.BotToken)
+		return showModal(ctx, conn, modal, isModal)
 	}
 }
 
@@ -100,6 +91,10 @@ func HandleModalSubmission(c *gin.Context, payload map[string]any, conn *sql.DB,
 		handleContactSupportSubmission(c, payload, cfg)
 	case "delete-prompt":
 		handleDeletePromptSubmission(c, payload, conn, cfg)
+	case "create-api-key":
+		handleCreateApiKeySubmission(c, payload, conn, cfg)
+	case "create-webhook":
+		handleCreateWebhookSubmission(c, payload, conn, cfg)
 	default:
 		c.JSON(http.StatusOK, map[string]any{
 			"response_action": "clear",
@@ -217,6 +212,62 @@ func extractModalSubmissionData(payload map[string]any) map[string]string {
 	return result
 }
 
+// Extract the selected values of a checkboxes element from a modal submission payload
+func extractModalCheckboxValues(payload map[string]any, actionID string) []string {
+	var result []string
+
+	view, _ := payload["view"].(map[string]any)
+	state, _ := view["state"].(map[string]any)
+	values, _ := state["values"].(map[string]any)
+
+	for _, block := range values {
+		blockMap, ok := block.(map[string]any)
+		if !ok {
+			continue
+		}
+		actionMap, ok := blockMap[actionID].(map[string]any)
+		if !ok {
+			continue
+		}
+		options, _ := actionMap["selected_options"].([]any)
+		for _, opt := range options {
+			optMap, ok := opt.(map[string]any)
+			if !ok {
+				continue
+			}
+			if val, ok := optMap["value"].(string); ok {
+				result = append(result, val)
+			}
+		}
+	}
+
+	return result
+}
+
+// Extract the selected value of a radio buttons or select element from a modal submission payload
+func extractModalSelectedValue(payload map[string]any, actionID string) string {
+	view, _ := payload["view"].(map[string]any)
+	state, _ := view["state"].(map[string]any)
+	values, _ := state["values"].(map[string]any)
+
+	for _, block := range values {
+		blockMap, ok := block.(map[string]any)
+		if !ok {
+			continue
+		}
+		actionMap, ok := blockMap[actionID].(map[string]any)
+		if !ok {
+			continue
+		}
+		option, _ := actionMap["selected_option"].(map[string]any)
+		if val, ok := option["value"].(string); ok {
+			return val
+		}
+	}
+
+	return ""
+}
+
 // Update the current Slack modal using response_action "update"
 func updateSlackModal(c *gin.Context, modal map[string]any) {
 	c.JSON(http.StatusOK, map[string]any{
@@ -7,9 +7,10 @@ package interactions
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"twothumbs/internal/config"
-	"twothumbs/internal/integrations"
 	"twothumbs/internal/models"
 	"twothumbs/internal/queries"
 	"twothumbs/internal/templates/home"
@@ -24,7 +25,7 @@ func HandleTabPrompts(ctx *models.InteractionContext, conn *sql.DB, cfg *config.
 	}
 	blocks := home.PromptsBlocks(prompts, cfg.PromptCountLimit)
 
-	return PublishHomeView(ctx.BotToken, ctx.UserID, blocks)
+	return publishHomeView(ctx, conn, blocks)
 }
 
 // Handle the "modal-delete-prompt" action
@@ -44,13 +45,30 @@ func handleModalDeletePrompt(ctx *models.InteractionContext, conn *sql.DB, paylo
 	modal := modals.DeletePromptModal(prompt)
 
 	// Open the modal
-	if err := integrations.OpenSlackModal(ctx.TriggerID, modal, ctx.BotToken); err != nil {
+	if err := openModal(ctx, conn, modal); err != nil {
 		return fmt.Errorf("failed to open delete prompt modal: %w", err)
 	}
 
 	return nil
 }
 
+// Handle the "toggle-live-prompt" action, turning live alerts of a prompt on or off
+func handleToggleLivePrompt(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig, payload map[string]any) error {
+	val, err := extractActionValueFromPayload(payload)
+	if err != nil {
+		return err
+	}
+	id, state, _ := strings.Cut(val, ":")
+	promptID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || (state != "on" && state != "off") {
+		return fmt.Errorf("invalid live prompt value %q", val)
+	}
+	if err := queries.SetPromptLive(conn, ctx.Workspace, promptID, state == "on"); err != nil {
+		return fmt.Errorf("failed to set live alerts of prompt %d for workspace %s: %w", promptID, ctx.Workspace, err)
+	}
+	return HandleTabPrompts(ctx, conn, cfg)
+}
+
 // Helper to extract action value from the payload
 func extractActionValueFromPayload(payload map[string]any) (string, error) {
 	actions, ok := payload["actions"].([]any)
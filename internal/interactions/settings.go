@@ -9,10 +9,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
-	"twothumbs/internal/integrations"
+	"twothumbs/internal/config"
 	"twothumbs/internal/models"
 	"twothumbs/internal/queries"
 	"twothumbs/internal/templates/home"
@@ -21,17 +25,25 @@ import (
 )
 
 // Handle the "home-settings" action
-func handleTabSettings(ctx *models.InteractionContext, conn *sql.DB) error {
-	apiKey, channel, err := queries.GetAPIKeyAndChannel(conn, ctx.Workspace)
+func handleTabSettings(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig) error {
+	channel, err := queries.GetSlackChannel(conn, ctx.Workspace)
 	if err != nil {
-		return fmt.Errorf("failed to get api key and channel for workspace %s: %w", ctx.Workspace, err)
+		return fmt.Errorf("failed to get channel for workspace %s: %w", ctx.Workspace, err)
 	}
-	blocks := home.SettingsBlocks(channel, apiKey)
-	return PublishHomeView(ctx.BotToken, ctx.UserID, blocks)
+	keys, err := queries.GetAPIKeys(conn, ctx.Workspace)
+	if err != nil {
+		return fmt.Errorf("failed to get api keys for workspace %s: %w", ctx.Workspace, err)
+	}
+	webhooks, err := queries.GetWebhooks(conn, ctx.Workspace)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks for workspace %s: %w", ctx.Workspace, err)
+	}
+	blocks := home.SettingsBlocks(channel, keys, config.MaxAPIKeys, cfg.APIKeyRotationGrace, webhooks, config.MaxWebhooks)
+	return publishHomeView(ctx, conn, blocks)
 }
 
 // Handler for linking a workspace using an activation code
-func handleLinkWorkspace(ctx *models.InteractionContext, c *gin.Context, payload map[string]any, conn *sql.DB) error {
+func handleLinkWorkspace(ctx *models.InteractionContext, c *gin.Context, payload map[string]any, conn *sql.DB, cfg *config.InteractConfig) error {
 	actions, ok := payload["actions"].([]any)
 	if !ok || len(actions) == 0 {
 		log.Printf("invalid actions payload: %v", payload)
@@ -58,7 +70,7 @@ func handleLinkWorkspace(ctx *models.InteractionContext, c *gin.Context, payload
 		return err
 	}
 	if !linked {
-		c.JSON(http.StatusNotFound, gin.H{"error": "activation code not found or already used"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "activation code not found, or already used outside of this Enterprise Grid organization"})
 		return nil
 	}
 
@@ -66,7 +78,7 @@ func handleLinkWorkspace(ctx *models.InteractionContext, c *gin.Context, payload
 
 	// Publish the settings view asynchronously
 	go func() {
-		if err := handleTabSettings(ctx, conn); err != nil {
+		if err := handleTabSettings(ctx, conn, cfg); err != nil {
 			log.Printf("failed to publish settings view: %v", err)
 		}
 	}()
@@ -75,7 +87,7 @@ func handleLinkWorkspace(ctx *models.InteractionContext, c *gin.Context, payload
 }
 
 // Handler for setting the digest channel
-func handleSetChannel(ctx *models.InteractionContext, conn *sql.DB, payload map[string]any) error {
+func handleSetChannel(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig, payload map[string]any) error {
 	actions, ok := payload["actions"].([]any)
 	if !ok || len(actions) == 0 {
 		return nil
@@ -91,27 +103,236 @@ func handleSetChannel(ctx *models.InteractionContext, conn *sql.DB, payload map[
 	if err := queries.UpdateSlackChannelForWorkspace(conn, ctx.Workspace, channel); err != nil {
 		return err
 	}
-	return handleTabSettings(ctx, conn)
+	return handleTabSettings(ctx, conn, cfg)
 }
 
 // Handler for clearing the digest channel
-func handleClearChannel(ctx *models.InteractionContext, conn *sql.DB) error {
+func handleClearChannel(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig) error {
 	if err := queries.ClearSlackChannelForWorkspace(conn, ctx.Workspace); err != nil {
 		return err
 	}
-	return handleTabSettings(ctx, conn)
+	return handleTabSettings(ctx, conn, cfg)
 }
 
-// Handler for rotating the API key
-func handleRotateApiKey(ctx *models.InteractionContext, conn *sql.DB) error {
+// Handler for rotating an API key, keeping the old key valid for the grace period
+func handleRotateApiKey(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig, payload map[string]any) error {
+	keyID, err := extractAPIKeyID(payload)
+	if err != nil {
+		return err
+	}
 	newKey, err := utils.GenerateUniqueApiKey(conn)
 	if err != nil {
 		return err
 	}
-	if err := queries.UpdateApiKeyForWorkspace(conn, ctx.Workspace, newKey); err != nil {
+	name, err := queries.RotateAPIKey(conn, ctx.Workspace, keyID, newKey, cfg.APIKeyRotationGrace)
+	if err != nil {
+		return fmt.Errorf("failed to rotate api key %d for workspace %s: %w", keyID, ctx.Workspace, err)
+	}
+
+	// Show the new key once, as only its hash is stored
+	if err := openModal(ctx, conn, modals.APIKeyCreatedModal(name, newKey)); err != nil {
+		return err
+	}
+	return handleTabSettings(ctx, conn, cfg)
+}
+
+// Handler for revoking an API key
+func handleRevokeApiKey(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig, payload map[string]any) error {
+	keyID, err := extractAPIKeyID(payload)
+	if err != nil {
+		return err
+	}
+	if err := queries.RevokeAPIKey(conn, ctx.Workspace, keyID); err != nil {
+		return fmt.Errorf("failed to revoke api key %d for workspace %s: %w", keyID, ctx.Workspace, err)
+	}
+	return handleTabSettings(ctx, conn, cfg)
+}
+
+// Handle create-api-key modal submission
+func handleCreateApiKeySubmission(c *gin.Context, payload map[string]any, conn *sql.DB, cfg *config.InteractConfig) {
+	ctx, err := ExtractInteractionContext(payload, conn)
+	if err != nil {
+		log.Printf("failed to extract interaction context: %v", err)
+		updateSlackModal(c, modals.APIKeyErrorModal())
+		return
+	}
+
+	fields := extractModalSubmissionData(payload)
+	name := strings.TrimSpace(fields["api-key-name"])
+	scopes := extractModalCheckboxValues(payload, "api-key-scopes")
+
+	// Validate the input, showing errors next to the fields
+	inputErrors := map[string]string{}
+	if name == "" || len(name) > config.MaxAPIKeyNameLen {
+		inputErrors["api-key-name-block"] = fmt.Sprintf("Please enter a name of at most %d characters", config.MaxAPIKeyNameLen)
+	}
+	if len(scopes) == 0 {
+		inputErrors["api-key-scopes-block"] = "Please select at least one scope"
+	}
+	if len(inputErrors) > 0 {
+		c.JSON(http.StatusOK, map[string]any{
+			"response_action": "errors",
+			"errors":          inputErrors,
+		})
+		return
+	}
+
+	keys, err := queries.GetAPIKeys(conn, ctx.Workspace)
+	if err != nil {
+		log.Printf("failed to get api keys for workspace %s: %v", ctx.Workspace, err)
+		updateSlackModal(c, modals.APIKeyErrorModal())
+		return
+	}
+	if len(keys) >= config.MaxAPIKeys {
+		c.JSON(http.StatusOK, map[string]any{
+			"response_action": "errors",
+			"errors": map[string]string{
+				"api-key-name-block": fmt.Sprintf("You have reached the limit of %d API keys", config.MaxAPIKeys),
+			},
+		})
+		return
+	}
+
+	newKey, err := utils.GenerateUniqueApiKey(conn)
+	if err != nil {
+		log.Printf("failed to generate api key for workspace %s: %v", ctx.Workspace, err)
+		updateSlackModal(c, modals.APIKeyErrorModal())
+		return
+	}
+	if err := queries.CreateAPIKey(conn, ctx.Workspace, name, newKey, scopes); err != nil {
+		log.Printf("failed to create api key for workspace %s: %v", ctx.Workspace, err)
+		updateSlackModal(c, modals.APIKeyErrorModal())
+		return
+	}
+
+	updateSlackModal(c, modals.APIKeyCreatedModal(name, newKey))
+
+	go func() {
+		if err := handleTabSettings(ctx, conn, cfg); err != nil {
+			log.Printf("failed to publish settings view: %v", err)
+		}
+	}()
+}
+
+// Helper to extract the API key ID from the action value
+func extractAPIKeyID(payload map[string]any) (int64, error) {
+	val, err := extractActionValueFromPayload(payload)
+	if err != nil {
+		return 0, err
+	}
+	keyID, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid api key id %q", val)
+	}
+	return keyID, nil
+}
+
+// Handler for deleting a webhook
+func handleDeleteWebhook(ctx *models.InteractionContext, conn *sql.DB, cfg *config.InteractConfig, payload map[string]any) error {
+	val, err := extractActionValueFromPayload(payload)
+	if err != nil {
 		return err
 	}
-	return handleTabSettings(ctx, conn)
+	webhookID, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook id %q", val)
+	}
+	if err := queries.DeleteWebhook(conn, ctx.Workspace, webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook %d for workspace %s: %w", webhookID, ctx.Workspace, err)
+	}
+	return handleTabSettings(ctx, conn, cfg)
+}
+
+// Handle create-webhook modal submission
+func handleCreateWebhookSubmission(c *gin.Context, payload map[string]any, conn *sql.DB, cfg *config.InteractConfig) {
+	ctx, err := ExtractInteractionContext(payload, conn)
+	if err != nil {
+		log.Printf("failed to extract interaction context: %v", err)
+		updateSlackModal(c, modals.WebhookErrorModal())
+		return
+	}
+
+	fields := extractModalSubmissionData(payload)
+	webhook := &models.Webhook{
+		SlackWorkspace: ctx.Workspace,
+		URL:            strings.TrimSpace(fields["webhook-url"]),
+		Origins:        splitWebhookFilter(fields["webhook-origins"]),
+		Categories:     splitWebhookFilter(fields["webhook-categories"]),
+		Thumb:          extractModalSelectedValue(payload, "webhook-thumb"),
+	}
+	if webhook.Thumb == "" {
+		webhook.Thumb = models.WebhookThumbAny
+	}
+
+	// Validate the input, showing errors next to the fields
+	inputErrors := map[string]string{}
+	if u, err := url.Parse(webhook.URL); err != nil || u.Scheme != "https" || u.Host == "" || len(webhook.URL) > config.MaxWebhookURLLen {
+		inputErrors["webhook-url-block"] = fmt.Sprintf("Please enter an https URL of at most %d characters", config.MaxWebhookURLLen)
+	}
+	for _, origin := range webhook.Origins {
+		if len(origin) > config.MaxOriginLen {
+			inputErrors["webhook-origins-block"] = fmt.Sprintf("Origins are at most %d characters", config.MaxOriginLen)
+		}
+	}
+	for _, category := range webhook.Categories {
+		if len(category) > config.MaxCategoryLen {
+			inputErrors["webhook-categories-block"] = fmt.Sprintf("Categories are at most %d characters", config.MaxCategoryLen)
+		}
+	}
+	if len(inputErrors) > 0 {
+		c.JSON(http.StatusOK, map[string]any{
+			"response_action": "errors",
+			"errors":          inputErrors,
+		})
+		return
+	}
+
+	webhooks, err := queries.GetWebhooks(conn, ctx.Workspace)
+	if err != nil {
+		log.Printf("failed to get webhooks for workspace %s: %v", ctx.Workspace, err)
+		updateSlackModal(c, modals.WebhookErrorModal())
+		return
+	}
+	if len(webhooks) >= config.MaxWebhooks {
+		c.JSON(http.StatusOK, map[string]any{
+			"response_action": "errors",
+			"errors": map[string]string{
+				"webhook-url-block": fmt.Sprintf("You have reached the limit of %d webhooks", config.MaxWebhooks),
+			},
+		})
+		return
+	}
+
+	secret, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		log.Printf("failed to generate webhook secret for workspace %s: %v", ctx.Workspace, err)
+		updateSlackModal(c, modals.WebhookErrorModal())
+		return
+	}
+	if err := queries.CreateWebhook(conn, webhook, secret); err != nil {
+		log.Printf("failed to create webhook for workspace %s: %v", ctx.Workspace, err)
+		updateSlackModal(c, modals.WebhookErrorModal())
+		return
+	}
+
+	updateSlackModal(c, modals.WebhookCreatedModal(webhook.URL, secret))
+
+	go func() {
+		if err := handleTabSettings(ctx, conn, cfg); err != nil {
+			log.Printf("failed to publish settings view: %v", err)
+		}
+	}()
+}
+
+// Split a comma-separated webhook filter into its trimmed, non-empty values
+func splitWebhookFilter(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	return values
 }
 
 // Handler for viewing test data
@@ -122,5 +343,5 @@ func handleViewTestData(ctx *models.InteractionContext, conn *sql.DB) error {
 	}
 
 	modal := modals.TestDataModal(data)
-	return integrations.OpenSlackModal(ctx.TriggerID, modal, ctx.BotToken)
+	return openModal(ctx, conn, modal)
 }
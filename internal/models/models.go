@@ -4,12 +4,31 @@
 
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Installation struct {
-	CreatedAt      time.Time `db:"created_at"`
-	SlackWorkspace string    `db:"slack_workspace"`
-	SlackToken     string    `db:"bot_token"`
+	CreatedAt           time.Time  `db:"created_at"`
+	SlackWorkspace      string     `db:"slack_workspace"` // the enterprise ID for org-wide installations
+	SlackToken          string     // stored encrypted, see utils.EncryptToken
+	RefreshToken        string     // set with token rotation, stored encrypted
+	TokenExpiresAt      *time.Time `db:"bot_token_expires_at"` // set with token rotation
+	Scopes              []string   `db:"scopes"`
+	BotUserID           string     `db:"bot_user_id"`
+	InstalledBy         string     `db:"installed_by"`
+	EnterpriseID        *string    `db:"enterprise_id"`
+	IsEnterpriseInstall bool       `db:"is_enterprise_install"` // the token covers the workspaces of the enterprise
+	AppID               string     `db:"app_id"`
+	InstalledAt         time.Time  `db:"installed_at"` // time of the latest (re)install
+}
+
+// Slack bot token of a workspace, along with the refresh token and expiry if token rotation is enabled
+type SlackTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    *time.Time
 }
 
 type Account struct {
@@ -17,16 +36,101 @@ type Account struct {
 	CreatedAt         time.Time `db:"created_at"`
 	AccountExpiryDate time.Time `db:"account_expiry_date"`
 	ActivationCode    string    `db:"activation_code"`
-	APIKey            string    `db:"api_key"`
-	SlackWorkspace    *string   `db:"slack_workspace"`
-	SlackChannel      *string   `db:"slack_channel"`
+	SlackWorkspace    *string   `db:"slack_workspace"` // workspace of the API key, receiving its feedback
 	FeedbackCount     int       `db:"feedback_count"`
+	APIKeyID          int64     // key used to authenticate the request
+	Scopes            []string  // scopes of that key
+}
+
+type APIKey struct {
+	ID         int64      `db:"id"`
+	AccountID  string     `db:"account_id"`
+	Name       string     `db:"name"`
+	Prefix     string     `db:"key_prefix"` // the key itself is only stored as a salted hash
+	Scopes     []string   `db:"scopes"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	ExpiresAt  *time.Time `db:"expires_at"` // set on rotation, so that the old key keeps working for a while
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+const (
+	ScopeWriteFeedback = "write-feedback" // POST /feedback and /feedback/batch
+	ScopeReadData      = "read-data"      // GET /feedback and /stats
+	ScopeAdmin         = "admin"          // all of the above
+)
+
+var APIKeyScopes = []string{ScopeWriteFeedback, ScopeReadData, ScopeAdmin}
+
+type Webhook struct {
+	ID             int64      `db:"id"`
+	SlackWorkspace string     `db:"slack_workspace"`
+	URL            string     `db:"url"`        // the secret is stored encrypted and only shown on creation
+	Origins        []string   `db:"origins"`    // empty for all origins
+	Categories     []string   `db:"categories"` // empty for all categories
+	Thumb          string     `db:"thumb"`      // "any", "up", or "down"
+	CreatedAt      time.Time  `db:"created_at"`
+	LastStatus     *string    // status of the latest delivery, if any
+	LastAttemptAt  *time.Time // time of the latest delivery attempt
+	FailedLastWeek int        // deliveries given up on in the last 7 days
+}
+
+const (
+	WebhookThumbAny  = "any"
+	WebhookThumbUp   = "up"
+	WebhookThumbDown = "down"
+)
+
+// Webhook delivery claimed for an attempt, with the decrypted secret of its webhook
+type WebhookDelivery struct {
+	ID        int64
+	WebhookID int64
+	URL       string
+	Secret    string
+	Payload   []byte
+	Attempts  int   // including the current attempt
+	SecretErr error // set if the secret could not be decrypted
+}
+
+// Body of a webhook delivery
+type WebhookEvent struct {
+	Event     string          `json:"event"` // "feedback.created"
+	Workspace string          `json:"workspace"`
+	Feedback  WebhookFeedback `json:"feedback"`
+}
+
+type WebhookFeedback struct {
+	ID           int64          `json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Origin       string         `json:"origin"`
+	Category     string         `json:"category"`
+	Prompt       string         `json:"prompt"`
+	ThumbUp      bool           `json:"thumb_up"`
+	Rating       *int           `json:"rating,omitempty"`
+	Scale        string         `json:"scale"`
+	Comment      *string        `json:"comment"`
+	UserID       string         `json:"user_id"`
+	InProduction bool           `json:"in_production"`
+	Metadata     map[string]any `json:"metadata,omitempty"`
+}
+
+// Thumbs-down comment on a live prompt, to be posted to the output channel of its workspace
+type LiveAlert struct {
+	FeedbackID int64
+	Workspace  string
+	Channel    string
+	Origin     string
+	Category   string
+	Prompt     string
+	Comment    string
+	Rating     *int
+	Scale      string
+	CreatedAt  time.Time
 }
 
 type InteractionContext struct {
 	TriggerID string
 	Workspace string
-	BotToken  string
 	UserID    string
 }
 
@@ -37,6 +141,9 @@ type StatsFilterValues struct {
 	DateFrom string
 	DateTo   string
 	Thumb    string // "Up", "Down", or ""
+	MetaKey  string // metadata key to filter on
+	MetaVal  string // metadata value to filter on
+	GroupBy  string // metadata key to group stats and comments by
 }
 
 type Prompt struct {
@@ -45,29 +152,87 @@ type Prompt struct {
 	Origin         string `db:"origin"`
 	Category       string `db:"category"`
 	Prompt         string `db:"prompt"`
+	Scale          string `db:"scale"`
+	Live           bool   `db:"live"` // new thumbs-down comments are posted to the output channel right away
 }
 
+// Rating scales of a prompt
+const (
+	ScaleThumbs = "thumbs" // thumb_up only
+	ScaleCSAT   = "csat"   // 1-5 rating, 4 and 5 count as satisfied
+	ScaleNPS    = "nps"    // 0-10 rating, 9 and 10 are promoters, 0-6 are detractors
+)
+
 type Feedback struct {
-	ID             int64     `db:"id"`
-	CreatedAt      time.Time `db:"created_at"`
-	SlackWorkspace string    `db:"slack_workspace"`
-	Prompt         string    `db:"prompt"`
-	ThumbUp        bool      `db:"thumb_up"`
-	Comment        *string   `db:"comment"`
-	Origin         string    `db:"origin"`
-	Category       string    `db:"category"`
-	InProduction   bool      `db:"in_production"`
-	UserID         string    `db:"user_id"`
+	ID             int64          `db:"id"`
+	CreatedAt      time.Time      `db:"created_at"`
+	SlackWorkspace string         `db:"slack_workspace"`
+	Prompt         string         `db:"prompt"`
+	ThumbUp        bool           `db:"thumb_up"`
+	Comment        *string        `db:"comment"`
+	Origin         string         `db:"origin"`
+	Category       string         `db:"category"`
+	InProduction   bool           `db:"in_production"`
+	UserID         string         `db:"user_id"`
+	Rating         *int           `db:"rating"`
+	Scale          string         `db:"scale"`
+	Metadata       map[string]any `db:"metadata"`
 }
 
 type FeedbackRequest struct {
-	Prompt       string `json:"prompt" binding:"required"`
-	ThumbUp      *bool  `json:"thumb_up" binding:"required"`
-	Comment      string `json:"comment"`
-	Origin       string `json:"origin" binding:"required"`
-	Category     string `json:"category" binding:"required"`
-	InProduction *bool  `json:"in_production" binding:"required"`
-	UserID       string `json:"user_id" binding:"required"`
+	Prompt       string         `json:"prompt" binding:"required"`
+	ThumbUp      *bool          `json:"thumb_up"` // required for the thumbs scale, derived from the rating otherwise
+	Comment      string         `json:"comment"`
+	Origin       string         `json:"origin" binding:"required"`
+	Category     string         `json:"category" binding:"required"`
+	InProduction *bool          `json:"in_production" binding:"required"`
+	UserID       string         `json:"user_id" binding:"required"`
+	Rating       *int           `json:"rating"`
+	Scale        string         `json:"scale"`    // "thumbs" (default), "csat", or "nps"
+	Metadata     map[string]any `json:"metadata"` // optional flat object of string, number, or boolean values
+}
+
+type BatchFeedbackResult struct {
+	Index   int    `json:"index"`
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type FeedbackRow struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Origin    string          `json:"origin"`
+	Category  string          `json:"category"`
+	Prompt    string          `json:"prompt"`
+	ThumbUp   bool            `json:"thumb_up"`
+	Rating    *int            `json:"rating,omitempty"`
+	Scale     string          `json:"scale"`
+	Comment   *string         `json:"comment"`
+	UserID    string          `json:"user_id"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+}
+
+type FeedbackPage struct {
+	Data       []FeedbackRow `json:"data"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset"`
+	NextOffset *int          `json:"next_offset"` // nil on the last page
+}
+
+type GroupStats struct {
+	Origin    string `json:"origin"`
+	Category  string `json:"category"`
+	Prompt    string `json:"prompt"`
+	Dimension string `json:"dimension,omitempty"` // metadata value when grouping by a metadata key
+	*FeedbackStats
+}
+
+type StatsResponse struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	PrevFrom string       `json:"prev_from"`
+	PrevTo   string       `json:"prev_to"`
+	Stats    []GroupStats `json:"stats"`
 }
 
 type DigestRange string
@@ -82,9 +247,36 @@ const (
 )
 
 type FeedbackGroup struct {
-	Prompt   string
-	Origin   string
-	Category string
+	Prompt    string
+	Origin    string
+	Category  string
+	Dimension string // metadata value when grouping by a metadata key
+}
+
+// Feedback counts of a prompt on one day, daysAgo days before today
+type PromptDailyCount struct {
+	Group   FeedbackGroup
+	DaysAgo int
+	N       int
+	NUp     int
+}
+
+// Significant shift of a prompt's thumbs-up rate or volume yesterday, against its baseline
+type Anomaly struct {
+	Group        FeedbackGroup
+	RecentN      int
+	RecentUp     int
+	BaselineN    int
+	BaselineUp   int
+	BaselineDays int
+	RateZ        float64 // positive if the rate went up
+	RateP        float64
+	RateShift    bool
+	ExpectedN    float64 // mean daily volume of the baseline
+	VolumeZ      float64 // positive if the volume went up
+	VolumeP      float64
+	VolumeShift  bool
+	Comments     []string // sample comments of yesterday behind the shift
 }
 
 type WorkspaceChannel struct {
@@ -93,12 +285,13 @@ type WorkspaceChannel struct {
 }
 
 type SummaryRow struct {
-	SummaryDate time.Time
-	Origin      string
-	Category    string
-	Prompt      string
-	NComments   int
-	Summary     string
+	SummaryDate         time.Time
+	Origin              string
+	Category            string
+	Prompt              string
+	NComments           int
+	NCommentsSummarized int // comments the summary is based on, only loaded for digests
+	Summary             string
 }
 
 type GroupedSummaries struct {
@@ -114,17 +307,58 @@ type IssueReport struct {
 }
 
 type FeedbackStats struct {
-	ThumbsUpPct     float64
-	PrevThumbsUpPct float64
-	NFeedback       int
-	PrevNFeedback   int
-	PrevNFeedbackDl float64
-	NComments       int
-	PrevCommentsDl  float64
+	ThumbsUpPct     float64 `json:"thumbs_up_pct"`
+	PrevThumbsUpPct float64 `json:"prev_thumbs_up_pct"`
+	NFeedback       int     `json:"n_feedback"`
+	PrevNFeedback   int     `json:"prev_n_feedback"`
+	PrevNFeedbackDl float64 `json:"n_feedback_delta_pct"`
+	NComments       int     `json:"n_comments"`
+	PrevCommentsDl  float64 `json:"n_comments_delta_pct"`
+	Scale           string  `json:"scale"`
+	MeanRating      float64 `json:"mean_rating"`
+	PrevMeanRating  float64 `json:"prev_mean_rating"`
+	RatingScore     float64 `json:"rating_score"` // CSAT % or NPS, depending on the scale
+	PrevRatingScore float64 `json:"prev_rating_score"`
+
+	// Counts behind the scores
+	NThumbsUp       int `json:"n_thumbs_up"`
+	PrevNThumbsUp   int `json:"prev_n_thumbs_up"`
+	NRated          int `json:"n_rated"`
+	PrevNRated      int `json:"prev_n_rated"`
+	NPositive       int `json:"n_positive"` // satisfied (CSAT) or promoters (NPS)
+	PrevNPositive   int `json:"prev_n_positive"`
+	NDetractors     int `json:"n_detractors"` // NPS only
+	PrevNDetractors int `json:"prev_n_detractors"`
+
+	// 95% confidence interval of the headline score (thumbs up %, CSAT %, or NPS), and the
+	// significance of its change from the previous period
+	ScoreLow               float64 `json:"score_low"`
+	ScoreHigh              float64 `json:"score_high"`
+	ScoreChangeP           float64 `json:"score_change_p_value"`
+	ScoreChangeSignificant bool    `json:"score_change_significant"`
+}
+
+// Headline score of stats for display, on the scale of the prompt
+type Score struct {
+	Value       int
+	Delta       string // change from the previous period, "-" if there is none
+	Low         int    // 95% confidence interval
+	High        int
+	N           int  // responses behind the score
+	Significant bool // whether the change is significant
+}
+
+// Rows of input behind an AI summary, which is capped to bound its cost
+type InputCoverage struct {
+	Rows             int  // available
+	IncludedRows     int  // summarized
+	Chunks           int  // AI calls the rows were split into, before combining their summaries
+	Comments         int  // behind the rows, if they are daily summaries
+	IncludedComments int  // behind the summarized rows, less those the daily summaries left out
+	Failed           bool // whether the summary could not be generated
 }
 
 type DigestMessage struct {
-	BotToken  string
 	Channel   string
 	Blocks    []map[string]any
 	Workspace string
@@ -134,37 +368,43 @@ type DailyDigestData struct {
 	Origin    string
 	NComments int
 	Digest    string
+	Coverage  InputCoverage
 }
 
 type WeeklyDigestData struct {
 	Origin          string
 	Category        string
 	Prompt          string
-	Score           int
-	ScoreDelta      string
+	Scale           string
+	MeanRating      float64
+	Score           Score
 	NResponses      int
 	NResponsesDelta string
 	NComments       int
 	NCommentsDelta  string
 	Digest          string
+	Coverage        InputCoverage
 }
 
 type MonthlyDigestData struct {
 	Origin          string
 	Category        string
-	Score           int
-	ScoreDelta      string
+	Scale           string
+	MeanRating      float64
+	Score           Score
 	NResponses      int
 	NResponsesDelta string
 	NComments       int
 	NCommentsDelta  string
 	Digest          string
+	Coverage        InputCoverage
 	GraphURL        string
 }
 
 type QuarterlyDigestData struct {
 	Origin   string
 	Digest   string
+	Coverage InputCoverage
 	GraphURL string
 }
 
@@ -181,14 +421,17 @@ type ExploreCommentsResult struct {
 	Prompt    string
 	Comment   string
 	Timestamp time.Time
+	Dimension string // metadata value when grouping by a metadata key
 }
 
 type StatsData struct {
 	Origin          string
 	Category        string
 	Prompt          string
-	Score           int
-	ScoreDelta      string
+	Dimension       string // "key: value" label when grouping by a metadata key
+	Scale           string
+	MeanRating      float64
+	Score           Score
 	NResponses      int
 	NResponsesDelta string
 	NComments       int
@@ -203,6 +446,9 @@ type RawData struct {
 	ThumbUp   bool      `db:"thumb_up"`
 	Comment   *string   `db:"comment"`
 	UserID    string    `db:"user_id"`
+	Rating    *int      `db:"rating"`
+	Scale     string    `db:"scale"`
+	Metadata  *string   `db:"metadata"`
 }
 
 type UserFilterCacheEntry struct {
@@ -218,16 +464,35 @@ type EmailSender struct {
 	From     string
 }
 
+// The request an idempotency key was used for, so that the key cannot be replayed for another one
+type IdempotentRequest struct {
+	Method   string
+	Path     string
+	BodyHash string // hex SHA-256 of the request body
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
 type SlackOAuthResponse struct {
-	OK          bool   `json:"ok"`
-	AccessToken string `json:"access_token"`
-	Team        struct {
+	OK           bool   `json:"ok"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"` // only with token rotation
+	ExpiresIn    int    `json:"expires_in"`    // seconds, only with token rotation
+	Scope        string `json:"scope"`
+	BotUserID    string `json:"bot_user_id"`
+	AppID        string `json:"app_id"`
+	Team         struct {
 		ID string `json:"id"`
 	} `json:"team"`
+	Enterprise *struct {
+		ID string `json:"id"`
+	} `json:"enterprise"`
+	IsEnterpriseInstall bool `json:"is_enterprise_install"` // org-wide, without a team
+	AuthedUser          struct {
+		ID string `json:"id"`
+	} `json:"authed_user"`
 	Error            string `json:"error"`
 	ResponseMetadata struct {
 		Messages []string `json:"messages"`
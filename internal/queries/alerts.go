@@ -0,0 +1,87 @@
+// File: internal/queries/alerts.go
+
+// This file contains the database queries related to live alerts.
+
+package queries
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"twothumbs/internal/models"
+)
+
+// Claim up to limit thumbs-down comments of the last hour on live prompts of active accounts with
+// an output channel, posted since live mode was turned on. Claimed alerts are leased for leaseSecs, so
+// that no other instance posts them meanwhile, and are claimed again after the lease unless they are
+// marked as sent.
+func ClaimLiveAlerts(conn *sql.DB, limit int, leaseSecs int) ([]models.LiveAlert, error) {
+	rows, err := conn.Query(`
+        UPDATE feedback f
+        SET alert_leased_until = NOW() + make_interval(secs => $2)
+        FROM (
+            SELECT f.id, w.slack_channel
+            FROM feedback f
+            JOIN prompts p
+              ON p.slack_workspace = f.slack_workspace
+             AND p.origin = f.origin
+             AND p.category = f.category
+             AND p.prompt = f.prompt
+            JOIN account_workspaces w ON w.slack_workspace = f.slack_workspace
+            JOIN accounts a ON a.account_id = w.account_id
+            WHERE p.live
+              AND f.created_at >= p.live_since
+              AND f.alerted_at IS NULL
+              AND (f.alert_leased_until IS NULL OR f.alert_leased_until < NOW())
+              AND NOT f.thumb_up
+              AND f.comment IS NOT NULL
+              AND f.comment <> ''
+              AND f.in_production
+              AND f.created_at > NOW() - INTERVAL '1 hour'
+              AND w.slack_channel IS NOT NULL
+              AND w.slack_channel <> ''
+              AND a.account_expiry_date > NOW()
+            ORDER BY f.id
+            LIMIT $1
+            FOR UPDATE OF f SKIP LOCKED
+        ) due
+        WHERE f.id = due.id
+        RETURNING f.id, f.slack_workspace, due.slack_channel, f.origin, f.category, f.prompt, f.comment, f.rating, f.scale, f.created_at
+    `, limit, leaseSecs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.LiveAlert
+	for rows.Next() {
+		var a models.LiveAlert
+		if err := rows.Scan(
+			&a.FeedbackID,
+			&a.Workspace,
+			&a.Channel,
+			&a.Origin,
+			&a.Category,
+			&a.Prompt,
+			&a.Comment,
+			&a.Rating,
+			&a.Scale,
+			&a.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// Mark claimed alerts as alerted once their message is sent, so that they are not posted again
+func MarkLiveAlertsSent(conn *sql.DB, feedbackIDs []int64) error {
+	_, err := conn.Exec(`
+        UPDATE feedback
+        SET alerted_at = NOW(), alert_leased_until = NULL
+        WHERE id = ANY($1)
+    `, pq.Array(feedbackIDs))
+	return err
+}
@@ -6,75 +6,431 @@ package queries
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
 
 	"twothumbs/internal/models"
+	"twothumbs/internal/utils"
 )
 
 // *Table: installations*
 
-// Save a Slack installation record
+// Save a Slack installation record, encrypting the bot token and the refresh token
 func SaveInstallation(conn *sql.DB, inst *models.Installation) error {
+	enc, refreshCiphertext, err := encryptSlackTokens(inst.SlackWorkspace, inst.SlackToken, inst.RefreshToken)
+	if err != nil {
+		return err
+	}
 	query := `
-	INSERT INTO installations (slack_workspace, bot_token)
-	VALUES ($1, $2)
+	INSERT INTO installations (
+		slack_workspace, bot_token_ciphertext, bot_token_data_key, bot_token_key_id, bot_token_hash,
+		refresh_token_ciphertext, bot_token_expires_at, bot_token_aad,
+		scopes, bot_user_id, installed_by, enterprise_id, is_enterprise_install, app_id
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, TRUE, $8, $9, $10, $11, $12, $13)
 	ON CONFLICT (slack_workspace) 
-	DO UPDATE SET bot_token = EXCLUDED.bot_token
+	DO UPDATE SET
+		bot_token_ciphertext = EXCLUDED.bot_token_ciphertext,
+		bot_token_data_key = EXCLUDED.bot_token_data_key,
+		bot_token_key_id = EXCLUDED.bot_token_key_id,
+		bot_token_hash = EXCLUDED.bot_token_hash,
+		refresh_token_ciphertext = EXCLUDED.refresh_token_ciphertext,
+		bot_token_expires_at = EXCLUDED.bot_token_expires_at,
+		bot_token_aad = EXCLUDED.bot_token_aad,
+		scopes = EXCLUDED.scopes,
+		bot_user_id = EXCLUDED.bot_user_id,
+		installed_by = EXCLUDED.installed_by,
+		enterprise_id = EXCLUDED.enterprise_id,
+		is_enterprise_install = EXCLUDED.is_enterprise_install,
+		app_id = EXCLUDED.app_id,
+		installed_at = NOW()
 	`
-	_, err := conn.Exec(query, inst.SlackWorkspace, inst.SlackToken)
+	_, err = conn.Exec(
+		query,
+		inst.SlackWorkspace, enc.Ciphertext, enc.DataKey, enc.KeyID, utils.HashToken(inst.SlackToken),
+		refreshCiphertext, inst.TokenExpiresAt,
+		pq.Array(inst.Scopes), inst.BotUserID, inst.InstalledBy, inst.EnterpriseID, inst.IsEnterpriseInstall, inst.AppID,
+	)
 	return err
 }
 
-// Delete a Slack installation record by workspace
+// Key of the installation serving a workspace ($1): the installation of the workspace itself,
+// or else the org-wide installation of its enterprise
+const installationKeySQL = `
+        COALESCE(
+            (SELECT slack_workspace FROM installations WHERE slack_workspace = $1),
+            (SELECT i.slack_workspace
+             FROM installations i
+             JOIN enterprise_workspaces e ON e.enterprise_id = i.enterprise_id
+             WHERE e.slack_workspace = $1
+               AND i.is_enterprise_install)
+        )`
+
+// Delete a Slack installation record by workspace, or by enterprise for org-wide installations,
+// along with the workspaces recorded for the enterprise
 func DeleteInstallation(conn *sql.DB, slackWorkspace string) error {
-	query := `DELETE FROM installations WHERE slack_workspace = $1`
-	_, err := conn.Exec(query, slackWorkspace)
+	_, err := conn.Exec(`
+        WITH deleted AS (
+            DELETE FROM installations WHERE slack_workspace = $1
+        )
+        DELETE FROM enterprise_workspaces WHERE enterprise_id = $1
+    `, slackWorkspace)
 	return err
 }
 
-// Delete a Slack installation record by token
+// Delete a Slack installation record by token, along with the workspaces recorded for the enterprise
+// of an org-wide installation
 func DeleteInstallationByToken(conn *sql.DB, slackToken string) error {
-	query := `DELETE FROM installations WHERE bot_token = $1`
-	_, err := conn.Exec(query, slackToken)
+	_, err := conn.Exec(`
+        WITH deleted AS (
+            DELETE FROM installations WHERE bot_token_hash = $1
+            RETURNING slack_workspace, is_enterprise_install
+        )
+        DELETE FROM enterprise_workspaces e
+        USING deleted d
+        WHERE d.is_enterprise_install
+          AND e.enterprise_id = d.slack_workspace
+    `, utils.HashToken(slackToken))
 	return err
 }
 
-// Get the Slack bot token for a given workspace
-func GetBotTokenForWorkspace(conn *sql.DB, slackWorkspace string) (string, error) {
-	var botToken string
-	err := conn.QueryRow(
-		`SELECT bot_token FROM installations WHERE slack_workspace = $1 LIMIT 1`,
-		slackWorkspace,
-	).Scan(&botToken)
+// Get the decrypted Slack tokens serving a workspace
+func GetSlackTokens(conn *sql.DB, slackWorkspace string) (*models.SlackTokens, error) {
+	return scanSlackTokens(conn.QueryRow(`
+        SELECT slack_workspace, bot_token_ciphertext, bot_token_data_key, bot_token_key_id, bot_token_aad, refresh_token_ciphertext, bot_token_expires_at
+        FROM installations
+        WHERE slack_workspace = `+installationKeySQL, slackWorkspace))
+}
+
+// Get the decrypted Slack tokens serving a workspace, locking the row until the transaction ends
+func LockSlackTokens(tx *sql.Tx, slackWorkspace string) (*models.SlackTokens, error) {
+	return scanSlackTokens(tx.QueryRow(`
+        SELECT slack_workspace, bot_token_ciphertext, bot_token_data_key, bot_token_key_id, bot_token_aad, refresh_token_ciphertext, bot_token_expires_at
+        FROM installations
+        WHERE slack_workspace = `+installationKeySQL+`
+        FOR UPDATE
+    `, slackWorkspace))
+}
+
+// Replace the Slack tokens serving a workspace after a refresh
+func UpdateSlackTokens(tx *sql.Tx, slackWorkspace string, tokens *models.SlackTokens) error {
+	// The tokens are bound to the installation, which may be the org-wide one of the enterprise
+	var installation string
+	if err := tx.QueryRow(`SELECT `+installationKeySQL, slackWorkspace).Scan(&installation); err != nil {
+		return err
+	}
+	enc, refreshCiphertext, err := encryptSlackTokens(installation, tokens.AccessToken, tokens.RefreshToken)
 	if err != nil {
-		return "", err
+		return err
+	}
+	_, err = tx.Exec(`
+        UPDATE installations
+        SET bot_token_ciphertext = $2,
+            bot_token_data_key = $3,
+            bot_token_key_id = $4,
+            bot_token_hash = $5,
+            refresh_token_ciphertext = $6,
+            bot_token_expires_at = $7,
+            bot_token_aad = TRUE
+        WHERE slack_workspace = $1
+    `, installation, enc.Ciphertext, enc.DataKey, enc.KeyID, utils.HashToken(tokens.AccessToken), refreshCiphertext, tokens.ExpiresAt)
+	return err
+}
+
+// Encrypt the bot token of an installation, and the refresh token if any with the same data key,
+// binding them to the installation
+func encryptSlackTokens(installation, accessToken, refreshToken string) (*utils.EncryptedToken, []byte, error) {
+	enc, err := utils.EncryptToken(accessToken, utils.TokenAAD("installations.bot_token", installation))
+	if err != nil {
+		return nil, nil, err
+	}
+	if refreshToken == "" {
+		return enc, nil, nil
+	}
+	refreshCiphertext, err := utils.EncryptWithDataKey(enc, refreshToken, utils.TokenAAD("installations.refresh_token", installation))
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, refreshCiphertext, nil
+}
+
+func scanSlackTokens(row *sql.Row) (*models.SlackTokens, error) {
+	var installation string
+	var enc utils.EncryptedToken
+	var refreshCiphertext []byte
+	var tokens models.SlackTokens
+	if err := row.Scan(&installation, &enc.Ciphertext, &enc.DataKey, &enc.KeyID, &enc.AADBound, &refreshCiphertext, &tokens.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return decryptSlackTokens(installation, &enc, refreshCiphertext, &tokens)
+}
+
+// Decrypt the bot token of an installation into tokens, along with the refresh token if any
+func decryptSlackTokens(installation string, enc *utils.EncryptedToken, refreshCiphertext []byte, tokens *models.SlackTokens) (*models.SlackTokens, error) {
+	accessToken, err := utils.DecryptToken(enc, utils.TokenAAD("installations.bot_token", installation))
+	if err != nil {
+		return nil, err
+	}
+	tokens.AccessToken = accessToken
+	if refreshCiphertext != nil {
+		refreshToken, err := utils.DecryptWithDataKey(enc, refreshCiphertext, utils.TokenAAD("installations.refresh_token", installation))
+		if err != nil {
+			return nil, err
+		}
+		tokens.RefreshToken = refreshToken
+	}
+	return tokens, nil
+}
+
+// Get the installation record serving a workspace, without the bot token
+func GetInstallation(conn *sql.DB, slackWorkspace string) (*models.Installation, error) {
+	var inst models.Installation
+	err := conn.QueryRow(`
+        SELECT slack_workspace, created_at, scopes, bot_user_id, installed_by, enterprise_id, is_enterprise_install, app_id, installed_at
+        FROM installations
+        WHERE slack_workspace = `+installationKeySQL, slackWorkspace).Scan(
+		&inst.SlackWorkspace,
+		&inst.CreatedAt,
+		pq.Array(&inst.Scopes),
+		&inst.BotUserID,
+		&inst.InstalledBy,
+		&inst.EnterpriseID,
+		&inst.IsEnterpriseInstall,
+		&inst.AppID,
+		&inst.InstalledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// Check whether the installations table still has the legacy plaintext bot_token column
+func HasLegacyBotTokenColumn(conn *sql.DB) (bool, error) {
+	var exists bool
+	err := conn.QueryRow(`
+        SELECT EXISTS(
+            SELECT 1 FROM information_schema.columns
+            WHERE table_name = 'installations' AND column_name = 'bot_token'
+        )
+    `).Scan(&exists)
+	return exists, err
+}
+
+// Encrypt bot tokens still stored in the legacy plaintext column, returning the number of rows. They
+// are bound to their installation by RewrapBotTokens, as the column marking bound tokens comes later.
+func EncryptLegacyBotTokens(conn *sql.DB) (int, error) {
+	rows, err := conn.Query(`SELECT slack_workspace, bot_token FROM installations WHERE bot_token IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	tokens := make(map[string]string)
+	for rows.Next() {
+		var ws, token string
+		if err := rows.Scan(&ws, &token); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tokens[ws] = token
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for ws, token := range tokens {
+		enc, err := utils.EncryptToken(token, nil)
+		if err != nil {
+			return n, err
+		}
+		_, err = conn.Exec(`
+            UPDATE installations
+            SET bot_token_ciphertext = $2, bot_token_data_key = $3, bot_token_key_id = $4, bot_token_hash = $5, bot_token = NULL
+            WHERE slack_workspace = $1
+        `, ws, enc.Ciphertext, enc.DataKey, enc.KeyID, utils.HashToken(token))
+		if err != nil {
+			return n, err
+		}
+		n++
 	}
-	return botToken, nil
+	return n, nil
+}
+
+// Re-encrypt the data keys of bot tokens that are not wrapped with the current master key, and
+// re-encrypt tokens that are not yet bound to their installation, returning the number of rows
+func RewrapBotTokens(conn *sql.DB) (int, error) {
+	kr, err := utils.GetTokenKeyring()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := conn.Query(`
+        SELECT slack_workspace, bot_token_ciphertext, bot_token_data_key, bot_token_key_id, bot_token_aad, refresh_token_ciphertext
+        FROM installations
+        WHERE bot_token_key_id <> $1
+           OR NOT bot_token_aad
+    `, kr.CurrentID())
+	if err != nil {
+		return 0, err
+	}
+	type storedTokens struct {
+		enc               utils.EncryptedToken
+		refreshCiphertext []byte
+	}
+	tokens := make(map[string]*storedTokens)
+	for rows.Next() {
+		var ws string
+		var t storedTokens
+		if err := rows.Scan(&ws, &t.enc.Ciphertext, &t.enc.DataKey, &t.enc.KeyID, &t.enc.AADBound, &t.refreshCiphertext); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tokens[ws] = &t
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for ws, t := range tokens {
+		if !t.enc.AADBound {
+			if err := bindSlackTokens(conn, ws, &t.enc, t.refreshCiphertext); err != nil {
+				return n, fmt.Errorf("workspace %s: %w", ws, err)
+			}
+			n++
+			continue
+		}
+		rewrapped, changed, err := utils.RewrapToken(&t.enc)
+		if err != nil {
+			return n, fmt.Errorf("workspace %s: %w", ws, err)
+		}
+		if !changed {
+			continue
+		}
+		// Only update if no one replaced the token in the meantime
+		_, err = conn.Exec(`
+            UPDATE installations
+            SET bot_token_data_key = $2, bot_token_key_id = $3
+            WHERE slack_workspace = $1 AND bot_token_key_id = $4
+        `, ws, rewrapped.DataKey, rewrapped.KeyID, t.enc.KeyID)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Re-encrypt the tokens of an installation encrypted before tokens were bound to their installation
+func bindSlackTokens(conn *sql.DB, installation string, enc *utils.EncryptedToken, refreshCiphertext []byte) error {
+	tokens, err := decryptSlackTokens(installation, enc, refreshCiphertext, &models.SlackTokens{})
+	if err != nil {
+		return err
+	}
+	bound, boundRefreshCiphertext, err := encryptSlackTokens(installation, tokens.AccessToken, tokens.RefreshToken)
+	if err != nil {
+		return err
+	}
+	// Only update if no one replaced the token in the meantime
+	_, err = conn.Exec(`
+        UPDATE installations
+        SET bot_token_ciphertext = $2,
+            bot_token_data_key = $3,
+            bot_token_key_id = $4,
+            refresh_token_ciphertext = $5,
+            bot_token_aad = TRUE
+        WHERE slack_workspace = $1 AND bot_token_ciphertext = $6
+    `, installation, bound.Ciphertext, bound.DataKey, bound.KeyID, boundRefreshCiphertext, enc.Ciphertext)
+	return err
+}
+
+// *Table: enterprise_workspaces*
+
+// Record workspaces as part of an Enterprise Grid organization. This runs for every event and command of
+// an org-wide installation, so workspaces already recorded for the enterprise are not written again.
+func SaveEnterpriseWorkspaces(conn *sql.DB, enterpriseID string, workspaces []string) error {
+	_, err := conn.Exec(`
+        INSERT INTO enterprise_workspaces (slack_workspace, enterprise_id)
+        SELECT ws, $1
+        FROM unnest($2::text[]) AS ws
+        WHERE NOT EXISTS (
+            SELECT 1 FROM enterprise_workspaces e
+            WHERE e.slack_workspace = ws
+              AND e.enterprise_id = $1
+        )
+        ON CONFLICT (slack_workspace)
+        DO UPDATE SET enterprise_id = EXCLUDED.enterprise_id
+        WHERE enterprise_workspaces.enterprise_id <> EXCLUDED.enterprise_id
+    `, enterpriseID, pq.Array(workspaces))
+	return err
+}
+
+// Forget workspaces of an Enterprise Grid organization after the app lost access to them, so that they
+// no longer use the bot token of the organization. Like an uninstall, this keeps them linked to their
+// account, along with their API keys, webhooks, and digest channel.
+func DeleteEnterpriseWorkspaces(conn *sql.DB, enterpriseID string, workspaces []string) error {
+	_, err := conn.Exec(`
+        DELETE FROM enterprise_workspaces
+        WHERE enterprise_id = $1
+          AND slack_workspace = ANY($2)
+    `, enterpriseID, pq.Array(workspaces))
+	return err
 }
 
 // *Table: accounts*
 
-// Get account information for a given API key
+// Get account information for a given active API key, recording the key as used
 func GetAccount(conn *sql.DB, apiKey string) (*models.Account, error) {
-	row := conn.QueryRow(`
-        SELECT account_expiry_date, slack_workspace, feedback_count
-        FROM accounts
-        WHERE api_key = $1
-        LIMIT 1
-    `, apiKey)
-
-	var cust models.Account
-	err := row.Scan(
-		&cust.AccountExpiryDate,
-		&cust.SlackWorkspace,
-		&cust.FeedbackCount,
-	)
-	if err == sql.ErrNoRows {
+	rows, err := conn.Query(`
+        SELECT k.id, k.key_salt, k.key_hash, k.scopes, a.account_id, a.account_expiry_date, w.slack_workspace, a.feedback_count
+        FROM api_keys k
+        JOIN accounts a ON a.account_id = k.account_id
+        LEFT JOIN account_workspaces w ON w.slack_workspace = k.slack_workspace AND w.account_id = k.account_id
+        WHERE k.key_prefix = $1
+          AND k.revoked_at IS NULL
+          AND (k.expires_at IS NULL OR k.expires_at > NOW())
+    `, utils.ApiKeyPrefix(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Find the key whose hash matches among the keys sharing the prefix
+	var found *models.Account
+	for rows.Next() {
+		var cust models.Account
+		var salt, hash string
+		if err := rows.Scan(
+			&cust.APIKeyID,
+			&salt,
+			&hash,
+			pq.Array(&cust.Scopes),
+			&cust.AccountID,
+			&cust.AccountExpiryDate,
+			&cust.SlackWorkspace,
+			&cust.FeedbackCount,
+		); err != nil {
+			return nil, err
+		}
+		if utils.VerifyApiKey(apiKey, salt, hash) {
+			found = &cust
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
 		return nil, nil
 	}
-	if err != nil {
+
+	if _, err := conn.Exec(`UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, found.APIKeyID); err != nil {
 		return nil, err
 	}
-	return &cust, nil
+	return found, nil
 }
 
 // Check if a account is active (workspace is linked and account_expiry_date > CURRENT_DATE)
@@ -82,10 +438,10 @@ func IsActiveAccount(conn *sql.DB, slackWorkspace string) (bool, error) {
 	var active bool
 	query := `
         SELECT EXISTS(
-            SELECT 1 FROM accounts
-            WHERE slack_workspace = $1
-              AND account_expiry_date > CURRENT_DATE
-			LIMIT 1
+            SELECT 1 FROM account_workspaces w
+            JOIN accounts a ON a.account_id = w.account_id
+            WHERE w.slack_workspace = $1
+              AND a.account_expiry_date > CURRENT_DATE
         )
     `
 	err := conn.QueryRow(query, slackWorkspace).Scan(&active)
@@ -95,29 +451,68 @@ func IsActiveAccount(conn *sql.DB, slackWorkspace string) (bool, error) {
 	return active, nil
 }
 
-// Link a workspace to a account using the activation code, returning true if successful
+// Link a workspace to a account using the activation code, returning true if successful.
+// An account links a single workspace, or several workspaces of the same Enterprise Grid organization.
+// API keys not yet assigned to a workspace, such as the initial key, are assigned to the first one.
 func LinkWorkspace(conn *sql.DB, activationCode, workspace string) (bool, error) {
-	query := `
-        UPDATE accounts
-        SET slack_workspace = $1
-        WHERE activation_code = $2
-          AND slack_workspace IS NULL
-        RETURNING 1
-    `
-	var updated int
-	err := conn.QueryRow(query, workspace, activationCode).Scan(&updated)
+	tx, err := conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var accountID int64
+	err = tx.QueryRow(`
+        WITH target AS (
+            SELECT enterprise_id FROM enterprise_workspaces WHERE slack_workspace = $1
+        )
+        INSERT INTO account_workspaces (slack_workspace, account_id)
+        SELECT $1, a.account_id
+        FROM accounts a
+        WHERE a.activation_code = $2
+          AND (
+            NOT EXISTS (SELECT 1 FROM account_workspaces w WHERE w.account_id = a.account_id)
+            OR (
+              EXISTS (SELECT 1 FROM target)
+              AND NOT EXISTS (
+                SELECT 1
+                FROM account_workspaces w
+                LEFT JOIN enterprise_workspaces e ON e.slack_workspace = w.slack_workspace
+                WHERE w.account_id = a.account_id
+                  AND e.enterprise_id IS DISTINCT FROM (SELECT enterprise_id FROM target)
+              )
+            )
+          )
+        ON CONFLICT (slack_workspace) DO NOTHING
+        RETURNING account_id
+    `, workspace, activationCode).Scan(&accountID)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
 	if err != nil {
 		return false, err
 	}
-	return true, nil
+
+	if _, err := tx.Exec(`
+        UPDATE api_keys
+        SET slack_workspace = $1
+        WHERE account_id = $2
+          AND slack_workspace IS NULL
+    `, workspace, accountID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
 }
 
 // Get the Slack workspaces of active accounts
 func GetActiveWorkspaces(conn *sql.DB) ([]string, error) {
-	rows, err := conn.Query(`SELECT slack_workspace FROM accounts WHERE account_expiry_date > NOW()`)
+	rows, err := conn.Query(`
+        SELECT w.slack_workspace
+        FROM account_workspaces w
+        JOIN accounts a ON a.account_id = w.account_id
+        WHERE a.account_expiry_date > NOW()
+    `)
 	if err != nil {
 		return nil, err
 	}
@@ -134,13 +529,15 @@ func GetActiveWorkspaces(conn *sql.DB) ([]string, error) {
 	return workspaces, nil
 }
 
-// Get the Slack workspaces and output channels of active accounts, so that created_at is older than the given digest range value
+// Get the Slack workspaces and output channels of active accounts, so that linked_at is older than the given digest range value
 func GetActiveWorkspacesAndChannels(conn *sql.DB, dr models.DigestRange) ([]models.WorkspaceChannel, error) {
 	query := `
-        SELECT slack_workspace, slack_channel FROM accounts
-        WHERE account_expiry_date > NOW()
-		  AND slack_channel IS NOT NULL
-          AND created_at < (CURRENT_DATE - CAST($1 as INTERVAL))
+        SELECT w.slack_workspace, w.slack_channel
+        FROM account_workspaces w
+        JOIN accounts a ON a.account_id = w.account_id
+        WHERE a.account_expiry_date > NOW()
+          AND w.slack_channel IS NOT NULL
+          AND w.linked_at < (CURRENT_DATE - CAST($1 as INTERVAL))
     `
 	rows, err := conn.Query(query, string(dr))
 	if err != nil {
@@ -159,31 +556,30 @@ func GetActiveWorkspacesAndChannels(conn *sql.DB, dr models.DigestRange) ([]mode
 	return result, nil
 }
 
-// Get the prompt count for a workspace, checking if the provided prompt exists already
-func GetPromptCountAndExists(conn *sql.DB, workspace, origin, category, prompt string) (count int, exists bool, err error) {
+// Get the prompt count for a workspace, along with the scale of the provided prompt ("" if it does not exist yet)
+func GetPromptCountAndScale(conn *sql.DB, workspace, origin, category, prompt string) (count int, scale string, err error) {
 	err = conn.QueryRow(`
         SELECT COUNT(*) AS count,
-               COALESCE(BOOL_OR(origin = $2 AND category = $3 AND prompt = $4), false) AS exists
+               COALESCE(MAX(scale) FILTER (WHERE origin = $2 AND category = $3 AND prompt = $4), '') AS scale
         FROM prompts
         WHERE slack_workspace = $1
-    `, workspace, origin, category, prompt).Scan(&count, &exists)
+    `, workspace, origin, category, prompt).Scan(&count, &scale)
 	return
 }
 
-// Get api_key and slack_channel for a given workspace
-func GetAPIKeyAndChannel(conn *sql.DB, workspace string) (apiKey, channel string, err error) {
+// Get slack_channel for a given workspace
+func GetSlackChannel(conn *sql.DB, workspace string) (channel string, err error) {
 	var channelNS sql.NullString
 	err = conn.QueryRow(`
-        SELECT api_key, slack_channel
-        FROM accounts
+        SELECT slack_channel
+        FROM account_workspaces
         WHERE slack_workspace = $1
-        LIMIT 1
-    `, workspace).Scan(&apiKey, &channelNS)
+    `, workspace).Scan(&channelNS)
 	if err == sql.ErrNoRows {
-		return "", "", nil
+		return "", nil
 	}
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 	if channelNS.Valid {
 		channel = channelNS.String
@@ -193,20 +589,10 @@ func GetAPIKeyAndChannel(conn *sql.DB, workspace string) (apiKey, channel string
 	return
 }
 
-// Update api_key for a given workspace
-func UpdateApiKeyForWorkspace(conn *sql.DB, workspace, newApiKey string) error {
-	_, err := conn.Exec(`
-        UPDATE accounts
-        SET api_key = $1
-        WHERE slack_workspace = $2
-    `, newApiKey, workspace)
-	return err
-}
-
 // Update slack_channel for a given workspace
 func UpdateSlackChannelForWorkspace(conn *sql.DB, workspace, channel string) error {
 	_, err := conn.Exec(`
-        UPDATE accounts
+        UPDATE account_workspaces
         SET slack_channel = $1
         WHERE slack_workspace = $2
     `, channel, workspace)
@@ -216,9 +602,224 @@ func UpdateSlackChannelForWorkspace(conn *sql.DB, workspace, channel string) err
 // Clear slack_channel for a given workspace
 func ClearSlackChannelForWorkspace(conn *sql.DB, workspace string) error {
 	_, err := conn.Exec(`
-        UPDATE accounts
+        UPDATE account_workspaces
         SET slack_channel = NULL
         WHERE slack_workspace = $1
     `, workspace)
 	return err
 }
+
+// *Table: api_keys*
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// Get the active (neither revoked nor expired) API keys of a workspace
+func GetAPIKeys(conn *sql.DB, workspace string) ([]models.APIKey, error) {
+	rows, err := conn.Query(`
+        SELECT k.id, k.account_id, k.name, k.key_prefix, k.scopes, k.created_at, k.last_used_at, k.expires_at
+        FROM api_keys k
+        JOIN account_workspaces w ON w.account_id = k.account_id AND w.slack_workspace = k.slack_workspace
+        WHERE w.slack_workspace = $1
+          AND k.revoked_at IS NULL
+          AND (k.expires_at IS NULL OR k.expires_at > NOW())
+        ORDER BY k.name, k.created_at
+    `, workspace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(
+			&k.ID,
+			&k.AccountID,
+			&k.Name,
+			&k.Prefix,
+			pq.Array(&k.Scopes),
+			&k.CreatedAt,
+			&k.LastUsedAt,
+			&k.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Create an API key for a workspace of an account, storing only its prefix and salted hash
+func CreateAPIKey(conn *sql.DB, workspace, name, apiKey string, scopes []string) error {
+	salt, err := utils.GenerateApiKeySalt()
+	if err != nil {
+		return err
+	}
+	res, err := conn.Exec(`
+        INSERT INTO api_keys (account_id, slack_workspace, name, key_prefix, key_salt, key_hash, scopes)
+        SELECT account_id, slack_workspace, $2, $3, $4, $5, $6
+        FROM account_workspaces
+        WHERE slack_workspace = $1
+    `, workspace, name, utils.ApiKeyPrefix(apiKey), salt, utils.HashApiKey(apiKey, salt), pq.Array(scopes))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Replace an API key with a new key of the same name and scopes. The old key keeps working
+// for the grace period, so that clients can switch over without downtime. Returns the name of the key.
+func RotateAPIKey(conn *sql.DB, workspace string, keyID int64, newKey string, graceSecs int) (string, error) {
+	salt, err := utils.GenerateApiKeySalt()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRow(`
+        INSERT INTO api_keys (account_id, slack_workspace, name, key_prefix, key_salt, key_hash, scopes)
+        SELECT k.account_id, k.slack_workspace, k.name, $3, $4, $5, k.scopes
+        FROM api_keys k
+        JOIN account_workspaces w ON w.account_id = k.account_id AND w.slack_workspace = k.slack_workspace
+        WHERE k.id = $2
+          AND w.slack_workspace = $1
+          AND k.revoked_at IS NULL
+          AND k.expires_at IS NULL
+        RETURNING name
+    `, workspace, keyID, utils.ApiKeyPrefix(newKey), salt, utils.HashApiKey(newKey, salt)).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE api_keys
+        SET expires_at = NOW() + make_interval(secs => $2)
+        WHERE id = $1
+    `, keyID, graceSecs); err != nil {
+		return "", err
+	}
+
+	return name, tx.Commit()
+}
+
+// Revoke an API key of a workspace immediately
+func RevokeAPIKey(conn *sql.DB, workspace string, keyID int64) error {
+	res, err := conn.Exec(`
+        UPDATE api_keys k
+        SET revoked_at = NOW()
+        FROM account_workspaces w
+        WHERE w.account_id = k.account_id
+          AND w.slack_workspace = k.slack_workspace
+          AND k.id = $2
+          AND w.slack_workspace = $1
+          AND k.revoked_at IS NULL
+    `, workspace, keyID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// *Table: idempotency_keys*
+
+// Reserve an idempotency key for an account, returning true if the key is new, its window has
+// passed, or its request did not finish within the lease (e.g. as the instance handling it stopped).
+// Otherwise, the stored response is returned (a nil body means the original request is still in flight),
+// along with the request the key was first used for.
+func ReserveIdempotencyKey(conn *sql.DB, accountID, key string, req models.IdempotentRequest, windowSecs, leaseSecs int) (reserved bool, status int, body []byte, original models.IdempotentRequest, err error) {
+	var one int
+	err = conn.QueryRow(`
+        INSERT INTO idempotency_keys (account_id, idempotency_key, request_method, request_path, request_hash)
+        VALUES ($1, $2, $4, $5, $6)
+        ON CONFLICT (account_id, idempotency_key)
+        DO UPDATE SET created_at = NOW(), status_code = NULL, response_body = NULL,
+                      request_method = $4, request_path = $5, request_hash = $6
+        WHERE idempotency_keys.created_at < NOW() - make_interval(secs => $3)
+           OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < NOW() - make_interval(secs => $7))
+        RETURNING 1
+    `, accountID, key, windowSecs, req.Method, req.Path, req.BodyHash, leaseSecs).Scan(&one)
+	if err == nil {
+		return true, 0, nil, req, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, 0, nil, original, err
+	}
+
+	var statusNI sql.NullInt64
+	err = conn.QueryRow(`
+        SELECT status_code, response_body, request_method, request_path, request_hash
+        FROM idempotency_keys
+        WHERE account_id = $1
+          AND idempotency_key = $2
+    `, accountID, key).Scan(&statusNI, &body, &original.Method, &original.Path, &original.BodyHash)
+	if err != nil {
+		return false, 0, nil, original, err
+	}
+	if statusNI.Valid {
+		status = int(statusNI.Int64)
+	}
+	return false, status, body, original, nil
+}
+
+// Store the response for a reserved idempotency key
+func SaveIdempotentResponse(conn *sql.DB, accountID, key string, status int, body []byte) error {
+	_, err := conn.Exec(`
+        UPDATE idempotency_keys
+        SET status_code = $3, response_body = $4
+        WHERE account_id = $1
+          AND idempotency_key = $2
+    `, accountID, key, status, body)
+	return err
+}
+
+// Release a reserved idempotency key, so that the request can be retried
+func DeleteIdempotencyKey(conn *sql.DB, accountID, key string) error {
+	_, err := conn.Exec(`
+        DELETE FROM idempotency_keys
+        WHERE account_id = $1
+          AND idempotency_key = $2
+    `, accountID, key)
+	return err
+}
+
+// *Table: slack_events*
+
+// Record a Slack event delivery, returning true if the event_id is new or its window has passed
+func RecordSlackEvent(conn *sql.DB, eventID string, windowSecs int) (bool, error) {
+	res, err := conn.Exec(`
+        INSERT INTO slack_events (event_id)
+        VALUES ($1)
+        ON CONFLICT (event_id)
+        DO UPDATE SET received_at = NOW()
+        WHERE slack_events.received_at < NOW() - make_interval(secs => $2)
+    `, eventID, windowSecs)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Delete the record of a Slack event, so that a retry of it is processed
+func DeleteSlackEvent(conn *sql.DB, eventID string) error {
+	_, err := conn.Exec(`DELETE FROM slack_events WHERE event_id = $1`, eventID)
+	return err
+}
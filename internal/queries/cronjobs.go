@@ -10,7 +10,7 @@ import (
 	"twothumbs/internal/models"
 )
 
-// Get comments for caching
+// Get comments for caching, most recent first
 func GetCommentsForCaching(conn *sql.DB, workspace string) ([]*models.Feedback, error) {
 	rows, err := conn.Query(`
         SELECT prompt, thumb_up, comment, origin, category, user_id
@@ -19,6 +19,7 @@ func GetCommentsForCaching(conn *sql.DB, workspace string) ([]*models.Feedback,
             AND in_production = true
             AND comment IS NOT NULL
             AND DATE(created_at) = CURRENT_DATE - INTERVAL '1 day'
+        ORDER BY created_at DESC, id
     `, workspace)
 	if err != nil {
 		return nil, err
@@ -37,11 +38,11 @@ func GetCommentsForCaching(conn *sql.DB, workspace string) ([]*models.Feedback,
 }
 
 // Insert a summary into the database
-func InsertSummary(conn *sql.DB, workspace, prompt, origin, category string, nComments int, summary string) error {
+func InsertSummary(conn *sql.DB, workspace, prompt, origin, category string, nComments, nSummarized int, summary string) error {
 	_, err := conn.Exec(`
-        INSERT INTO summaries (summary_date, slack_workspace, origin, category, prompt, n_comments, summary)
-        VALUES (CURRENT_DATE - INTERVAL '1 day', $1, $2, $3, $4, $5, $6)
-    `, workspace, origin, category, prompt, nComments, summary)
+        INSERT INTO summaries (summary_date, slack_workspace, origin, category, prompt, n_comments, n_comments_summarized, summary)
+        VALUES (CURRENT_DATE - INTERVAL '1 day', $1, $2, $3, $4, $5, $6, $7)
+    `, workspace, origin, category, prompt, nComments, nSummarized, summary)
 	return err
 }
 
@@ -93,6 +94,70 @@ func GetLatestSummaries(conn *sql.DB, workspace string) ([]*models.SummaryRow, e
 	return summaries, nil
 }
 
+// Fetch the daily feedback counts of each prompt over the given number of days before today
+func GetPromptDailyCounts(conn *sql.DB, workspace string, days int) ([]models.PromptDailyCount, error) {
+	rows, err := conn.Query(`
+        SELECT origin, category, prompt,
+               CURRENT_DATE - DATE(created_at) AS days_ago,
+               COUNT(*),
+               COUNT(*) FILTER (WHERE thumb_up)
+        FROM feedback
+        WHERE slack_workspace = $1
+          AND in_production = true
+          AND created_at >= CURRENT_DATE - make_interval(days => $2)
+          AND created_at < CURRENT_DATE
+        GROUP BY origin, category, prompt, days_ago
+    `, workspace, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.PromptDailyCount
+	for rows.Next() {
+		var c models.PromptDailyCount
+		if err := rows.Scan(&c.Group.Origin, &c.Group.Category, &c.Group.Prompt, &c.DaysAgo, &c.N, &c.NUp); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// Fetch up to limit of yesterday's comments on a prompt, optionally only thumbs up or down ones
+func GetYesterdaysComments(conn *sql.DB, workspace string, group models.FeedbackGroup, thumbUp *bool, limit int) ([]string, error) {
+	rows, err := conn.Query(`
+        SELECT comment
+        FROM feedback
+        WHERE slack_workspace = $1
+          AND origin = $2
+          AND category = $3
+          AND prompt = $4
+          AND ($5::BOOLEAN IS NULL OR thumb_up = $5)
+          AND in_production = true
+          AND comment IS NOT NULL
+          AND comment <> ''
+          AND created_at >= CURRENT_DATE - 1
+          AND created_at < CURRENT_DATE
+        ORDER BY created_at DESC
+        LIMIT $6
+    `, workspace, group.Origin, group.Category, group.Prompt, thumbUp, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []string
+	for rows.Next() {
+		var comment string
+		if err := rows.Scan(&comment); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
 // Insert an issue report into the database
 func InsertIssueReport(conn *sql.DB, workspace, origin, issueReport string) error {
 	_, err := conn.Exec(`
@@ -6,6 +6,7 @@ package queries
 
 import (
 	"database/sql"
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -16,16 +17,16 @@ import (
 )
 
 // Insert a new prompt into the database
-func InsertPrompt(conn *sql.DB, workspace, origin, category, prompt string) (bool, error) {
+func InsertPrompt(conn *sql.DB, workspace, origin, category, prompt, scale string) (bool, error) {
 	origin, category, prompt = utils.TrimFeedbackFields(origin, category, prompt)
 	result, err := conn.Exec(`
         INSERT INTO prompts (
-            slack_workspace, origin, category, prompt
+            slack_workspace, origin, category, prompt, scale
         ) VALUES (
-            $1, $2, $3, $4
+            $1, $2, $3, $4, $5
         )
         ON CONFLICT (slack_workspace, origin, category, prompt) DO NOTHING
-    `, workspace, origin, category, prompt)
+    `, workspace, origin, category, prompt, scale)
 	if err != nil {
 		return false, err
 	}
@@ -82,7 +83,7 @@ func DeletePromptAndFeedback(conn *sql.DB, promptID string) error {
 func GetPrompt(conn *sql.DB, promptID string) (models.Prompt, error) {
 	var p models.Prompt
 	err := conn.QueryRow(`
-        SELECT id, slack_workspace, origin, category, prompt
+        SELECT id, slack_workspace, origin, category, prompt, scale, live
         FROM prompts
         WHERE id = $1
     `, promptID).Scan(
@@ -91,6 +92,8 @@ func GetPrompt(conn *sql.DB, promptID string) (models.Prompt, error) {
 		&p.Origin,
 		&p.Category,
 		&p.Prompt,
+		&p.Scale,
+		&p.Live,
 	)
 	return p, err
 }
@@ -98,7 +101,7 @@ func GetPrompt(conn *sql.DB, promptID string) (models.Prompt, error) {
 // Get all prompts for a given workspace, ordered by origin, category, and prompt
 func GetPrompts(conn *sql.DB, workspace string) ([]models.Prompt, error) {
 	rows, err := conn.Query(`
-        SELECT id, slack_workspace, origin, category, prompt
+        SELECT id, slack_workspace, origin, category, prompt, scale, live
         FROM prompts
         WHERE slack_workspace = $1
         ORDER BY origin, category, prompt
@@ -117,6 +120,8 @@ func GetPrompts(conn *sql.DB, workspace string) ([]models.Prompt, error) {
 			&p.Origin,
 			&p.Category,
 			&p.Prompt,
+			&p.Scale,
+			&p.Live,
 		); err != nil {
 			return nil, err
 		}
@@ -125,23 +130,45 @@ func GetPrompts(conn *sql.DB, workspace string) ([]models.Prompt, error) {
 	return prompts, nil
 }
 
-// Insert a new feedback record into the database and increment the feedback count for the account.
+// Turn live alerts of a prompt of a workspace on or off. Turning them on records the time, so that
+// only comments from then on are alerted.
+func SetPromptLive(conn *sql.DB, workspace string, promptID int64, live bool) error {
+	res, err := conn.Exec(`
+        UPDATE prompts
+        SET live = $3,
+            live_since = CASE WHEN $3 AND NOT live THEN NOW() ELSE live_since END
+        WHERE id = $1 AND slack_workspace = $2
+    `, promptID, workspace, live)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Insert a new feedback record into the database, increment the feedback count for the account, and queue
+// the webhook deliveries of the record, all in one transaction. The ID and creation time of the record are set on fb.
 func InsertFeedback(conn *sql.DB, fb *models.Feedback) error {
-	fb.Origin, fb.Category, fb.Prompt = utils.TrimFeedbackFields(fb.Origin, fb.Category, fb.Prompt)
-	if fb.Comment != nil {
-		trimmed := strings.TrimSpace(*fb.Comment)
-		if trimmed == "" {
-			fb.Comment = nil
-		} else {
-			fb.Comment = &trimmed
-		}
+	normalizeFeedback(fb)
+	metadata, err := metadataJSON(fb.Metadata)
+	if err != nil {
+		return err
 	}
-	_, err := conn.Exec(`
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
         INSERT INTO feedback (
-            slack_workspace, prompt, thumb_up, comment, origin, category, in_production, user_id
+            slack_workspace, prompt, thumb_up, comment, origin, category, in_production, user_id, rating, scale, metadata
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
         )
+        RETURNING id, created_at
     `,
 		fb.SlackWorkspace,
 		fb.Prompt,
@@ -150,19 +177,121 @@ func InsertFeedback(conn *sql.DB, fb *models.Feedback) error {
 		fb.Origin,
 		fb.Category,
 		fb.InProduction,
-		strings.TrimSpace(fb.UserID),
-	)
+		fb.UserID,
+		fb.Rating,
+		fb.Scale,
+		metadata,
+	).Scan(&fb.ID, &fb.CreatedAt)
 	if err != nil {
 		return err
 	}
 
 	// Increment feedback_count for the account
-	_, err = conn.Exec(`
+	_, err = tx.Exec(`
         UPDATE accounts
         SET feedback_count = COALESCE(feedback_count, 0) + 1
-        WHERE slack_workspace = $1
+        WHERE account_id = (SELECT account_id FROM account_workspaces WHERE slack_workspace = $1)
     `, fb.SlackWorkspace)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := enqueueWebhookDeliveries(tx, fb); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Insert a batch of feedback records and their prompts in a single transaction, queuing their webhook
+// deliveries and incrementing the feedback count for the account by the batch size. The ID and creation time
+// of each record are set on it.
+func InsertFeedbackBatch(conn *sql.DB, workspace string, feedbacks []*models.Feedback) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, fb := range feedbacks {
+		normalizeFeedback(fb)
+		metadata, err := metadataJSON(fb.Metadata)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+            INSERT INTO prompts (
+                slack_workspace, origin, category, prompt, scale
+            ) VALUES (
+                $1, $2, $3, $4, $5
+            )
+            ON CONFLICT (slack_workspace, origin, category, prompt) DO NOTHING
+        `, workspace, fb.Origin, fb.Category, fb.Prompt, fb.Scale); err != nil {
+			return err
+		}
+		if err := tx.QueryRow(`
+            INSERT INTO feedback (
+                slack_workspace, prompt, thumb_up, comment, origin, category, in_production, user_id, rating, scale, metadata
+            ) VALUES (
+                $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+            )
+            RETURNING id, created_at
+        `,
+			workspace,
+			fb.Prompt,
+			fb.ThumbUp,
+			fb.Comment,
+			fb.Origin,
+			fb.Category,
+			fb.InProduction,
+			fb.UserID,
+			fb.Rating,
+			fb.Scale,
+			metadata,
+		).Scan(&fb.ID, &fb.CreatedAt); err != nil {
+			return err
+		}
+		fb.SlackWorkspace = workspace
+		if err := enqueueWebhookDeliveries(tx, fb); err != nil {
+			return err
+		}
+	}
+
+	// Increment feedback_count for the account
+	if _, err := tx.Exec(`
+        UPDATE accounts
+        SET feedback_count = COALESCE(feedback_count, 0) + $2
+        WHERE account_id = (SELECT account_id FROM account_workspaces WHERE slack_workspace = $1)
+    `, workspace, len(feedbacks)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Trim the feedback fields and drop blank comments before insertion
+func normalizeFeedback(fb *models.Feedback) {
+	fb.Origin, fb.Category, fb.Prompt = utils.TrimFeedbackFields(fb.Origin, fb.Category, fb.Prompt)
+	fb.UserID = strings.TrimSpace(fb.UserID)
+	if fb.Comment != nil {
+		trimmed := strings.TrimSpace(*fb.Comment)
+		if trimmed == "" {
+			fb.Comment = nil
+		} else {
+			fb.Comment = &trimmed
+		}
+	}
+}
+
+// Encode feedback metadata for a JSONB column, storing NULL when there is none
+func metadataJSON(metadata map[string]any) (any, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
 }
 
 // Get distinct origins, categories, prompts, and feedback count for a workspace and time range, with filters
@@ -170,7 +299,7 @@ func GetHomeTabData(
 	conn *sql.DB,
 	workspace string,
 	from, to time.Time,
-	origin, category, prompt, thumb string,
+	origin, category, prompt, thumb, metaKey, metaVal string,
 ) ([]string, []string, []string, int, int, error) {
 	query := `
     WITH
@@ -187,6 +316,7 @@ func GetHomeTabData(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
+          AND ($8 = '' OR metadata->>$8 = $9)
     ),
     categories AS (
         SELECT DISTINCT category FROM feedback
@@ -201,6 +331,7 @@ func GetHomeTabData(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
+          AND ($8 = '' OR metadata->>$8 = $9)
     ),
     prompts AS (
         SELECT DISTINCT prompt FROM feedback
@@ -215,6 +346,7 @@ func GetHomeTabData(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
+          AND ($8 = '' OR metadata->>$8 = $9)
     ),
     counts AS (
         SELECT COUNT(*) AS feedback_count FROM feedback
@@ -230,6 +362,7 @@ func GetHomeTabData(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
+          AND ($8 = '' OR metadata->>$8 = $9)
     ),
     comments AS (
         SELECT COUNT(*) AS comment_count FROM feedback
@@ -246,6 +379,7 @@ func GetHomeTabData(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
+          AND ($8 = '' OR metadata->>$8 = $9)
     )
     SELECT
         ARRAY(SELECT origin FROM origins),
@@ -257,7 +391,7 @@ func GetHomeTabData(
 
 	var outOrigins, outCategories, outPrompts []string
 	var feedbackCount, commentCount int
-	row := conn.QueryRow(query, workspace, from, to, origin, category, prompt, thumb)
+	row := conn.QueryRow(query, workspace, from, to, origin, category, prompt, thumb, metaKey, metaVal)
 	err := row.Scan(pq.Array(&outOrigins), pq.Array(&outCategories), pq.Array(&outPrompts), &feedbackCount, &commentCount)
 	if err != nil {
 		return nil, nil, nil, 0, 0, err
@@ -341,15 +475,20 @@ func GetFeedbackGroups(conn *sql.DB, workspace string, dr models.DigestRange) ([
 	return groups, nil
 }
 
-// Get distinct feedback groups for a workspace, date range, and optional filters
+// Get distinct feedback groups for a workspace, date range, and optional filters,
+// split by the value of the groupBy metadata key if one is given
 func GetFeedbackGroupsWithFilters(
 	conn *sql.DB,
 	workspace string,
 	from, to time.Time,
-	origin, category, prompt, thumb string,
+	origin, category, prompt, thumb, metaKey, metaVal, groupBy string,
 ) ([]models.FeedbackGroup, error) {
 	query := `
-        SELECT DISTINCT origin, category, prompt
+        SELECT DISTINCT
+            origin,
+            category,
+            prompt,
+            CASE WHEN $10 = '' THEN '' ELSE COALESCE(metadata->>$10, '') END AS dimension
         FROM feedback
         WHERE slack_workspace = $1
           AND in_production = TRUE
@@ -363,8 +502,9 @@ func GetFeedbackGroupsWithFilters(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
+          AND ($8 = '' OR metadata->>$8 = $9)
     `
-	args := []any{workspace, from, to, origin, category, prompt, thumb}
+	args := []any{workspace, from, to, origin, category, prompt, thumb, metaKey, metaVal, groupBy}
 
 	rows, err := conn.Query(query, args...)
 	if err != nil {
@@ -375,7 +515,7 @@ func GetFeedbackGroupsWithFilters(
 	var groups []models.FeedbackGroup
 	for rows.Next() {
 		var g models.FeedbackGroup
-		if err := rows.Scan(&g.Origin, &g.Category, &g.Prompt); err != nil {
+		if err := rows.Scan(&g.Origin, &g.Category, &g.Prompt, &g.Dimension); err != nil {
 			return nil, err
 		}
 		groups = append(groups, g)
@@ -389,7 +529,7 @@ func GetSummariesByWorkspace(conn *sql.DB, workspace string, dr models.DigestRan
 	switch dr {
 	case models.Monthly:
 		query = `
-            SELECT summary_date, origin, category, prompt, n_comments, summary
+            SELECT summary_date, origin, category, prompt, n_comments, n_comments_summarized, summary
             FROM summaries
             WHERE slack_workspace = $1
               AND summary_date >= DATE_TRUNC('month', CURRENT_DATE - CAST($2 AS INTERVAL))
@@ -397,7 +537,7 @@ func GetSummariesByWorkspace(conn *sql.DB, workspace string, dr models.DigestRan
         `
 	case models.Quarterly:
 		query = `
-            SELECT summary_date, origin, category, prompt, n_comments, summary
+            SELECT summary_date, origin, category, prompt, n_comments, n_comments_summarized, summary
             FROM summaries
             WHERE slack_workspace = $1
               AND summary_date >= DATE_TRUNC('month', CURRENT_DATE - CAST($2 AS INTERVAL))
@@ -405,7 +545,7 @@ func GetSummariesByWorkspace(conn *sql.DB, workspace string, dr models.DigestRan
         `
 	default: // Daily or Weekly
 		query = `
-            SELECT summary_date, origin, category, prompt, n_comments, summary
+            SELECT summary_date, origin, category, prompt, n_comments, n_comments_summarized, summary
             FROM summaries
             WHERE slack_workspace = $1
               AND summary_date >= (CURRENT_DATE - CAST($2 AS INTERVAL))
@@ -413,6 +553,9 @@ func GetSummariesByWorkspace(conn *sql.DB, workspace string, dr models.DigestRan
         `
 	}
 
+	// Most recent first, as that is what digests keep if there are too many to summarize
+	query += `  ORDER BY summary_date DESC, origin, category, prompt`
+
 	var rows *sql.Rows
 	var err error
 	rows, err = conn.Query(query, workspace, string(dr))
@@ -424,7 +567,7 @@ func GetSummariesByWorkspace(conn *sql.DB, workspace string, dr models.DigestRan
 	var summaries []models.SummaryRow
 	for rows.Next() {
 		var s models.SummaryRow
-		if err := rows.Scan(&s.SummaryDate, &s.Origin, &s.Category, &s.Prompt, &s.NComments, &s.Summary); err != nil {
+		if err := rows.Scan(&s.SummaryDate, &s.Origin, &s.Category, &s.Prompt, &s.NComments, &s.NCommentsSummarized, &s.Summary); err != nil {
 			return nil, err
 		}
 		summaries = append(summaries, s)
@@ -432,136 +575,47 @@ func GetSummariesByWorkspace(conn *sql.DB, workspace string, dr models.DigestRan
 	return summaries, nil
 }
 
-// Calculate thumbs up %, feedback count, and comment count for the current and previous period
-// by origin, category, and prompt
-func GetWeeklyFeedbackStats(conn *sql.DB, workspace string, origin string, category string, prompt string) (*models.FeedbackStats, error) {
-	query := `
-    WITH
-    current_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND DATE(created_at) >= (CURRENT_DATE - INTERVAL '7 days')
-              AND DATE(created_at) < CURRENT_DATE
-            ORDER BY user_id, created_at DESC
-        ) latest
-    ),
-    prev_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND DATE(created_at) >= (CURRENT_DATE - INTERVAL '14 days')
-              AND DATE(created_at) < (CURRENT_DATE - INTERVAL '7 days')
-            ORDER BY user_id, created_at DESC
-        ) latest
-    )
-    SELECT
-        CASE WHEN c.num_feedback > 0
-            THEN 100.0 * c.thumbs_up / c.num_feedback
-            ELSE 0 END AS thumbs_up_pct,
-        CASE WHEN p.num_feedback > 0
-            THEN 100.0 * p.thumbs_up / p.num_feedback
-            ELSE 0 END AS prev_thumbs_up_pct,
-        c.num_feedback AS n_feedback,
-        p.num_feedback AS prev_n_feedback,
-		CASE WHEN p.num_feedback > 0
-        	THEN 100.0 * (c.num_feedback - p.num_feedback) / p.num_feedback
-        ELSE 0 END AS prev_n_feedback_dl,
-        c.num_comments,
-		CASE WHEN p.num_comments > 0
-			THEN 100.0 * (c.num_comments - p.num_comments) / p.num_comments
-		ELSE 0 END AS prev_comments_dl
-    FROM
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM current_period
-        ) c,
-        (SELECT
+// Query of feedback stats for a current and a previous period, counting the latest feedback of each
+// user in a period. filter restricts the feedback of the workspace ($1) in both periods, and current
+// and prev restrict it to each period.
+func periodStatsQuery(filter, current, prev string) string {
+	period := func(dates string) string {
+		return `
+        SELECT DISTINCT ON (user_id)
+            user_id,
+            thumb_up,
+            comment,
+            rating,
+            scale,
+            created_at
+        FROM feedback
+        WHERE slack_workspace = $1
+          AND in_production = TRUE
+          AND ` + filter + `
+          AND ` + dates + `
+        ORDER BY user_id, created_at DESC`
+	}
+	aggregates := `
+        SELECT
             COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
             COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM prev_period
-        ) p;
-    `
-
-	var stats models.FeedbackStats
-	err := conn.QueryRow(query, workspace, origin, category, prompt).Scan(
-		&stats.ThumbsUpPct,
-		&stats.PrevThumbsUpPct,
-		&stats.NFeedback,
-		&stats.PrevNFeedback,
-		&stats.PrevNFeedbackDl,
-		&stats.NComments,
-		&stats.PrevCommentsDl,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return &stats, nil
-}
-
-// Calculate thumbs up %, feedback count, and comment count for the current and previous period
-// by origin and category
-func GetMonthlyFeedbackStats(conn *sql.DB, workspace string, origin string, category string) (*models.FeedbackStats, error) {
-	query := `
+            COUNT(comment) AS num_comments,
+            CASE WHEN COUNT(DISTINCT scale) = 1 THEN MAX(scale) ELSE 'thumbs' END AS scale,
+            COALESCE(AVG(rating), 0) AS mean_rating,
+            CASE WHEN COUNT(rating) > 0
+                THEN 100.0 * (
+                    COUNT(rating) FILTER (WHERE (scale = 'csat' AND rating >= 4) OR (scale = 'nps' AND rating >= 9))
+                    - COUNT(rating) FILTER (WHERE scale = 'nps' AND rating <= 6)
+                ) / COUNT(rating)
+                ELSE 0 END AS rating_score,
+            COUNT(rating) AS num_rated,
+            COUNT(rating) FILTER (WHERE (scale = 'csat' AND rating >= 4) OR (scale = 'nps' AND rating >= 9)) AS num_positive,
+            COUNT(rating) FILTER (WHERE scale = 'nps' AND rating <= 6) AS num_detractors`
+	return `
     WITH
-    current_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND DATE(created_at) >= (DATE_TRUNC('month', CURRENT_DATE) - INTERVAL '1 month')
-              AND DATE(created_at) < DATE_TRUNC('month', CURRENT_DATE)
-            ORDER BY user_id, created_at DESC
-        ) latest
+    current_period AS (` + period(current) + `
     ),
-    prev_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND DATE(created_at) >= (DATE_TRUNC('month', CURRENT_DATE) - INTERVAL '2 months')
-              AND DATE(created_at) < (DATE_TRUNC('month', CURRENT_DATE) - INTERVAL '1 month')
-            ORDER BY user_id, created_at DESC
-        ) latest
+    prev_period AS (` + period(prev) + `
     )
     SELECT
         CASE WHEN c.num_feedback > 0
@@ -578,24 +632,34 @@ func GetMonthlyFeedbackStats(conn *sql.DB, workspace string, origin string, cate
         c.num_comments,
         CASE WHEN p.num_comments > 0
             THEN 100.0 * (c.num_comments - p.num_comments) / p.num_comments
-        ELSE 0 END AS prev_comments_dl
+        ELSE 0 END AS prev_comments_dl,
+        c.scale,
+        c.mean_rating,
+        p.mean_rating AS prev_mean_rating,
+        c.rating_score,
+        p.rating_score AS prev_rating_score,
+        c.thumbs_up,
+        p.thumbs_up AS prev_thumbs_up,
+        c.num_rated,
+        p.num_rated AS prev_num_rated,
+        c.num_positive,
+        p.num_positive AS prev_num_positive,
+        c.num_detractors,
+        p.num_detractors AS prev_num_detractors
     FROM
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
+        (` + aggregates + `
          FROM current_period
         ) c,
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
+        (` + aggregates + `
          FROM prev_period
         ) p;
     `
+}
 
+// Scan the row of a periodStatsQuery, adding the confidence of the scores
+func scanPeriodStats(row *sql.Row) (*models.FeedbackStats, error) {
 	var stats models.FeedbackStats
-	err := conn.QueryRow(query, workspace, origin, category).Scan(
+	err := row.Scan(
 		&stats.ThumbsUpPct,
 		&stats.PrevThumbsUpPct,
 		&stats.NFeedback,
@@ -603,13 +667,49 @@ func GetMonthlyFeedbackStats(conn *sql.DB, workspace string, origin string, cate
 		&stats.PrevNFeedbackDl,
 		&stats.NComments,
 		&stats.PrevCommentsDl,
+		&stats.Scale,
+		&stats.MeanRating,
+		&stats.PrevMeanRating,
+		&stats.RatingScore,
+		&stats.PrevRatingScore,
+		&stats.NThumbsUp,
+		&stats.PrevNThumbsUp,
+		&stats.NRated,
+		&stats.PrevNRated,
+		&stats.NPositive,
+		&stats.PrevNPositive,
+		&stats.NDetractors,
+		&stats.PrevNDetractors,
 	)
 	if err != nil {
 		return nil, err
 	}
+	utils.SetScoreConfidence(&stats)
 	return &stats, nil
 }
 
+// Calculate thumbs up %, feedback count, and comment count for the current and previous period
+// by origin, category, and prompt
+func GetWeeklyFeedbackStats(conn *sql.DB, workspace string, origin string, category string, prompt string) (*models.FeedbackStats, error) {
+	query := periodStatsQuery(
+		`origin = $2 AND category = $3 AND prompt = $4`,
+		`DATE(created_at) >= (CURRENT_DATE - INTERVAL '7 days') AND DATE(created_at) < CURRENT_DATE`,
+		`DATE(created_at) >= (CURRENT_DATE - INTERVAL '14 days') AND DATE(created_at) < (CURRENT_DATE - INTERVAL '7 days')`,
+	)
+	return scanPeriodStats(conn.QueryRow(query, workspace, origin, category, prompt))
+}
+
+// Calculate thumbs up %, feedback count, and comment count for the current and previous period
+// by origin and category
+func GetMonthlyFeedbackStats(conn *sql.DB, workspace string, origin string, category string) (*models.FeedbackStats, error) {
+	query := periodStatsQuery(
+		`origin = $2 AND category = $3`,
+		`DATE(created_at) >= (DATE_TRUNC('month', CURRENT_DATE) - INTERVAL '1 month') AND DATE(created_at) < DATE_TRUNC('month', CURRENT_DATE)`,
+		`DATE(created_at) >= (DATE_TRUNC('month', CURRENT_DATE) - INTERVAL '2 months') AND DATE(created_at) < (DATE_TRUNC('month', CURRENT_DATE) - INTERVAL '1 month')`,
+	)
+	return scanPeriodStats(conn.QueryRow(query, workspace, origin, category))
+}
+
 // Get feedback stats for plotting the monthly plots
 func GetMonthlyDigestPlotStats(conn *sql.DB, workspace, origin, category string) ([]models.PlotStats, error) {
 	query := `
@@ -683,18 +783,19 @@ func GetQuarterlyDigestPlotStats(conn *sql.DB, workspace string, origin string)
 	return stats, nil
 }
 
-// Get the n latest comments for a workspace
-func GetLatestComments(conn *sql.DB, workspace string, n int) ([]models.ExploreCommentsResult, error) {
+// Get the n latest comments for a workspace, of a single origin if one is given
+func GetLatestComments(conn *sql.DB, workspace, origin string, n int) ([]models.ExploreCommentsResult, error) {
 	query := `
         SELECT origin, category, prompt, comment, created_at
         FROM feedback
         WHERE slack_workspace = $1
           AND in_production = TRUE
           AND comment IS NOT NULL
+          AND ($2 = '' OR LOWER(origin) = LOWER($2))
         ORDER BY created_at DESC
-        LIMIT $2
+        LIMIT $3
     `
-	rows, err := conn.Query(query, workspace, n)
+	rows, err := conn.Query(query, workspace, origin, n)
 	if err != nil {
 		return nil, err
 	}
@@ -711,16 +812,94 @@ func GetLatestComments(conn *sql.DB, workspace string, n int) ([]models.ExploreC
 	return results, nil
 }
 
-// Get comments with filters
+// Get the latest daily summaries since a date, of rows matching any of the ILIKE patterns if some are given.
+// Patterns escape literal wildcards with a backslash.
+func SearchSummaries(conn *sql.DB, workspace string, from time.Time, patterns []string, limit int) ([]models.SummaryRow, error) {
+	rows, err := conn.Query(`
+        SELECT summary_date, origin, category, prompt, n_comments, summary
+        FROM summaries
+        WHERE slack_workspace = $1
+          AND summary_date >= $2
+          AND (
+            cardinality($3::text[]) = 0
+            OR EXISTS (
+              SELECT 1 FROM unnest($3::text[]) AS p
+              WHERE origin ILIKE p ESCAPE '\' OR category ILIKE p ESCAPE '\'
+                 OR prompt ILIKE p ESCAPE '\' OR summary ILIKE p ESCAPE '\'
+            )
+          )
+        ORDER BY summary_date DESC
+        LIMIT $4
+    `, workspace, from, pq.Array(patterns), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.SummaryRow
+	for rows.Next() {
+		var s models.SummaryRow
+		if err := rows.Scan(&s.SummaryDate, &s.Origin, &s.Category, &s.Prompt, &s.NComments, &s.Summary); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// Get the latest comments since a time, of rows matching any of the ILIKE patterns if some are given.
+// Patterns escape literal wildcards with a backslash.
+func SearchComments(conn *sql.DB, workspace string, from time.Time, patterns []string, limit int) ([]models.ExploreCommentsResult, error) {
+	rows, err := conn.Query(`
+        SELECT origin, category, prompt, comment, created_at
+        FROM feedback
+        WHERE slack_workspace = $1
+          AND in_production = TRUE
+          AND comment IS NOT NULL
+          AND created_at >= $2
+          AND (
+            cardinality($3::text[]) = 0
+            OR EXISTS (
+              SELECT 1 FROM unnest($3::text[]) AS p
+              WHERE origin ILIKE p ESCAPE '\' OR category ILIKE p ESCAPE '\'
+                 OR prompt ILIKE p ESCAPE '\' OR comment ILIKE p ESCAPE '\'
+            )
+          )
+        ORDER BY created_at DESC
+        LIMIT $4
+    `, workspace, from, pq.Array(patterns), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.ExploreCommentsResult
+	for rows.Next() {
+		var r models.ExploreCommentsResult
+		if err := rows.Scan(&r.Origin, &r.Category, &r.Prompt, &r.Comment, &r.Timestamp); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Get comments with filters, tagging each with the value of the groupBy metadata key if one is given
 func GetCommentsWithFilters(
 	conn *sql.DB,
 	workspace string,
 	from, to time.Time,
-	origin, category, prompt, thumb string,
+	origin, category, prompt, thumb, metaKey, metaVal, groupBy string,
 	limit int,
 ) ([]models.ExploreCommentsResult, error) {
 	query := `
-        SELECT origin, category, prompt, comment, created_at
+        SELECT
+            origin,
+            category,
+            prompt,
+            comment,
+            created_at,
+            CASE WHEN $10 = '' THEN '' ELSE COALESCE(metadata->>$10, '') END AS dimension
         FROM feedback
         WHERE slack_workspace = $1
           AND in_production = TRUE
@@ -735,10 +914,11 @@ func GetCommentsWithFilters(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
+          AND ($8 = '' OR metadata->>$8 = $9)
         ORDER BY created_at DESC
-        LIMIT $8
+        LIMIT $11
     `
-	rows, err := conn.Query(query, workspace, from, to, origin, category, prompt, thumb, limit)
+	rows, err := conn.Query(query, workspace, from, to, origin, category, prompt, thumb, metaKey, metaVal, groupBy, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -747,7 +927,7 @@ func GetCommentsWithFilters(
 	var results []models.ExploreCommentsResult
 	for rows.Next() {
 		var r models.ExploreCommentsResult
-		if err := rows.Scan(&r.Origin, &r.Category, &r.Prompt, &r.Comment, &r.Timestamp); err != nil {
+		if err := rows.Scan(&r.Origin, &r.Category, &r.Prompt, &r.Comment, &r.Timestamp, &r.Dimension); err != nil {
 			return nil, err
 		}
 		results = append(results, r)
@@ -781,298 +961,64 @@ func GetTopIssuesByWorkspace(conn *sql.DB, workspace string) ([]models.IssueRepo
 
 // Calculate thumbs up %, feedback count, and comment count for the current and previous period by origin, category, and prompt
 func GetLast7DayStats(conn *sql.DB, workspace string, origin string, category string, prompt string) (*models.FeedbackStats, error) {
-	query := `
-    WITH
-    current_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND created_at >= (CURRENT_DATE - INTERVAL '7 days')
-            ORDER BY user_id, created_at DESC
-        ) latest
-    ),
-    prev_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND created_at >= (CURRENT_DATE - INTERVAL '14 days')
-              AND DATE(created_at) <= (CURRENT_DATE - INTERVAL '7 days')
-            ORDER BY user_id, created_at DESC
-        ) latest
-    )
-    SELECT
-        CASE WHEN c.num_feedback > 0
-            THEN 100.0 * c.thumbs_up / c.num_feedback
-            ELSE 0 END AS thumbs_up_pct,
-        CASE WHEN p.num_feedback > 0
-            THEN 100.0 * p.thumbs_up / p.num_feedback
-            ELSE 0 END AS prev_thumbs_up_pct,
-        c.num_feedback AS n_feedback,
-        p.num_feedback AS prev_n_feedback,
-		CASE WHEN p.num_feedback > 0
-        	THEN 100.0 * (c.num_feedback - p.num_feedback) / p.num_feedback
-        ELSE 0 END AS prev_n_feedback_dl,
-        c.num_comments,
-		CASE WHEN p.num_comments > 0
-			THEN 100.0 * (c.num_comments - p.num_comments) / p.num_comments
-		ELSE 0 END AS prev_comments_dl
-    FROM
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM current_period
-        ) c,
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM prev_period
-        ) p;
-    `
-
-	var stats models.FeedbackStats
-	err := conn.QueryRow(query, workspace, origin, category, prompt).Scan(
-		&stats.ThumbsUpPct,
-		&stats.PrevThumbsUpPct,
-		&stats.NFeedback,
-		&stats.PrevNFeedback,
-		&stats.PrevNFeedbackDl,
-		&stats.NComments,
-		&stats.PrevCommentsDl,
+	query := periodStatsQuery(
+		`origin = $2 AND category = $3 AND prompt = $4`,
+		`created_at >= (CURRENT_DATE - INTERVAL '7 days')`,
+		`created_at >= (CURRENT_DATE - INTERVAL '14 days') AND DATE(created_at) <= (CURRENT_DATE - INTERVAL '7 days')`,
 	)
-	if err != nil {
-		return nil, err
-	}
-	return &stats, nil
+	return scanPeriodStats(conn.QueryRow(query, workspace, origin, category, prompt))
 }
 
 // Calculate thumbs up %, feedback count, and comment count for the current and previous period by origin, category, and prompt
 func GetLast30DayStats(conn *sql.DB, workspace string, origin string, category string, prompt string) (*models.FeedbackStats, error) {
-	query := `
-    WITH
-    current_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND created_at >= (CURRENT_DATE - INTERVAL '30 days')
-            ORDER BY user_id, created_at DESC
-        ) latest
-    ),
-    prev_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND created_at >= (CURRENT_DATE - INTERVAL '60 days')
-              AND DATE(created_at) <= (CURRENT_DATE - INTERVAL '30 days')
-            ORDER BY user_id, created_at DESC
-        ) latest
-    )
-    SELECT
-        CASE WHEN c.num_feedback > 0
-            THEN 100.0 * c.thumbs_up / c.num_feedback
-            ELSE 0 END AS thumbs_up_pct,
-        CASE WHEN p.num_feedback > 0
-            THEN 100.0 * p.thumbs_up / p.num_feedback
-            ELSE 0 END AS prev_thumbs_up_pct,
-        c.num_feedback AS n_feedback,
-        p.num_feedback AS prev_n_feedback,
-		CASE WHEN p.num_feedback > 0
-        	THEN 100.0 * (c.num_feedback - p.num_feedback) / p.num_feedback
-        ELSE 0 END AS prev_n_feedback_dl,
-        c.num_comments,
-		CASE WHEN p.num_comments > 0
-			THEN 100.0 * (c.num_comments - p.num_comments) / p.num_comments
-		ELSE 0 END AS prev_comments_dl
-    FROM
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM current_period
-        ) c,
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM prev_period
-        ) p;
-    `
-
-	var stats models.FeedbackStats
-	err := conn.QueryRow(query, workspace, origin, category, prompt).Scan(
-		&stats.ThumbsUpPct,
-		&stats.PrevThumbsUpPct,
-		&stats.NFeedback,
-		&stats.PrevNFeedback,
-		&stats.PrevNFeedbackDl,
-		&stats.NComments,
-		&stats.PrevCommentsDl,
+	query := periodStatsQuery(
+		`origin = $2 AND category = $3 AND prompt = $4`,
+		`created_at >= (CURRENT_DATE - INTERVAL '30 days')`,
+		`created_at >= (CURRENT_DATE - INTERVAL '60 days') AND DATE(created_at) <= (CURRENT_DATE - INTERVAL '30 days')`,
 	)
-	if err != nil {
-		return nil, err
-	}
-	return &stats, nil
+	return scanPeriodStats(conn.QueryRow(query, workspace, origin, category, prompt))
 }
 
-// Calculate thumbs up %, feedback count, and comment count for the current and previous period for a given workspace, date range, and filters (origin, category, prompt, thumb)
+// Calculate thumbs up %, feedback count, and comment count for the current and previous period for a given workspace, date range, and filters (origin, category, prompt, thumb, metadata),
+// restricted to the feedback whose groupBy metadata value equals dimension if a groupBy key is given
 func GetFilteredStats(
 	conn *sql.DB,
 	workspace string,
 	origin, category, prompt string,
 	from, to time.Time,
 	prevFrom, prevTo time.Time,
-	thumb string,
+	thumb, metaKey, metaVal string,
+	groupBy, dimension string,
 ) (*models.FeedbackStats, error) {
-	query := `
-    WITH
-    current_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND created_at >= $5
-              AND created_at < $6
-              AND (
-                $9 = '' OR
-                ($9 = 'Up' AND thumb_up = TRUE) OR
-                ($9 = 'Down' AND thumb_up = FALSE)
-              )
-            ORDER BY user_id, created_at DESC
-        ) latest
-    ),
-    prev_period AS (
-        SELECT *
-        FROM (
-            SELECT DISTINCT ON (user_id)
-                user_id,
-                thumb_up,
-                comment,
-                created_at
-            FROM feedback
-            WHERE slack_workspace = $1
-              AND in_production = TRUE
-              AND origin = $2
-              AND category = $3
-              AND prompt = $4
-              AND created_at >= $7
-              AND created_at < $8
-              AND (
-                $9 = '' OR
-                ($9 = 'Up' AND thumb_up = TRUE) OR
-                ($9 = 'Down' AND thumb_up = FALSE)
-              )
-            ORDER BY user_id, created_at DESC
-        ) latest
-    )
-    SELECT
-        CASE WHEN c.num_feedback > 0
-            THEN 100.0 * c.thumbs_up / c.num_feedback
-            ELSE 0 END AS thumbs_up_pct,
-        CASE WHEN p.num_feedback > 0
-            THEN 100.0 * p.thumbs_up / p.num_feedback
-            ELSE 0 END AS prev_thumbs_up_pct,
-        c.num_feedback AS n_feedback,
-        p.num_feedback AS prev_n_feedback,
-        CASE WHEN p.num_feedback > 0
-            THEN 100.0 * (c.num_feedback - p.num_feedback) / p.num_feedback
-        ELSE 0 END AS prev_n_feedback_dl,
-        c.num_comments,
-        CASE WHEN p.num_comments > 0
-            THEN 100.0 * (c.num_comments - p.num_comments) / p.num_comments
-        ELSE 0 END AS prev_comments_dl
-    FROM
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM current_period
-        ) c,
-        (SELECT
-            COUNT(thumb_up) FILTER (WHERE thumb_up IS TRUE) AS thumbs_up,
-            COUNT(thumb_up) AS num_feedback,
-            COUNT(comment) AS num_comments
-         FROM prev_period
-        ) p;
-    `
-
-	var stats models.FeedbackStats
-	err := conn.QueryRow(
+	query := periodStatsQuery(
+		`origin = $2 AND category = $3 AND prompt = $4
+          AND (
+            $9 = '' OR
+            ($9 = 'Up' AND thumb_up = TRUE) OR
+            ($9 = 'Down' AND thumb_up = FALSE)
+          )
+          AND ($10 = '' OR metadata->>$10 = $11)
+          AND ($12 = '' OR COALESCE(metadata->>$12, '') = $13)`,
+		`created_at >= $5 AND created_at < $6`,
+		`created_at >= $7 AND created_at < $8`,
+	)
+	return scanPeriodStats(conn.QueryRow(
 		query,
 		workspace, origin, category, prompt,
 		from, to,
 		prevFrom, prevTo,
-		thumb,
-	).Scan(
-		&stats.ThumbsUpPct,
-		&stats.PrevThumbsUpPct,
-		&stats.NFeedback,
-		&stats.PrevNFeedback,
-		&stats.PrevNFeedbackDl,
-		&stats.NComments,
-		&stats.PrevCommentsDl,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return &stats, nil
+		thumb, metaKey, metaVal,
+		groupBy, dimension,
+	))
 }
 
-// Get raw feedback data for a workspace and time range, with optional filters
+// Get raw feedback data for a workspace and time range, with optional filters and pagination (a limit of 0 returns all rows)
 func GetRawFeedbackData(
 	conn *sql.DB,
 	workspace string,
 	from, to time.Time,
-	origin, category, prompt, thumb string,
+	origin, category, prompt, thumb, metaKey, metaVal string,
+	limit, offset int,
 ) ([]models.RawData, error) {
 	query := `
         SELECT
@@ -1082,7 +1028,10 @@ func GetRawFeedbackData(
             prompt,
             thumb_up,
             comment,
-            user_id
+            user_id,
+            rating,
+            scale,
+            metadata::text
         FROM feedback
         WHERE slack_workspace = $1
           AND in_production = TRUE
@@ -1096,9 +1045,12 @@ func GetRawFeedbackData(
             ($7 = 'Up' AND thumb_up = TRUE) OR
             ($7 = 'Down' AND thumb_up = FALSE)
           )
-        ORDER BY created_at DESC
+          AND ($8 = '' OR metadata->>$8 = $9)
+        ORDER BY created_at DESC, id DESC
+        LIMIT NULLIF($10, 0)
+        OFFSET $11
     `
-	rows, err := conn.Query(query, workspace, from, to, origin, category, prompt, thumb)
+	rows, err := conn.Query(query, workspace, from, to, origin, category, prompt, thumb, metaKey, metaVal, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -1115,6 +1067,9 @@ func GetRawFeedbackData(
 			&fb.ThumbUp,
 			&fb.Comment,
 			&fb.UserID,
+			&fb.Rating,
+			&fb.Scale,
+			&fb.Metadata,
 		)
 		if err != nil {
 			return nil, err
@@ -1137,7 +1092,9 @@ func GetTestFeedbackData(
             prompt,
             thumb_up,
             comment,
-            user_id
+            user_id,
+            rating,
+            scale
         FROM feedback
         WHERE slack_workspace = $1
           AND in_production = FALSE
@@ -1161,6 +1118,8 @@ func GetTestFeedbackData(
 			&fb.ThumbUp,
 			&fb.Comment,
 			&fb.UserID,
+			&fb.Rating,
+			&fb.Scale,
 		)
 		if err != nil {
 			return nil, err
@@ -1169,3 +1128,63 @@ func GetTestFeedbackData(
 	}
 	return results, nil
 }
+
+// Get distinct metadata keys used by feedback for a workspace and time range
+func GetMetadataKeys(conn *sql.DB, workspace string, from, to time.Time) ([]string, error) {
+	query := `
+        SELECT DISTINCT k
+        FROM feedback, jsonb_object_keys(metadata) AS k
+        WHERE slack_workspace = $1
+          AND in_production = TRUE
+          AND metadata IS NOT NULL
+          AND created_at >= $2
+          AND created_at < $3
+        ORDER BY k
+        LIMIT 100
+    `
+	rows, err := conn.Query(query, workspace, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Get distinct values of a metadata key for a workspace and time range
+func GetMetadataValues(conn *sql.DB, workspace string, from, to time.Time, key string) ([]string, error) {
+	query := `
+        SELECT DISTINCT metadata->>$4 AS v
+        FROM feedback
+        WHERE slack_workspace = $1
+          AND in_production = TRUE
+          AND metadata ? $4
+          AND created_at >= $2
+          AND created_at < $3
+        ORDER BY v
+        LIMIT 100
+    `
+	rows, err := conn.Query(query, workspace, from, to, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,279 @@
+// File: internal/queries/webhooks.go
+
+// This file contains queries for outgoing webhooks and their delivery log.
+
+package queries
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"twothumbs/internal/models"
+	"twothumbs/internal/utils"
+)
+
+// *Table: webhooks*
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Get the webhooks of a workspace, along with the outcome of their latest delivery
+func GetWebhooks(conn *sql.DB, workspace string) ([]models.Webhook, error) {
+	rows, err := conn.Query(`
+        SELECT w.id, w.slack_workspace, w.url, w.origins, w.categories, w.thumb, w.created_at,
+               d.status, COALESCE(d.delivered_at, d.next_attempt_at),
+               (SELECT COUNT(*) FROM webhook_deliveries f
+                WHERE f.webhook_id = w.id
+                  AND f.status = 'failed'
+                  AND f.created_at > NOW() - INTERVAL '7 days')
+        FROM webhooks w
+        LEFT JOIN LATERAL (
+            SELECT status, delivered_at, next_attempt_at
+            FROM webhook_deliveries
+            WHERE webhook_id = w.id
+              AND attempts > 0
+            ORDER BY created_at DESC
+            LIMIT 1
+        ) d ON TRUE
+        WHERE w.slack_workspace = $1
+        ORDER BY w.created_at
+    `, workspace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var wh models.Webhook
+		if err := rows.Scan(
+			&wh.ID,
+			&wh.SlackWorkspace,
+			&wh.URL,
+			pq.Array(&wh.Origins),
+			pq.Array(&wh.Categories),
+			&wh.Thumb,
+			&wh.CreatedAt,
+			&wh.LastStatus,
+			&wh.LastAttemptAt,
+			&wh.FailedLastWeek,
+		); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+	return webhooks, rows.Err()
+}
+
+// Create a webhook for a workspace, storing its secret encrypted
+func CreateWebhook(conn *sql.DB, wh *models.Webhook, secret string) error {
+	// The secret is bound to the ID of the webhook, so the ID is taken before inserting
+	var id int64
+	if err := conn.QueryRow(`SELECT nextval(pg_get_serial_sequence('webhooks', 'id'))`).Scan(&id); err != nil {
+		return err
+	}
+	enc, err := utils.EncryptToken(secret, webhookSecretAAD(id))
+	if err != nil {
+		return err
+	}
+	res, err := conn.Exec(`
+        INSERT INTO webhooks (id, slack_workspace, url, secret_ciphertext, secret_data_key, secret_key_id, secret_aad, origins, categories, thumb)
+        SELECT $9, slack_workspace, $2, $3, $4, $5, TRUE, $6, $7, $8
+        FROM account_workspaces
+        WHERE slack_workspace = $1
+    `, wh.SlackWorkspace, wh.URL, enc.Ciphertext, enc.DataKey, enc.KeyID, pq.Array(wh.Origins), pq.Array(wh.Categories), wh.Thumb, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	wh.ID = id
+	return nil
+}
+
+func webhookSecretAAD(webhookID int64) []byte {
+	return utils.TokenAAD("webhooks.secret", strconv.FormatInt(webhookID, 10))
+}
+
+// Delete a webhook of a workspace, along with its delivery log
+func DeleteWebhook(conn *sql.DB, workspace string, webhookID int64) error {
+	res, err := conn.Exec(`DELETE FROM webhooks WHERE id = $1 AND slack_workspace = $2`, webhookID, workspace)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Re-encrypt the data keys of webhook secrets that are not wrapped with the current master key, and
+// re-encrypt secrets that are not yet bound to their webhook, returning the number of rows
+func RewrapWebhookSecrets(conn *sql.DB) (int, error) {
+	kr, err := utils.GetTokenKeyring()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := conn.Query(`
+        SELECT id, secret_ciphertext, secret_data_key, secret_key_id, secret_aad
+        FROM webhooks
+        WHERE secret_key_id <> $1
+           OR NOT secret_aad
+    `, kr.CurrentID())
+	if err != nil {
+		return 0, err
+	}
+	secrets := make(map[int64]*utils.EncryptedToken)
+	for rows.Next() {
+		var id int64
+		var enc utils.EncryptedToken
+		if err := rows.Scan(&id, &enc.Ciphertext, &enc.DataKey, &enc.KeyID, &enc.AADBound); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		secrets[id] = &enc
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for id, enc := range secrets {
+		if !enc.AADBound {
+			// Secrets encrypted before they were bound to their webhook are encrypted again
+			secret, err := utils.DecryptToken(enc, nil)
+			if err != nil {
+				return n, fmt.Errorf("webhook %d: %w", id, err)
+			}
+			bound, err := utils.EncryptToken(secret, webhookSecretAAD(id))
+			if err != nil {
+				return n, fmt.Errorf("webhook %d: %w", id, err)
+			}
+			_, err = conn.Exec(`
+                UPDATE webhooks
+                SET secret_ciphertext = $2, secret_data_key = $3, secret_key_id = $4, secret_aad = TRUE
+                WHERE id = $1 AND NOT secret_aad
+            `, id, bound.Ciphertext, bound.DataKey, bound.KeyID)
+			if err != nil {
+				return n, err
+			}
+			n++
+			continue
+		}
+		rewrapped, changed, err := utils.RewrapToken(enc)
+		if err != nil {
+			return n, fmt.Errorf("webhook %d: %w", id, err)
+		}
+		if !changed {
+			continue
+		}
+		_, err = conn.Exec(`
+            UPDATE webhooks
+            SET secret_data_key = $2, secret_key_id = $3
+            WHERE id = $1 AND secret_key_id = $4
+        `, id, rewrapped.DataKey, rewrapped.KeyID, enc.KeyID)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// *Table: webhook_deliveries*
+
+// Queue a delivery of a feedback record to each webhook of its workspace whose filters match. This runs in
+// the transaction that inserts the feedback (an outbox), so that a delivery is queued if and only if the
+// feedback is stored.
+func enqueueWebhookDeliveries(tx *sql.Tx, fb *models.Feedback) error {
+	payload, err := utils.FeedbackCreatedPayload(fb)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	_, err = tx.Exec(`
+        INSERT INTO webhook_deliveries (webhook_id, feedback_id, payload)
+        SELECT id, $2, $3
+        FROM webhooks
+        WHERE slack_workspace = $1
+          AND (cardinality(origins) = 0 OR $4 = ANY(origins))
+          AND (cardinality(categories) = 0 OR $5 = ANY(categories))
+          AND (thumb = 'any' OR (thumb = 'up') = $6)
+    `, fb.SlackWorkspace, fb.ID, payload, fb.Origin, fb.Category, fb.ThumbUp)
+	return err
+}
+
+// Claim up to limit due deliveries for an attempt. Claimed deliveries are leased, so that they are
+// attempted again after the lease if the attempt never completes. A delivery whose webhook secret
+// cannot be decrypted is returned with SecretErr set, so that it can be failed on its own.
+func ClaimWebhookDeliveries(conn *sql.DB, limit int, leaseSecs int) ([]models.WebhookDelivery, error) {
+	rows, err := conn.Query(`
+        UPDATE webhook_deliveries d
+        SET attempts = d.attempts + 1,
+            next_attempt_at = NOW() + make_interval(secs => $2)
+        FROM webhooks w
+        WHERE w.id = d.webhook_id
+          AND d.id IN (
+            SELECT id FROM webhook_deliveries
+            WHERE status = 'pending'
+              AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+          )
+        RETURNING d.id, d.webhook_id, w.url, w.secret_ciphertext, w.secret_data_key, w.secret_key_id, w.secret_aad, d.payload, d.attempts
+    `, limit, leaseSecs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var enc utils.EncryptedToken
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.URL, &enc.Ciphertext, &enc.DataKey, &enc.KeyID, &enc.AADBound, &d.Payload, &d.Attempts); err != nil {
+			return nil, err
+		}
+		if d.Secret, err = utils.DecryptToken(&enc, webhookSecretAAD(d.WebhookID)); err != nil {
+			d.SecretErr = fmt.Errorf("failed to decrypt the secret of webhook %d: %w", d.WebhookID, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Record a successful delivery attempt
+func CompleteWebhookDelivery(conn *sql.DB, deliveryID int64, statusCode int) error {
+	_, err := conn.Exec(`
+        UPDATE webhook_deliveries
+        SET status = 'delivered',
+            delivered_at = NOW(),
+            last_status_code = $2,
+            last_error = NULL
+        WHERE id = $1
+    `, deliveryID, statusCode)
+	return err
+}
+
+// Record a failed delivery attempt, to be retried at retryAt, or given up on if retryAt is nil
+func FailWebhookDelivery(conn *sql.DB, deliveryID int64, statusCode *int, errMsg string, retryAt *time.Time) error {
+	status := "pending"
+	if retryAt == nil {
+		status = "failed"
+	}
+	_, err := conn.Exec(`
+        UPDATE webhook_deliveries
+        SET status = $2,
+            next_attempt_at = COALESCE($3, next_attempt_at),
+            last_status_code = $4,
+            last_error = $5
+        WHERE id = $1
+    `, deliveryID, status, retryAt, statusCode, errMsg)
+	return err
+}
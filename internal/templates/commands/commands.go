@@ -0,0 +1,81 @@
+// File: internal/templates/commands/commands.go
+
+// This file contains the ephemeral replies to the /twothumbs slash command.
+
+package commands
+
+import (
+	"twothumbs/internal/utils"
+)
+
+// Slack's limit of blocks per message
+const maxBlocks = 50
+
+// Ephemeral reply with a header, cut short if the blocks exceed Slack's limit
+func EphemeralResponse(title string, blocks []map[string]any) map[string]any {
+	all := []map[string]any{
+		{
+			"type": "header",
+			"text": map[string]any{
+				"type": "plain_text",
+				"text": title,
+			},
+		},
+	}
+	if len(blocks) > maxBlocks-2 {
+		blocks = append(blocks[:maxBlocks-2:maxBlocks-2], map[string]any{
+			"type": "context",
+			"elements": []map[string]any{
+				{
+					"type": "mrkdwn",
+					"text": "_Kindly note: There is more than fits in one message. Open the Two Thumbs App Home to see everything._",
+				},
+			},
+		})
+	}
+	all = append(all, blocks...)
+
+	return map[string]any{
+		"response_type": "ephemeral",
+		"blocks":        all,
+	}
+}
+
+// Ephemeral reply with a plain message
+func MessageResponse(text string) map[string]any {
+	return map[string]any{
+		"response_type": "ephemeral",
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{
+					"type": "mrkdwn",
+					"text": text,
+				},
+			},
+		},
+	}
+}
+
+func HelpBlocks() []map[string]any {
+	return []map[string]any{
+		{
+			"type": "section",
+			"text": map[string]any{
+				"type": "mrkdwn",
+				"text": "Check your feedback from any channel. Only you can see the replies.",
+			},
+		},
+		utils.Spacer(),
+		{
+			"type": "section",
+			"text": map[string]any{
+				"type": "mrkdwn",
+				"text": "`/twothumbs stats [origin] [7d|30d]`\nStats of the last 7 days (default) or 30 days, of all origins or a single one\n\n" +
+					"`/twothumbs comments [origin]`\nThe latest comments, of all origins or a single one\n\n" +
+					"`/twothumbs issues`\nThe top issues of the last 30 days\n\n" +
+					"`/twothumbs help`\nThis help",
+			},
+		},
+	}
+}
@@ -0,0 +1,129 @@
+// File: internal/templates/digests/anomaly.go
+
+// This file contains the Block Kit template for anomaly alerts.
+
+package digests
+
+import (
+	"fmt"
+	"strings"
+
+	"twothumbs/internal/models"
+)
+
+func BuildAnomalyAlertBlocks(anomalies []models.Anomaly) []map[string]any {
+	// Main header
+	blocks := []map[string]any{
+		{
+			"type": "header",
+			"text": map[string]any{
+				"type": "plain_text",
+				"text": "Anomaly Alert  🔎",
+			},
+		},
+		{
+			"type": "context",
+			"elements": []map[string]any{
+				{
+					"type": "mrkdwn",
+					"text": "_Yesterday's feedback differs significantly from the days before it:_",
+				},
+			},
+		},
+	}
+
+	// Content
+	for _, a := range anomalies {
+		var findings []string
+		if a.RateShift {
+			findings = append(findings, AnomalyRateText(a))
+		}
+		if a.VolumeShift {
+			findings = append(findings, AnomalyVolumeText(a))
+		}
+		blocks = append(blocks,
+			map[string]any{
+				"type": "divider",
+			},
+			map[string]any{
+				"type": "section",
+				"text": map[string]any{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf(
+						"*%s*  ·  %s\n>_%s_\n\n%s",
+						a.Group.Origin, a.Group.Category, a.Group.Prompt, strings.Join(findings, "\n"),
+					),
+				},
+			},
+		)
+		if len(a.Comments) > 0 {
+			var quote []map[string]any
+			for i, comment := range a.Comments {
+				text := comment
+				if i < len(a.Comments)-1 {
+					text += "\n"
+				}
+				quote = append(quote, map[string]any{
+					"type": "text",
+					"text": text,
+				})
+			}
+			blocks = append(blocks, map[string]any{
+				"type": "rich_text",
+				"elements": []map[string]any{
+					{
+						"type":     "rich_text_quote",
+						"elements": quote,
+					},
+				},
+			})
+		}
+	}
+
+	// Footer
+	blocks = append(
+		blocks,
+		DigestFooter()...,
+	)
+
+	return blocks
+}
+
+func AnomalyRateText(a models.Anomaly) string {
+	arrow := "📈"
+	if a.RateZ < 0 {
+		arrow = "📉"
+	}
+	return fmt.Sprintf(
+		"%s Thumbs up: *%.0f%%* vs. %.0f%% _(%d vs. %d responses over %d days, %s)_",
+		arrow,
+		100*float64(a.RecentUp)/float64(a.RecentN),
+		100*float64(a.BaselineUp)/float64(a.BaselineN),
+		a.RecentN,
+		a.BaselineN,
+		a.BaselineDays,
+		FormatPValue(a.RateP),
+	)
+}
+
+func AnomalyVolumeText(a models.Anomaly) string {
+	arrow := "📈"
+	if a.VolumeZ < 0 {
+		arrow = "📉"
+	}
+	return fmt.Sprintf(
+		"%s Responses: *%d* vs. %.1f per day _(over %d days, %s)_",
+		arrow,
+		a.RecentN,
+		a.ExpectedN,
+		a.BaselineDays,
+		FormatPValue(a.VolumeP),
+	)
+}
+
+func FormatPValue(p float64) string {
+	if p < 0.001 {
+		return "p < 0.001"
+	}
+	return fmt.Sprintf("p = %.3f", p)
+}
@@ -4,6 +4,13 @@
 
 package digests
 
+import (
+	"fmt"
+	"strings"
+
+	"twothumbs/internal/models"
+)
+
 func DigestFooter() []map[string]any {
 	return []map[string]any{
 		{
@@ -32,3 +39,52 @@ func DigestFooter() []map[string]any {
 		},
 	}
 }
+
+// Note on how many comments the AI summaries of digests are based on, and on the summaries that could
+// not be generated, with the coverage of each digest given by the coverage function. Empty if there is
+// nothing to note.
+func CoverageNote[T any](digests []T, coverage func(T) models.InputCoverage) []map[string]any {
+	var comments, included, failed int
+	for _, d := range digests {
+		c := coverage(d)
+		if c.Failed {
+			failed++
+			continue
+		}
+		comments += c.Comments
+		included += c.IncludedComments
+	}
+
+	var notes []string
+	if comments > 0 {
+		noun := "comments"
+		if comments == 1 {
+			noun = "comment"
+		}
+		if included < comments {
+			notes = append(notes, fmt.Sprintf("_AI summaries are based on %d of %d %s (%d%%), the rest were left out to limit AI costs._", included, comments, noun, included*100/comments))
+		} else {
+			notes = append(notes, fmt.Sprintf("_AI summaries are based on all %d %s._", comments, noun))
+		}
+	}
+	if failed == 1 {
+		notes = append(notes, "_1 summary could not be generated._")
+	} else if failed > 1 {
+		notes = append(notes, fmt.Sprintf("_%d summaries could not be generated._", failed))
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+
+	return []map[string]any{
+		{
+			"type": "context",
+			"elements": []map[string]any{
+				{
+					"type": "mrkdwn",
+					"text": strings.Join(notes, " "),
+				},
+			},
+		},
+	}
+}
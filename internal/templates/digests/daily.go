@@ -64,6 +64,9 @@ func BuildDailyDigestBlocks(digests []models.DailyDigestData) []map[string]any {
 		}
 	}
 
+	// Note on the input behind the summaries
+	blocks = append(blocks, CoverageNote(digests, func(d models.DailyDigestData) models.InputCoverage { return d.Coverage })...)
+
 	// Footer
 	blocks = append(
 		blocks,
@@ -0,0 +1,100 @@
+// File: internal/templates/digests/live.go
+
+// This file contains the Block Kit template for live alerts.
+
+package digests
+
+import (
+	"fmt"
+
+	"twothumbs/internal/models"
+)
+
+// Build the alert of new thumbs-down comments for an origin, showing at most maxComments of them
+func BuildLiveAlertBlocks(origin string, alerts []models.LiveAlert, maxComments int) []map[string]any {
+	commentWord := "comments"
+	if len(alerts) == 1 {
+		commentWord = "comment"
+	}
+
+	// Main header
+	blocks := []map[string]any{
+		{
+			"type": "header",
+			"text": map[string]any{
+				"type": "plain_text",
+				"text": "Live Alert  🚨",
+			},
+		},
+		{
+			"type": "section",
+			"text": map[string]any{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s* _(%d new thumbs-down %s)_", origin, len(alerts), commentWord),
+			},
+		},
+	}
+
+	// Content
+	for i, a := range alerts {
+		if i == maxComments {
+			blocks = append(blocks, map[string]any{
+				"type": "context",
+				"elements": []map[string]any{
+					{
+						"type": "mrkdwn",
+						"text": fmt.Sprintf("_…and %d more in Explore Feedback_", len(alerts)-maxComments),
+					},
+				},
+			})
+			break
+		}
+		blocks = append(blocks,
+			map[string]any{
+				"type": "context",
+				"elements": []map[string]any{
+					{
+						"type": "mrkdwn",
+						"text": fmt.Sprintf("%s  ·  %s  ·  %s", liveAlertRating(a), a.Category, a.Prompt),
+					},
+				},
+			},
+			map[string]any{
+				"type": "rich_text",
+				"elements": []map[string]any{
+					{
+						"type": "rich_text_quote",
+						"elements": []map[string]any{
+							{
+								"type": "text",
+								"text": a.Comment,
+							},
+						},
+					},
+				},
+			},
+		)
+	}
+
+	// Footer
+	blocks = append(
+		blocks,
+		DigestFooter()...,
+	)
+
+	return blocks
+}
+
+// Rating of an alert on the scale of its prompt
+func liveAlertRating(a models.LiveAlert) string {
+	if a.Rating == nil {
+		return "👎"
+	}
+	switch a.Scale {
+	case models.ScaleCSAT:
+		return fmt.Sprintf("👎 %d/5", *a.Rating)
+	case models.ScaleNPS:
+		return fmt.Sprintf("👎 %d/10", *a.Rating)
+	}
+	return "👎"
+}
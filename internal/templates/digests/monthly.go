@@ -64,8 +64,9 @@ func BuildMonthlyDigestBlocks(digests []models.MonthlyDigestData, monthLabel str
 					"type": "mrkdwn",
 					"text": MonthlyBlockText(
 						d.Category,
+						d.Scale,
+						d.MeanRating,
 						d.Score,
-						d.ScoreDelta,
 						d.NResponses,
 						d.NResponsesDelta,
 						d.NComments,
@@ -103,6 +104,14 @@ func BuildMonthlyDigestBlocks(digests []models.MonthlyDigestData, monthLabel str
 		)
 	}
 
+	// Note on the confidence of the stats
+	if len(digests) > 0 {
+		blocks = append(blocks, utils.ConfidenceNote())
+	}
+
+	// Note on the input behind the summaries
+	blocks = append(blocks, CoverageNote(digests, func(d models.MonthlyDigestData) models.InputCoverage { return d.Coverage })...)
+
 	// Footer
 	blocks = append(
 		blocks,
@@ -114,18 +123,18 @@ func BuildMonthlyDigestBlocks(digests []models.MonthlyDigestData, monthLabel str
 
 func MonthlyBlockText(
 	category string,
-	score int,
-	scoreDelta string,
+	scale string,
+	meanRating float64,
+	score models.Score,
 	nResponses int,
 	nResponsesDelta string,
 	nComments int,
 	nCommentsDelta string,
 ) string {
 	return fmt.Sprintf(
-		"*%s*\n\n\n👍   %d%% (%s%%)    👋   %d (%s%%)    💬   %d (%s%%)",
+		"*%s*\n\n\n%s    👋   %d (%s%%)    💬   %d (%s%%)",
 		category,
-		score,
-		scoreDelta,
+		utils.FormatScore(scale, meanRating, score, "    "),
 		nResponses,
 		nResponsesDelta,
 		nComments,
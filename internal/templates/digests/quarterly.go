@@ -76,6 +76,9 @@ func BuildQuarterlyDigestBlocks(digests []models.QuarterlyDigestData, quarterLab
 		)
 	}
 
+	// Note on the input behind the summaries
+	blocks = append(blocks, CoverageNote(digests, func(d models.QuarterlyDigestData) models.InputCoverage { return d.Coverage })...)
+
 	// Footer
 	blocks = append(
 		blocks,
@@ -71,8 +71,9 @@ func BuildWeeklyDigestBlocks(digests []models.WeeklyDigestData) []map[string]any
 					"type": "mrkdwn",
 					"text": utils.FormatDigestStats(
 						d.Prompt,
+						d.Scale,
+						d.MeanRating,
 						d.Score,
-						d.ScoreDelta,
 						d.NResponses,
 						d.NResponsesDelta,
 						d.NComments,
@@ -99,6 +100,14 @@ func BuildWeeklyDigestBlocks(digests []models.WeeklyDigestData) []map[string]any
 		)
 	}
 
+	// Note on the confidence of the stats
+	if len(digests) > 0 {
+		blocks = append(blocks, utils.ConfidenceNote())
+	}
+
+	// Note on the input behind the summaries
+	blocks = append(blocks, CoverageNote(digests, func(d models.WeeklyDigestData) models.InputCoverage { return d.Coverage })...)
+
 	// Footer
 	blocks = append(
 		blocks,
@@ -24,12 +24,18 @@ func ExploreBlocks(
 	selectedCategory string,
 	selectedPrompt string,
 	selectedThumb string,
+	metaKeys []string,
+	metaVals []string,
+	selectedMetaKey string,
+	selectedMetaVal string,
+	selectedGroupBy string,
 	expanded bool,
 ) []map[string]any {
 	if expanded {
 		return exploreBlocksExpanded(
 			origins, categories, prompts, dateFrom, dateTo, dateInitial,
 			numFeedbackItems, numComments, selectedOrigin, selectedCategory, selectedPrompt, selectedThumb,
+			metaKeys, metaVals, selectedMetaKey, selectedMetaVal, selectedGroupBy,
 		)
 	}
 	return exploreBlocksDefault()
@@ -156,6 +162,11 @@ func exploreBlocksExpanded(
 	selectedCategory string,
 	selectedPrompt string,
 	selectedThumb string,
+	metaKeys []string,
+	metaVals []string,
+	selectedMetaKey string,
+	selectedMetaVal string,
+	selectedGroupBy string,
 ) []map[string]any {
 	blocks := []map[string]any{
 		{
@@ -267,6 +278,57 @@ func exploreBlocksExpanded(
 		"elements": thumbRow,
 	})
 
+	// Metadata filter and group-by rows, shown once feedback carries metadata
+	if len(metaKeys) > 0 {
+		metaRow := []map[string]any{
+			staticSelectBlock(
+				"select-meta-key",
+				fmt.Sprintf("Metadata (%d)", len(metaKeys)),
+				metaKeys,
+				selectedMetaKey,
+			),
+		}
+		if selectedMetaKey != "" {
+			metaRow = append(metaRow, staticSelectBlock(
+				"select-meta-value",
+				fmt.Sprintf("Value (%d)", len(metaVals)),
+				metaVals,
+				selectedMetaVal,
+			))
+			metaRow = append(metaRow, map[string]any{
+				"type":      "button",
+				"text":      map[string]any{"type": "plain_text", "text": "Clear"},
+				"action_id": "clear-meta",
+				"style":     "danger",
+			})
+		}
+		blocks = append(blocks, map[string]any{
+			"type":     "actions",
+			"elements": metaRow,
+		})
+
+		groupByRow := []map[string]any{
+			staticSelectBlock(
+				"select-group-by",
+				"Group by",
+				metaKeys,
+				selectedGroupBy,
+			),
+		}
+		if selectedGroupBy != "" {
+			groupByRow = append(groupByRow, map[string]any{
+				"type":      "button",
+				"text":      map[string]any{"type": "plain_text", "text": "Clear"},
+				"action_id": "clear-group-by",
+				"style":     "danger",
+			})
+		}
+		blocks = append(blocks, map[string]any{
+			"type":     "actions",
+			"elements": groupByRow,
+		})
+	}
+
 	// Date range section
 	blocks = append(blocks, map[string]any{
 		"type": "section",
@@ -80,20 +80,33 @@ func PromptsBlocks(prompts []models.Prompt, maxPromptCount int) []map[string]any
 		)
 	} else {
 		for i, p := range prompts {
+			text := fmt.Sprintf(
+				"Origin: %s\nCategory: %s\n\n>_%s_",
+				p.Origin, p.Category, p.Prompt,
+			)
+			liveButton := map[string]any{
+				"type":      "button",
+				"text":      map[string]any{"type": "plain_text", "text": "Turn On Live Alerts"},
+				"action_id": "toggle-live-prompt",
+				"value":     fmt.Sprintf("%d:on", p.ID),
+			}
+			if p.Live {
+				text += "\n\n🚨 _Live: new thumbs-down comments are posted to the output channel within a minute_"
+				liveButton["text"] = map[string]any{"type": "plain_text", "text": "Turn Off Live Alerts"}
+				liveButton["value"] = fmt.Sprintf("%d:off", p.ID)
+			}
 			blocks = append(blocks,
 				map[string]any{
 					"type": "section",
 					"text": map[string]any{
 						"type": "mrkdwn",
-						"text": fmt.Sprintf(
-							"Origin: %s\nCategory: %s\n\n>_%s_",
-							p.Origin, p.Category, p.Prompt,
-						),
+						"text": text,
 					},
 				},
 				map[string]any{
 					"type": "actions",
 					"elements": []map[string]any{
+						liveButton,
 						{
 							"type":      "button",
 							"text":      map[string]any{"type": "plain_text", "text": "Delete"},
@@ -6,10 +6,13 @@ package home
 
 import (
 	"fmt"
+	"strings"
+
+	"twothumbs/internal/models"
 	"twothumbs/internal/utils"
 )
 
-func SettingsBlocks(channel, apiKey string) []map[string]any {
+func SettingsBlocks(channel string, keys []models.APIKey, maxKeys int, rotationGraceSecs int, webhooks []models.Webhook, maxWebhooks int) []map[string]any {
 	channelSelect := map[string]any{
 		"type": "channels_select",
 		"placeholder": map[string]any{
@@ -50,7 +53,7 @@ func SettingsBlocks(channel, apiKey string) []map[string]any {
 		})
 	}
 
-	return []map[string]any{
+	blocks := []map[string]any{
 		{
 			"type": "actions",
 			"elements": []map[string]any{
@@ -114,46 +117,26 @@ func SettingsBlocks(channel, apiKey string) []map[string]any {
 			"type": "header",
 			"text": map[string]any{
 				"type": "plain_text",
-				"text": "API Key  🔑",
+				"text": "API Keys  🔑",
 			},
 		},
 		utils.Spacer(),
+	}
+	blocks = append(blocks, apiKeyBlocks(keys, maxKeys, rotationGraceSecs)...)
+	blocks = append(blocks, []map[string]any{
+		utils.Spacer(),
 		{
-			"type": "section",
+			"type": "header",
 			"text": map[string]any{
 				"type": "plain_text",
-				"text": fmt.Sprintf("Your current API key: %s", apiKey),
-			},
-		},
-		{
-			"type": "actions",
-			"elements": []map[string]any{
-				{
-					"type":      "button",
-					"text":      map[string]any{"type": "plain_text", "text": "Rotate API Key"},
-					"action_id": "rotate-api-key",
-					"confirm": map[string]any{
-						"title": map[string]any{
-							"type": "plain_text",
-							"text": "Rotate API Key?",
-						},
-						"text": map[string]any{
-							"type": "plain_text",
-							"text": "Are you sure you want to rotate your API key? This will invalidate your current key and generate a new one.",
-						},
-						"confirm": map[string]any{
-							"type": "plain_text",
-							"text": "Rotate",
-						},
-						"deny": map[string]any{
-							"type": "plain_text",
-							"text": "Cancel",
-						},
-					},
-				},
+				"text": "Webhooks  🪝",
 			},
 		},
 		utils.Spacer(),
+	}...)
+	blocks = append(blocks, webhookBlocks(webhooks, maxWebhooks)...)
+	blocks = append(blocks, []map[string]any{
+		utils.Spacer(),
 		{
 			"type": "header",
 			"text": map[string]any{
@@ -179,5 +162,232 @@ func SettingsBlocks(channel, apiKey string) []map[string]any {
 				},
 			},
 		},
+	}...)
+
+	return blocks
+}
+
+// Build a section with rotate and revoke buttons for each API key, followed by the create button
+func apiKeyBlocks(keys []models.APIKey, maxKeys int, rotationGraceSecs int) []map[string]any {
+	blocks := []map[string]any{
+		{
+			"type": "section",
+			"text": map[string]any{
+				"type": "plain_text",
+				"text": "Keys authenticate requests to the API. Rotating a key creates a new one with the same name and scopes, while the old key stays valid for a grace period.",
+			},
+		},
 	}
+
+	for _, k := range keys {
+		lastUsed := "Never used"
+		if k.LastUsedAt != nil {
+			lastUsed = fmt.Sprintf("Last used %s ago", utils.TimeToAgo(*k.LastUsedAt))
+		}
+		text := fmt.Sprintf(
+			"*%s*   `%s…`\nScopes: %s\nCreated %s  ·  %s",
+			k.Name,
+			k.Prefix,
+			strings.Join(k.Scopes, ", "),
+			k.CreatedAt.Format("2006-01-02"),
+			lastUsed,
+		)
+		if k.ExpiresAt != nil {
+			text += fmt.Sprintf("\n⏳ Rotated, valid until %s UTC", k.ExpiresAt.UTC().Format("2006-01-02 15:04"))
+		}
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]any{
+				"type": "mrkdwn",
+				"text": text,
+			},
+		})
+
+		keyActions := []map[string]any{}
+		if k.ExpiresAt == nil {
+			keyActions = append(keyActions, map[string]any{
+				"type":      "button",
+				"text":      map[string]any{"type": "plain_text", "text": "Rotate"},
+				"action_id": "rotate-api-key",
+				"value":     fmt.Sprintf("%d", k.ID),
+				"confirm": map[string]any{
+					"title": map[string]any{
+						"type": "plain_text",
+						"text": "Rotate API Key?",
+					},
+					"text": map[string]any{
+						"type": "plain_text",
+						"text": fmt.Sprintf("A new key named %s will be generated. The current key stays valid for %s, so please switch your clients over in the meantime.", k.Name, utils.FormatDuration(rotationGraceSecs)),
+					},
+					"confirm": map[string]any{
+						"type": "plain_text",
+						"text": "Rotate",
+					},
+					"deny": map[string]any{
+						"type": "plain_text",
+						"text": "Cancel",
+					},
+				},
+			})
+		}
+		keyActions = append(keyActions, map[string]any{
+			"type":      "button",
+			"text":      map[string]any{"type": "plain_text", "text": "Revoke"},
+			"action_id": "revoke-api-key",
+			"value":     fmt.Sprintf("%d", k.ID),
+			"style":     "danger",
+			"confirm": map[string]any{
+				"title": map[string]any{
+					"type": "plain_text",
+					"text": "Revoke API Key?",
+				},
+				"text": map[string]any{
+					"type": "plain_text",
+					"text": fmt.Sprintf("Requests using the key named %s will be rejected immediately. This action cannot be undone.", k.Name),
+				},
+				"confirm": map[string]any{
+					"type": "plain_text",
+					"text": "Revoke",
+				},
+				"deny": map[string]any{
+					"type": "plain_text",
+					"text": "Cancel",
+				},
+			},
+		})
+		blocks = append(blocks, map[string]any{
+			"type":     "actions",
+			"elements": keyActions,
+		})
+	}
+
+	if len(keys) < maxKeys {
+		blocks = append(blocks, map[string]any{
+			"type": "actions",
+			"elements": []map[string]any{
+				{
+					"type":      "button",
+					"text":      map[string]any{"type": "plain_text", "text": "Create API Key"},
+					"action_id": "create-api-key",
+				},
+			},
+		})
+	} else {
+		blocks = append(blocks, map[string]any{
+			"type": "context",
+			"elements": []map[string]any{
+				{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf("_You have reached the limit of %d API keys. Please revoke a key to create a new one._", maxKeys),
+				},
+			},
+		})
+	}
+
+	return blocks
+}
+
+// Build a section with a delete button for each webhook, followed by the create button
+func webhookBlocks(webhooks []models.Webhook, maxWebhooks int) []map[string]any {
+	blocks := []map[string]any{
+		{
+			"type": "section",
+			"text": map[string]any{
+				"type": "plain_text",
+				"text": "Webhooks receive each new feedback record matching their filters as a signed JSON POST request. Failed deliveries are retried with increasing delays.",
+			},
+		},
+	}
+
+	for _, wh := range webhooks {
+		filters := []string{}
+		if len(wh.Origins) > 0 {
+			filters = append(filters, "Origins: "+strings.Join(wh.Origins, ", "))
+		}
+		if len(wh.Categories) > 0 {
+			filters = append(filters, "Categories: "+strings.Join(wh.Categories, ", "))
+		}
+		switch wh.Thumb {
+		case models.WebhookThumbUp:
+			filters = append(filters, "Thumbs up 👍")
+		case models.WebhookThumbDown:
+			filters = append(filters, "Thumbs down 👎")
+		}
+		if len(filters) == 0 {
+			filters = append(filters, "All feedback")
+		}
+
+		lastDelivery := "No deliveries yet"
+		if wh.LastStatus != nil && wh.LastAttemptAt != nil {
+			switch *wh.LastStatus {
+			case "delivered":
+				lastDelivery = fmt.Sprintf("✅ Last delivered %s ago", utils.TimeToAgo(*wh.LastAttemptAt))
+			case "failed":
+				lastDelivery = "❌ Last delivery failed"
+			default:
+				lastDelivery = "⏳ Last delivery is being retried"
+			}
+		}
+		if wh.FailedLastWeek > 0 {
+			lastDelivery += fmt.Sprintf("  ·  %d failed in the last 7 days", wh.FailedLastWeek)
+		}
+
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]any{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s*\n%s\n%s", wh.URL, strings.Join(filters, "  ·  "), lastDelivery),
+			},
+			"accessory": map[string]any{
+				"type":      "button",
+				"text":      map[string]any{"type": "plain_text", "text": "Delete"},
+				"action_id": "delete-webhook",
+				"value":     fmt.Sprintf("%d", wh.ID),
+				"style":     "danger",
+				"confirm": map[string]any{
+					"title": map[string]any{
+						"type": "plain_text",
+						"text": "Delete Webhook?",
+					},
+					"text": map[string]any{
+						"type": "plain_text",
+						"text": "Feedback will no longer be sent to this URL, and pending deliveries will be dropped. This action cannot be undone.",
+					},
+					"confirm": map[string]any{
+						"type": "plain_text",
+						"text": "Delete",
+					},
+					"deny": map[string]any{
+						"type": "plain_text",
+						"text": "Cancel",
+					},
+				},
+			},
+		})
+	}
+
+	if len(webhooks) < maxWebhooks {
+		blocks = append(blocks, map[string]any{
+			"type": "actions",
+			"elements": []map[string]any{
+				{
+					"type":      "button",
+					"text":      map[string]any{"type": "plain_text", "text": "Create Webhook"},
+					"action_id": "create-webhook",
+				},
+			},
+		})
+	} else {
+		blocks = append(blocks, map[string]any{
+			"type": "context",
+			"elements": []map[string]any{
+				{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf("_You have reached the limit of %d webhooks. Please delete a webhook to create a new one._", maxWebhooks),
+				},
+			},
+		})
+	}
+
+	return blocks
 }
@@ -20,7 +20,7 @@ func WelcomeBlocks() []map[string]any {
 			"type": "section",
 			"text": map[string]any{
 				"type": "plain_text",
-				"text": "To get started, link your Slack workspace to your Two Thumbs account. Just submit the activation code found in the welcome email and we are good to go.",
+				"text": "To get started, link your Slack workspace to your Two Thumbs account. Just submit the activation code found in the welcome email and we are good to go. On Enterprise Grid, the same code links further workspaces of your organization.",
 			},
 		},
 		{
@@ -0,0 +1,125 @@
+// File: internal/templates/modals/api_keys.go
+
+// This file contains the modal templates for creating API keys.
+
+package modals
+
+import (
+	"fmt"
+
+	"twothumbs/internal/models"
+	"twothumbs/internal/utils"
+)
+
+func CreateAPIKeyModal() map[string]any {
+	scopeOptions := []map[string]any{
+		{
+			"text":        map[string]any{"type": "plain_text", "text": models.ScopeWriteFeedback},
+			"description": map[string]any{"type": "plain_text", "text": "Submit feedback"},
+			"value":       models.ScopeWriteFeedback,
+		},
+		{
+			"text":        map[string]any{"type": "plain_text", "text": models.ScopeReadData},
+			"description": map[string]any{"type": "plain_text", "text": "Query feedback and stats"},
+			"value":       models.ScopeReadData,
+		},
+		{
+			"text":        map[string]any{"type": "plain_text", "text": models.ScopeAdmin},
+			"description": map[string]any{"type": "plain_text", "text": "All of the above"},
+			"value":       models.ScopeAdmin,
+		},
+	}
+
+	return map[string]any{
+		"type":        "modal",
+		"callback_id": "create-api-key",
+		"title": map[string]any{
+			"type": "plain_text",
+			"text": "Create API Key  🔑",
+		},
+		"submit": map[string]any{
+			"type": "plain_text",
+			"text": "Create",
+		},
+		"close": map[string]any{
+			"type": "plain_text",
+			"text": "Cancel",
+		},
+		"blocks": []map[string]any{
+			{
+				"type":     "input",
+				"block_id": "api-key-name-block",
+				"element": map[string]any{
+					"type":        "plain_text_input",
+					"action_id":   "api-key-name",
+					"placeholder": map[string]any{"type": "plain_text", "text": "e.g. Web App"},
+				},
+				"label": map[string]any{
+					"type": "plain_text",
+					"text": "Name",
+				},
+			},
+			{
+				"type":     "input",
+				"block_id": "api-key-scopes-block",
+				"element": map[string]any{
+					"type":            "checkboxes",
+					"action_id":       "api-key-scopes",
+					"options":         scopeOptions,
+					"initial_options": []map[string]any{scopeOptions[0]},
+				},
+				"label": map[string]any{
+					"type": "plain_text",
+					"text": "Scopes",
+				},
+			},
+			utils.Spacer(),
+		},
+	}
+}
+
+func APIKeyCreatedModal(name, apiKey string) map[string]any {
+	return map[string]any{
+		"type": "modal",
+		"title": map[string]any{
+			"type": "plain_text",
+			"text": "Done  ✅",
+		},
+		"close": map[string]any{
+			"type": "plain_text",
+			"text": "Close",
+		},
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf("Here is the API key *%s*:\n\n`%s`\n\nPlease copy it now. For your security, it is stored hashed and cannot be shown again.", name, apiKey),
+				},
+			},
+		},
+	}
+}
+
+func APIKeyErrorModal() map[string]any {
+	return map[string]any{
+		"type": "modal",
+		"title": map[string]any{
+			"type": "plain_text",
+			"text": "Ouch  🤕",
+		},
+		"close": map[string]any{
+			"type": "plain_text",
+			"text": "Close",
+		},
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{
+					"type": "plain_text",
+					"text": "There was an error creating the API key. Please try again and contact our support if the error persists. We are truly sorry for the inconvenience.",
+				},
+			},
+		},
+	}
+}
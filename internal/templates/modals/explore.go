@@ -73,6 +73,22 @@ func ExploreModal() map[string]any {
 }
 
 func ExploreCommentsModal(title string, cfg *config.InteractConfig, results []models.ExploreCommentsResult) map[string]any {
+	return map[string]any{
+		"type": "modal",
+		"title": map[string]any{
+			"type": "plain_text",
+			"text": title,
+		},
+		"close": map[string]any{
+			"type": "plain_text",
+			"text": "Close",
+		},
+		"blocks": CommentsBlocks(results, cfg.NComments),
+	}
+}
+
+// Blocks listing comments, of which at most nComments are shown
+func CommentsBlocks(results []models.ExploreCommentsResult, nComments int) []map[string]any {
 	blocks := []map[string]any{}
 
 	if len(results) == 0 {
@@ -94,7 +110,7 @@ func ExploreCommentsModal(title string, cfg *config.InteractConfig, results []mo
 				"elements": []map[string]any{
 					{
 						"type": "mrkdwn",
-						"text": fmt.Sprintf("_Kindly note: Only the %d most recent comments are shown._", cfg.NComments),
+						"text": fmt.Sprintf("_Kindly note: Only the %d most recent comments are shown._", nComments),
 					},
 				},
 			},
@@ -114,7 +130,7 @@ func ExploreCommentsModal(title string, cfg *config.InteractConfig, results []mo
 					"elements": []map[string]any{
 						{
 							"type": "plain_text",
-							"text": fmt.Sprintf("%s ago", utils.TimeToAgo(r.Timestamp)),
+							"text": commentContext(r),
 						},
 					},
 				},
@@ -126,21 +142,26 @@ func ExploreCommentsModal(title string, cfg *config.InteractConfig, results []mo
 		}
 	}
 
+	return blocks
+}
+
+func TopIssuesModal(issues []models.IssueReport) map[string]any {
 	return map[string]any{
 		"type": "modal",
 		"title": map[string]any{
 			"type": "plain_text",
-			"text": title,
+			"text": "Top Issues ❗️",
 		},
 		"close": map[string]any{
 			"type": "plain_text",
 			"text": "Close",
 		},
-		"blocks": blocks,
+		"blocks": TopIssuesBlocks(issues),
 	}
 }
 
-func TopIssuesModal(issues []models.IssueReport) map[string]any {
+// Blocks listing the top issues of each origin
+func TopIssuesBlocks(issues []models.IssueReport) []map[string]any {
 	blocks := []map[string]any{}
 
 	if len(issues) == 0 {
@@ -193,21 +214,34 @@ func TopIssuesModal(issues []models.IssueReport) map[string]any {
 		}
 	}
 
+	return blocks
+}
+
+// Context line of a comment, with its metadata dimension when grouping
+func commentContext(r models.ExploreCommentsResult) string {
+	if r.Dimension != "" {
+		return fmt.Sprintf("%s ago  ·  %s", utils.TimeToAgo(r.Timestamp), r.Dimension)
+	}
+	return fmt.Sprintf("%s ago", utils.TimeToAgo(r.Timestamp))
+}
+
+func StatsModal(title string, stats []models.StatsData) map[string]any {
 	return map[string]any{
 		"type": "modal",
 		"title": map[string]any{
 			"type": "plain_text",
-			"text": "Top Issues ❗️",
+			"text": title,
 		},
 		"close": map[string]any{
 			"type": "plain_text",
 			"text": "Close",
 		},
-		"blocks": blocks,
+		"blocks": StatsBlocks(stats),
 	}
 }
 
-func StatsModal(title string, stats []models.StatsData) map[string]any {
+// Blocks listing stats by origin and category
+func StatsBlocks(stats []models.StatsData) []map[string]any {
 	blocks := []map[string]any{}
 
 	if len(stats) == 0 {
@@ -225,6 +259,11 @@ func StatsModal(title string, stats []models.StatsData) map[string]any {
 	} else {
 		var lastOrigin, lastCategory string
 		for _, s := range stats {
+			prompt := s.Prompt
+			if s.Dimension != "" {
+				prompt = fmt.Sprintf("%s  ·  %s", s.Prompt, s.Dimension)
+			}
+
 			// Add a header for each origin
 			if s.Origin != lastOrigin {
 				blocks = append(blocks,
@@ -253,9 +292,10 @@ func StatsModal(title string, stats []models.StatsData) map[string]any {
 							"type": "mrkdwn",
 							"text": utils.FormatModalStats(
 								s.Category,
-								s.Prompt,
+								prompt,
+								s.Scale,
+								s.MeanRating,
 								s.Score,
-								s.ScoreDelta,
 								s.NResponses,
 								s.NResponsesDelta,
 								s.NComments,
@@ -274,9 +314,10 @@ func StatsModal(title string, stats []models.StatsData) map[string]any {
 							"type": "mrkdwn",
 							"text": utils.FormatModalStats(
 								s.Category,
-								s.Prompt,
+								prompt,
+								s.Scale,
+								s.MeanRating,
 								s.Score,
-								s.ScoreDelta,
 								s.NResponses,
 								s.NResponsesDelta,
 								s.NComments,
@@ -289,18 +330,8 @@ func StatsModal(title string, stats []models.StatsData) map[string]any {
 			}
 			blocks = append(blocks, utils.Spacer())
 		}
+		blocks = append(blocks, utils.ConfidenceNote())
 	}
 
-	return map[string]any{
-		"type": "modal",
-		"title": map[string]any{
-			"type": "plain_text",
-			"text": title,
-		},
-		"close": map[string]any{
-			"type": "plain_text",
-			"text": "Close",
-		},
-		"blocks": blocks,
-	}
+	return blocks
 }
@@ -56,16 +56,25 @@ func TestDataModal(data []models.RawData) map[string]any {
 					{"type": "plain_text", "text": vals.Prompt},
 				},
 			})
+			fields := []map[string]any{
+				{"type": "plain_text", "text": "thumb_up:"},
+				{"type": "plain_text", "text": fmt.Sprintf("%v", vals.ThumbUp)},
+			}
+			if vals.Rating != nil {
+				fields = append(fields,
+					map[string]any{"type": "plain_text", "text": vals.Scale + ":"},
+					map[string]any{"type": "plain_text", "text": fmt.Sprintf("%d", *vals.Rating)},
+				)
+			}
+			fields = append(fields,
+				map[string]any{"type": "plain_text", "text": "comment:"},
+				map[string]any{"type": "plain_text", "text": comment},
+				map[string]any{"type": "plain_text", "text": "user_id:"},
+				map[string]any{"type": "plain_text", "text": vals.UserID},
+			)
 			blocks = append(blocks, map[string]any{
-				"type": "section",
-				"fields": []map[string]any{
-					{"type": "plain_text", "text": "thumb_up:"},
-					{"type": "plain_text", "text": fmt.Sprintf("%v", vals.ThumbUp)},
-					{"type": "plain_text", "text": "comment:"},
-					{"type": "plain_text", "text": comment},
-					{"type": "plain_text", "text": "user_id:"},
-					{"type": "plain_text", "text": vals.UserID},
-				},
+				"type":   "section",
+				"fields": fields,
 			})
 			blocks = append(blocks, utils.Spacer())
 			blocks = append(blocks, map[string]any{
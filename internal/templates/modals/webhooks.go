@@ -0,0 +1,168 @@
+// File: internal/templates/modals/webhooks.go
+
+// This file contains the modal templates for creating webhooks.
+
+package modals
+
+import (
+	"fmt"
+
+	"twothumbs/internal/models"
+	"twothumbs/internal/utils"
+)
+
+func CreateWebhookModal() map[string]any {
+	thumbOptions := []map[string]any{
+		{
+			"text":  map[string]any{"type": "plain_text", "text": "All feedback"},
+			"value": models.WebhookThumbAny,
+		},
+		{
+			"text":  map[string]any{"type": "plain_text", "text": "Thumbs up 👍"},
+			"value": models.WebhookThumbUp,
+		},
+		{
+			"text":  map[string]any{"type": "plain_text", "text": "Thumbs down 👎"},
+			"value": models.WebhookThumbDown,
+		},
+	}
+
+	return map[string]any{
+		"type":        "modal",
+		"callback_id": "create-webhook",
+		"title": map[string]any{
+			"type": "plain_text",
+			"text": "Create Webhook  🪝",
+		},
+		"submit": map[string]any{
+			"type": "plain_text",
+			"text": "Create",
+		},
+		"close": map[string]any{
+			"type": "plain_text",
+			"text": "Cancel",
+		},
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{
+					"type": "plain_text",
+					"text": "Each new feedback record matching the filters is sent to the URL as a signed JSON POST request.",
+				},
+			},
+			{
+				"type":     "input",
+				"block_id": "webhook-url-block",
+				"element": map[string]any{
+					"type":        "url_text_input",
+					"action_id":   "webhook-url",
+					"placeholder": map[string]any{"type": "plain_text", "text": "https://example.com/twothumbs"},
+				},
+				"label": map[string]any{
+					"type": "plain_text",
+					"text": "URL",
+				},
+			},
+			{
+				"type":     "input",
+				"block_id": "webhook-origins-block",
+				"optional": true,
+				"element": map[string]any{
+					"type":        "plain_text_input",
+					"action_id":   "webhook-origins",
+					"placeholder": map[string]any{"type": "plain_text", "text": "e.g. web, ios"},
+				},
+				"label": map[string]any{
+					"type": "plain_text",
+					"text": "Origins",
+				},
+				"hint": map[string]any{
+					"type": "plain_text",
+					"text": "Comma-separated. Leave empty for all origins.",
+				},
+			},
+			{
+				"type":     "input",
+				"block_id": "webhook-categories-block",
+				"optional": true,
+				"element": map[string]any{
+					"type":        "plain_text_input",
+					"action_id":   "webhook-categories",
+					"placeholder": map[string]any{"type": "plain_text", "text": "e.g. search, checkout"},
+				},
+				"label": map[string]any{
+					"type": "plain_text",
+					"text": "Categories",
+				},
+				"hint": map[string]any{
+					"type": "plain_text",
+					"text": "Comma-separated. Leave empty for all categories.",
+				},
+			},
+			{
+				"type":     "input",
+				"block_id": "webhook-thumb-block",
+				"element": map[string]any{
+					"type":           "radio_buttons",
+					"action_id":      "webhook-thumb",
+					"options":        thumbOptions,
+					"initial_option": thumbOptions[0],
+				},
+				"label": map[string]any{
+					"type": "plain_text",
+					"text": "Thumb",
+				},
+			},
+			utils.Spacer(),
+		},
+	}
+}
+
+func WebhookCreatedModal(url, secret string) map[string]any {
+	return map[string]any{
+		"type": "modal",
+		"title": map[string]any{
+			"type": "plain_text",
+			"text": "Done  ✅",
+		},
+		"close": map[string]any{
+			"type": "plain_text",
+			"text": "Close",
+		},
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{
+					"type": "mrkdwn",
+					"text": fmt.Sprintf(
+						"Feedback is now sent to %s. Here is the signing secret of the webhook:\n\n`%s`\n\nPlease copy it now, as it cannot be shown again. Use it to verify the `%s` header of each request.",
+						url, secret, utils.WebhookSignatureHeader,
+					),
+				},
+			},
+		},
+	}
+}
+
+func WebhookErrorModal() map[string]any {
+	return map[string]any{
+		"type": "modal",
+		"title": map[string]any{
+			"type": "plain_text",
+			"text": "Ouch  🤕",
+		},
+		"close": map[string]any{
+			"type": "plain_text",
+			"text": "Close",
+		},
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{
+					"type": "plain_text",
+					"text": "There was an error creating the webhook. Please try again and contact our support if the error persists. We are truly sorry for the inconvenience.",
+				},
+			},
+		},
+	}
+}
@@ -69,13 +69,31 @@ func FeedbackToCSV(feedbacks []*models.Feedback) (string, error) {
 	return buf.String(), w.Error()
 }
 
+// CSV formatting of comments as context for questions
+func CommentsToCSV(results []models.ExploreCommentsResult) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"date", "origin", "category", "prompt", "comment"})
+	for _, r := range results {
+		w.Write([]string{
+			r.Timestamp.Format("2006-01-02"),
+			r.Origin,
+			r.Category,
+			r.Prompt,
+			r.Comment,
+		})
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
 func RawDataToCSV(data []models.RawData) (string, error) {
 	var buf bytes.Buffer
 	w := csv.NewWriter(&buf)
 
 	// Write header
 	w.Write([]string{
-		"created_at", "origin", "category", "prompt", "thumb_up", "comment", "user_id",
+		"created_at", "origin", "category", "prompt", "thumb_up", "comment", "user_id", "rating", "scale", "metadata",
 	})
 
 	// Write data rows
@@ -84,6 +102,10 @@ func RawDataToCSV(data []models.RawData) (string, error) {
 		if r.Comment != nil {
 			comment = *r.Comment
 		}
+		rating := ""
+		if r.Rating != nil {
+			rating = fmt.Sprintf("%d", *r.Rating)
+		}
 		w.Write([]string{
 			r.CreatedAt.Format(time.RFC3339),
 			r.Origin,
@@ -92,6 +114,9 @@ func RawDataToCSV(data []models.RawData) (string, error) {
 			fmt.Sprintf("%t", r.ThumbUp),
 			comment,
 			r.UserID,
+			rating,
+			r.Scale,
+			PtrToString(r.Metadata),
 		})
 	}
 	w.Flush()
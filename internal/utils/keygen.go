@@ -1,11 +1,14 @@
 // File: utils/keygen.go
 
 // This file contains utility functions for generating unique activation codes and API keys.
+// API keys are stored as a salted SHA-256 hash along with a short prefix used for lookup and display.
 
 package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
@@ -13,6 +16,9 @@ import (
 	"strings"
 )
 
+// Length of the stored key prefix, i.e. "twth_" and 8 random characters
+const apiKeyPrefixLen = 13
+
 // GenerateActivationCode generates TWTH-XXXX-YYYY format
 func GenerateActivationCode() (string, error) {
 	b := make([]byte, 4)
@@ -35,7 +41,7 @@ func GenerateApiKey() (string, error) {
 	return apiKey, nil
 }
 
-// GenerateUniqueApiKey ensures generated API key is unique
+// GenerateUniqueApiKey ensures generated API key has a unique prefix, so that lookups find a single key
 func GenerateUniqueApiKey(conn *sql.DB) (string, error) {
 	for range 5 {
 		apiKey, err := GenerateApiKey()
@@ -43,7 +49,7 @@ func GenerateUniqueApiKey(conn *sql.DB) (string, error) {
 			return "", err
 		}
 		var exists bool
-		err = conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM accounts WHERE api_key = $1)`, apiKey).Scan(&exists)
+		err = conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM api_keys WHERE key_prefix = $1)`, ApiKeyPrefix(apiKey)).Scan(&exists)
 		if err != nil {
 			return "", err
 		}
@@ -53,3 +59,48 @@ func GenerateUniqueApiKey(conn *sql.DB) (string, error) {
 	}
 	return "", errors.New("could not generate unique API key")
 }
+
+// ApiKeyPrefix returns the part of an API key that is stored in plaintext
+func ApiKeyPrefix(apiKey string) string {
+	if len(apiKey) <= apiKeyPrefixLen {
+		return apiKey
+	}
+	return apiKey[:apiKeyPrefixLen]
+}
+
+// RedactApiKey returns the prefix of an API key for logging
+func RedactApiKey(apiKey string) string {
+	if len(apiKey) <= apiKeyPrefixLen {
+		return "[redacted]"
+	}
+	return ApiKeyPrefix(apiKey) + "…"
+}
+
+// GenerateApiKeySalt generates a random 16-byte hex encoded salt
+func GenerateApiKeySalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashApiKey returns the hex encoded SHA-256 hash of the salt followed by the key
+func HashApiKey(apiKey, salt string) string {
+	sum := sha256.Sum256([]byte(salt + apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyApiKey checks an API key against a stored salt and hash in constant time
+func VerifyApiKey(apiKey, salt, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashApiKey(apiKey, salt)), []byte(hash)) == 1
+}
+
+// Generate the secret that signs the deliveries of a webhook
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, 32) // 256 bits
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
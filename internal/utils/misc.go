@@ -12,6 +12,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"twothumbs/internal/models"
 )
 
 func ConnectToDB(dsn string) (*sql.DB, error) {
@@ -33,6 +35,14 @@ func GetEnv(key string) string {
 	return value
 }
 
+func GetEnvOrDefault(key, fallback string) string {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	return value
+}
+
 func PtrOrNil(s string) *string {
 	if s == "" {
 		return nil
@@ -95,6 +105,27 @@ func TimeToAgo(ts time.Time) string {
 	return fmt.Sprintf("%d days", days)
 }
 
+// Format a duration in seconds as whole days, hours, or minutes
+func FormatDuration(secs int) string {
+	switch {
+	case secs >= 86400:
+		if secs/86400 == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", secs/86400)
+	case secs >= 3600:
+		if secs/3600 == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", secs/3600)
+	default:
+		if secs/60 == 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", secs/60)
+	}
+}
+
 func FormatDelta(val float64) string {
 	if val == 0 {
 		return "-"
@@ -114,20 +145,84 @@ func FormatIssues(input string) string {
 	return strings.Join(paragraphs, "\n\n\n")
 }
 
+// Headline score for the prompt's scale: thumbs up %, CSAT %, or NPS, with its delta and confidence interval
+func ScoreFromStats(stats *models.FeedbackStats) models.Score {
+	score, prevScore, n := stats.ThumbsUpPct, stats.PrevThumbsUpPct, stats.NFeedback
+	if stats.Scale == models.ScaleCSAT || stats.Scale == models.ScaleNPS {
+		score, prevScore, n = stats.RatingScore, stats.PrevRatingScore, stats.NRated
+	}
+	scoreDelta := "-"
+	if stats.PrevNFeedback > 0 {
+		scoreDelta = FormatDelta(score - prevScore)
+	}
+	return models.Score{
+		Value:       RoundFloat(score),
+		Delta:       scoreDelta,
+		Low:         RoundFloat(stats.ScoreLow),
+		High:        RoundFloat(stats.ScoreHigh),
+		N:           n,
+		Significant: stats.ScoreChangeSignificant,
+	}
+}
+
+// Score delta with its unit, in bold if the change is significant and in italics if it may be noise
+func FormatScoreDelta(score models.Score, unit string) string {
+	if score.Delta == "-" {
+		return "-"
+	}
+	if score.Significant {
+		return fmt.Sprintf("*%s%s*", score.Delta, unit)
+	}
+	return fmt.Sprintf("_%s%s_", score.Delta, unit)
+}
+
+// Score part of the stats line, separated by sep from the mean rating where applicable.
+// The score is followed by its 95% confidence interval, so that scores of few responses stand out.
+func FormatScore(scale string, meanRating float64, score models.Score, sep string) string {
+	switch scale {
+	case models.ScaleCSAT:
+		return fmt.Sprintf("⭐   %.1f/5%s😊   %d%%%s (%s)", meanRating, sep, score.Value, formatScoreInterval(score, "%d"), FormatScoreDelta(score, "%"))
+	case models.ScaleNPS:
+		return fmt.Sprintf("📣   NPS %+d%s (%s)%s⭐   %.1f/10", score.Value, formatScoreInterval(score, "%+d"), FormatScoreDelta(score, ""), sep, meanRating)
+	default:
+		return fmt.Sprintf("👍   %d%%%s (%s)", score.Value, formatScoreInterval(score, "%d"), FormatScoreDelta(score, "%"))
+	}
+}
+
+func formatScoreInterval(score models.Score, verb string) string {
+	if score.N == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  ["+verb+"–"+verb+"]", score.Low, score.High)
+}
+
+// Note explaining the confidence intervals and deltas of stats
+func ConfidenceNote() map[string]any {
+	return map[string]any{
+		"type": "context",
+		"elements": []map[string]any{
+			{
+				"type": "mrkdwn",
+				"text": "_[low–high] is the 95% confidence interval of a score. Changes in *bold* are statistically significant, the others may be noise._",
+			},
+		},
+	}
+}
+
 func FormatDigestStats(
 	prompt string,
-	score int,
-	scoreDelta string,
+	scale string,
+	meanRating float64,
+	score models.Score,
 	nResponses int,
 	nResponsesDelta string,
 	nComments int,
 	nCommentsDelta string,
 ) string {
 	return fmt.Sprintf(
-		"_%s_\n\n\n👍   %d%% (%s%%)    👋   %d (%s%%)    💬   %d (%s%%)",
+		"_%s_\n\n\n%s    👋   %d (%s%%)    💬   %d (%s%%)",
 		prompt,
-		score,
-		scoreDelta,
+		FormatScore(scale, meanRating, score, "    "),
 		nResponses,
 		nResponsesDelta,
 		nComments,
@@ -138,8 +233,9 @@ func FormatDigestStats(
 func FormatModalStats(
 	category string,
 	prompt string,
-	score int,
-	scoreDelta string,
+	scale string,
+	meanRating float64,
+	score models.Score,
 	nResponses int,
 	nResponsesDelta string,
 	nComments int,
@@ -148,11 +244,10 @@ func FormatModalStats(
 ) string {
 	if printCategory {
 		return fmt.Sprintf(
-			"*%s*\n\n\n_%s_\n\n\n👍   %d%% (%s%%)\n\n👋   %d (%s%%)\n\n💬   %d (%s%%)",
+			"*%s*\n\n\n_%s_\n\n\n%s\n\n👋   %d (%s%%)\n\n💬   %d (%s%%)",
 			category,
 			prompt,
-			score,
-			scoreDelta,
+			FormatScore(scale, meanRating, score, "\n\n"),
 			nResponses,
 			nResponsesDelta,
 			nComments,
@@ -160,10 +255,9 @@ func FormatModalStats(
 		)
 	} else {
 		return fmt.Sprintf(
-			"_%s_\n\n\n👍   %d%% (%s%%)\n\n👋   %d (%s%%)\n\n💬   %d (%s%%)",
+			"_%s_\n\n\n%s\n\n👋   %d (%s%%)\n\n💬   %d (%s%%)",
 			prompt,
-			score,
-			scoreDelta,
+			FormatScore(scale, meanRating, score, "\n\n"),
 			nResponses,
 			nResponsesDelta,
 			nComments,
@@ -0,0 +1,61 @@
+// File: internal/utils/oauth_state.go
+
+// This file contains the signed, expiring state parameter of the Slack OAuth install flow.
+// The state carries a random nonce and an expiry, signed with HMAC-SHA256. The nonce is also
+// stored in a cookie, so that a callback is only accepted in the browser that started the flow.
+
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// How long an install flow may take
+const OAuthStateTTL = 10 * time.Minute
+
+// Name of the cookie holding the nonce of the state
+const OAuthStateCookie = "twothumbs_oauth_state"
+
+// NewOAuthState returns a signed state expiring after ttl, along with its nonce
+func NewOAuthState(secret string, ttl time.Duration) (string, string, error) {
+	b := make([]byte, 16+8)
+	if _, err := rand.Read(b[:16]); err != nil {
+		return "", "", err
+	}
+	binary.BigEndian.PutUint64(b[16:], uint64(time.Now().Add(ttl).Unix()))
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + signOAuthState(secret, payload), hex.EncodeToString(b[:16]), nil
+}
+
+// VerifyOAuthState checks the signature and expiry of a state, returning its nonce
+func VerifyOAuthState(secret, state string) (string, error) {
+	payload, sig, ok := strings.Cut(state, ".")
+	if !ok {
+		return "", errors.New("malformed state")
+	}
+	if !hmac.Equal([]byte(sig), []byte(signOAuthState(secret, payload))) {
+		return "", errors.New("invalid state signature")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(b) != 16+8 {
+		return "", errors.New("malformed state")
+	}
+	if time.Now().Unix() > int64(binary.BigEndian.Uint64(b[16:])) {
+		return "", errors.New("state expired")
+	}
+	return hex.EncodeToString(b[:16]), nil
+}
+
+func signOAuthState(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,79 @@
+// File: internal/utils/questions.go
+
+// This file contains helpers to turn a question about feedback into search terms and a date range.
+
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Days searched when a question does not mention a period
+const defaultQuestionRangeDays = 30
+
+var (
+	slackMentionRe = regexp.MustCompile(`<[@#!][^>]*>`)
+	questionWordRe = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}_-]*`)
+)
+
+// Escapes the wildcards of LIKE patterns, and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Common words that do not help to find matching feedback
+var questionStopwords = map[string]bool{
+	"about": true, "all": true, "and": true, "any": true, "are": true, "can": true, "comments": true,
+	"could": true, "day": true, "days": true, "did": true, "does": true, "feedback": true, "for": true,
+	"from": true, "give": true, "has": true, "have": true, "how": true, "last": true, "like": true,
+	"many": true, "month": true, "months": true, "most": true, "our": true, "past": true, "people": true,
+	"quarter": true, "saying": true, "say": true, "says": true, "should": true, "show": true, "summarize": true,
+	"summary": true, "tell": true, "that": true, "the": true, "their": true, "there": true, "they": true,
+	"this": true, "today": true, "users": true, "was": true, "week": true, "weeks": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "who": true, "why": true, "with": true,
+	"yesterday": true, "you": true,
+}
+
+// StripSlackMentions removes user, channel, and special mentions from a message
+func StripSlackMentions(text string) string {
+	return strings.TrimSpace(slackMentionRe.ReplaceAllString(text, ""))
+}
+
+// QuestionKeywords returns the distinct words of a question worth searching for
+func QuestionKeywords(question string) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, w := range questionWordRe.FindAllString(strings.ToLower(question), -1) {
+		if len([]rune(w)) < 3 || questionStopwords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		keywords = append(keywords, w)
+	}
+	return keywords
+}
+
+// QuestionPatterns returns ILIKE patterns matching any of the keywords of a question, with wildcards
+// in the keywords escaped with a backslash
+func QuestionPatterns(question string) []string {
+	var patterns []string
+	for _, kw := range QuestionKeywords(question) {
+		patterns = append(patterns, "%"+likeEscaper.Replace(kw)+"%")
+	}
+	return patterns
+}
+
+// QuestionRangeDays returns the number of days a question asks about
+func QuestionRangeDays(question string) int {
+	q := strings.ToLower(question)
+	switch {
+	case strings.Contains(q, "today"), strings.Contains(q, "yesterday"):
+		return 2
+	case strings.Contains(q, "week"), strings.Contains(q, "7 days"):
+		return 7
+	case strings.Contains(q, "quarter"), strings.Contains(q, "90 days"):
+		return 90
+	case strings.Contains(q, "month"), strings.Contains(q, "30 days"):
+		return 30
+	}
+	return defaultQuestionRangeDays
+}
@@ -0,0 +1,25 @@
+// File: internal/utils/questions_test.go
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuestionPatterns(t *testing.T) {
+	tests := []struct {
+		question string
+		want     []string
+	}{
+		{"What are people saying about checkout?", []string{"%checkout%"}},
+		{"Any feedback on the sign_up flow this week?", []string{`%sign\_up%`, "%flow%"}},
+		{`Complaints about 100% C:\ drives`, []string{"%complaints%", "%100%", "%drives%"}},
+		{"What are they saying?", nil},
+	}
+	for _, tt := range tests {
+		if got := QuestionPatterns(tt.question); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QuestionPatterns(%q) = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// File: internal/utils/rate_limit_stores.go
+
+// This file contains the token bucket stores for the rate limiter.
+// The in-memory store is the default; the Postgres store shares limits across replicas and restarts.
+
+package utils
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Create the store of a backend, "memory" or "postgres", removing idle buckets at the given interval.
+// The names are those of the limiters using the store, whose buckets are the only ones it removes, as
+// stores with other intervals share the table of the Postgres backend.
+func NewRateLimitStore(backend string, conn *sql.DB, cleanupIntervalSeconds int, names ...string) RateLimitStore {
+	if backend == "postgres" {
+		return NewPostgresRateLimitStore(conn, cleanupIntervalSeconds, names...)
+	}
+	return NewMemoryRateLimitStore(cleanupIntervalSeconds)
+}
+
+// *In-memory store*
+
+type bucket struct {
+	lastSeen time.Time
+	tokens   float64
+}
+
+type MemoryRateLimitStore struct {
+	buckets         map[string]*bucket
+	mu              sync.Mutex
+	cleanupInterval time.Duration
+}
+
+func NewMemoryRateLimitStore(cleanupIntervalSeconds int) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{
+		buckets:         make(map[string]*bucket),
+		cleanupInterval: time.Duration(cleanupIntervalSeconds) * time.Second,
+	}
+	go s.cleanupBuckets()
+	return s
+}
+
+func (s *MemoryRateLimitStore) Take(key string, rate int, refillRate float64) (bool, int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{lastSeen: now, tokens: float64(rate)}
+		s.buckets[key] = b
+	}
+
+	// Refill tokens for the time elapsed since the last request
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(rate), b.tokens+elapsed*refillRate)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, int(b.tokens), 0, nil
+	}
+	return false, 0, retryAfter(b.tokens, refillRate), nil
+}
+
+func (s *MemoryRateLimitStore) cleanupBuckets() {
+	for {
+		time.Sleep(s.cleanupInterval)
+		s.mu.Lock()
+		for k, b := range s.buckets {
+			if time.Since(b.lastSeen) > s.cleanupInterval {
+				delete(s.buckets, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// *Postgres store*
+
+type PostgresRateLimitStore struct {
+	conn            *sql.DB
+	cleanupInterval time.Duration
+	names           []string // of the limiters whose buckets are cleaned up
+}
+
+func NewPostgresRateLimitStore(conn *sql.DB, cleanupIntervalSeconds int, names ...string) *PostgresRateLimitStore {
+	s := &PostgresRateLimitStore{
+		conn:            conn,
+		cleanupInterval: time.Duration(cleanupIntervalSeconds) * time.Second,
+		names:           names,
+	}
+	go s.cleanupBuckets()
+	return s
+}
+
+func (s *PostgresRateLimitStore) Take(key string, rate int, refillRate float64) (bool, int, time.Duration, error) {
+	// Refill and take a token in a single statement, so that concurrent replicas cannot overdraw a bucket
+	var tokens float64
+	var allowed bool
+	err := s.conn.QueryRow(`
+        INSERT INTO rate_limits AS r (bucket_key, tokens, last_seen, last_allowed)
+        VALUES ($1, $2::float8 - 1, NOW(), TRUE)
+        ON CONFLICT (bucket_key) DO UPDATE SET
+            tokens = CASE
+                WHEN LEAST($2::float8, r.tokens + EXTRACT(EPOCH FROM (NOW() - r.last_seen))::float8 * $3::float8) >= 1
+                THEN LEAST($2::float8, r.tokens + EXTRACT(EPOCH FROM (NOW() - r.last_seen))::float8 * $3::float8) - 1
+                ELSE LEAST($2::float8, r.tokens + EXTRACT(EPOCH FROM (NOW() - r.last_seen))::float8 * $3::float8)
+            END,
+            last_allowed = LEAST($2::float8, r.tokens + EXTRACT(EPOCH FROM (NOW() - r.last_seen))::float8 * $3::float8) >= 1,
+            last_seen = NOW()
+        RETURNING tokens, last_allowed
+    `, key, float64(rate), refillRate).Scan(&tokens, &allowed)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	if allowed {
+		return true, int(tokens), 0, nil
+	}
+	return false, 0, retryAfter(tokens, refillRate), nil
+}
+
+func (s *PostgresRateLimitStore) cleanupBuckets() {
+	for {
+		time.Sleep(s.cleanupInterval)
+		// Bucket keys start with the name of their limiter
+		_, err := s.conn.Exec(`
+            DELETE FROM rate_limits
+            WHERE split_part(bucket_key, ':', 1) = ANY($2)
+              AND last_seen < NOW() - make_interval(secs => $1)
+        `, s.cleanupInterval.Seconds(), pq.Array(s.names))
+		if err != nil {
+			log.Printf("Failed to clean up rate limit buckets: %v", err)
+		}
+	}
+}
+
+// Time until a bucket holding the given tokens has refilled one token
+func retryAfter(tokens, refillRate float64) time.Duration {
+	return time.Duration((1 - tokens) / refillRate * float64(time.Second))
+}
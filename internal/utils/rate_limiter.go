@@ -1,82 +1,92 @@
 // File: internal/utils/rate_limiter.go
 
-// This file contains the rate limiting middleware for the feedback endpoint.
+// This file contains the rate limiting middleware for the feedback endpoint, and rate limiting of
+// other work such as questions to the bot.
+// Each key gets a token bucket that refills continuously at rate/window tokens per second.
+// The bucket state is kept in a RateLimitStore (see rate_limit_stores.go).
 
 package utils
 
 import (
+	"fmt"
+	"log"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-type visitor struct {
-	lastSeen time.Time
-	tokens   int
+// RateLimitStore takes a token from the bucket of a key, returning whether the request is allowed,
+// the number of remaining tokens, and the time until the next token is available
+type RateLimitStore interface {
+	Take(key string, rate int, refillRate float64) (allowed bool, remaining int, retryAfter time.Duration, err error)
 }
 
 type RateLimiter struct {
-	visitors        map[string]*visitor
-	mu              sync.Mutex
-	rate            int
-	window          time.Duration
-	cleanupInterval time.Duration
+	store      RateLimitStore
+	name       string
+	rate       int     // bucket capacity
+	refillRate float64 // tokens per second
+	keyFunc    func(*gin.Context) string
 }
 
-func NewRateLimiter(rate int, windowSeconds int, cleanupIntervalSeconds int) *RateLimiter {
-	rl := &RateLimiter{
-		visitors:        make(map[string]*visitor),
-		rate:            rate,
-		window:          time.Duration(windowSeconds) * time.Second,
-		cleanupInterval: time.Duration(cleanupIntervalSeconds) * time.Second,
+func NewRateLimiter(store RateLimitStore, name string, rate int, windowSeconds int, keyFunc func(*gin.Context) string) *RateLimiter {
+	return &RateLimiter{
+		store:      store,
+		name:       name,
+		rate:       rate,
+		refillRate: float64(rate) / float64(windowSeconds),
+		keyFunc:    keyFunc,
 	}
-	go rl.cleanupVisitors()
-	return rl
 }
 
-func (rl *RateLimiter) getVisitor(key string) *visitor {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	v, exists := rl.visitors[key]
-	if !exists {
-		v = &visitor{lastSeen: time.Now(), tokens: rl.rate}
-		rl.visitors[key] = v
+// Set by the authentication middleware to the ID of the API key a request was authenticated with
+const APIKeyIDContextKey = "api_key_id"
+
+// Key requests by the ID of their authenticated API key, falling back to the client IP for requests that
+// were not authenticated. Keying by the raw header would give every made-up key a fresh bucket.
+func APIKeyRateLimitKey(c *gin.Context) string {
+	if id, ok := c.Get(APIKeyIDContextKey); ok {
+		return fmt.Sprintf("key:%v", id)
 	}
-	return v
+	return "ip:" + c.ClientIP()
 }
 
-func (rl *RateLimiter) cleanupVisitors() {
-	for {
-		time.Sleep(rl.cleanupInterval)
-		rl.mu.Lock()
-		for k, v := range rl.visitors {
-			if time.Since(v.lastSeen) > rl.cleanupInterval {
-				delete(rl.visitors, k)
-			}
-		}
-		rl.mu.Unlock()
+// Key requests by client IP
+func ClientIPRateLimitKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// Take a token for a key outside of a request, returning whether it is allowed and, if not, the time until
+// it would be. Like the middleware, this fails open on store errors.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	allowed, _, retryAfter, err := rl.store.Take(rl.name+":"+key, rl.rate, rl.refillRate)
+	if err != nil {
+		log.Printf("Rate limit store error for limiter %s: %v", rl.name, err)
+		return true, 0
 	}
+	return allowed, retryAfter
 }
 
 func (rl *RateLimiter) Limit() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		key := c.ClientIP()
-		v := rl.getVisitor(key)
-		now := time.Now()
-		elapsed := now.Sub(v.lastSeen)
-		v.lastSeen = now
-
-		// refill tokens if window has passed
-		if elapsed > rl.window {
-			v.tokens = rl.rate
+		allowed, remaining, retryAfter, err := rl.store.Take(rl.name+":"+rl.keyFunc(c), rl.rate, rl.refillRate)
+		if err != nil {
+			// Fail open, so that a store outage does not drop feedback
+			log.Printf("Rate limit store error for limiter %s: %v", rl.name, err)
+			c.Next()
+			return
 		}
 
-		if v.tokens > 0 {
-			v.tokens--
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rl.rate))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+		if allowed {
 			c.Next()
 		} else {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
 		}
 	}
@@ -0,0 +1,124 @@
+// File: internal/utils/slack_signature.go
+
+// This file contains the middleware that verifies requests from Slack by their X-Slack-Signature header.
+// Requests must be signed within the replay window, and a signature is only accepted once. Signatures are
+// remembered in memory, so replays are only detected by the instance that served the original request;
+// with several instances behind a load balancer, a replay within the window may reach another instance.
+
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How far the request timestamp may be from the current time
+const SlackReplayWindow = 5 * time.Minute
+
+// Seen signatures are grouped by the minute of their request timestamp, so that they can be forgotten
+// a minute at a time
+const slackSeenBucketSecs = 60
+
+type SlackVerifier struct {
+	signingSecret string
+	window        time.Duration
+	mu            sync.Mutex
+	seen          map[int64]map[string]struct{} // timestamp bucket -> signatures
+}
+
+func NewSlackVerifier(signingSecret string, window time.Duration) *SlackVerifier {
+	return &SlackVerifier{
+		signingSecret: signingSecret,
+		window:        window,
+		seen:          make(map[int64]map[string]struct{}),
+	}
+}
+
+// Middleware rejecting requests that are not signed by Slack, leaving the body readable for the handler
+func (v *SlackVerifier) Verify() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v.signingSecret == "" {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server Misconfiguration"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		timestamp := c.GetHeader("X-Slack-Request-Timestamp")
+		signature := c.GetHeader("X-Slack-Signature")
+		ts, ok := VerifySlackRequest(v.signingSecret, timestamp, string(body), signature, v.window)
+		if !ok {
+			log.Printf("rejected Slack request with invalid signature from %s", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+		if !v.firstUse(signature, ts) {
+			log.Printf("rejected replayed Slack request from %s", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Replayed request"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Record a signature, returning false if it was already used within the replay window
+func (v *SlackVerifier) firstUse(signature string, ts time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Forget the buckets of timestamps that are outside the window anyway
+	cutoff := time.Now().Add(-v.window).Unix()
+	for bucket := range v.seen {
+		if (bucket+1)*slackSeenBucketSecs <= cutoff {
+			delete(v.seen, bucket)
+		}
+	}
+
+	bucket := ts.Unix() / slackSeenBucketSecs
+	sigs, ok := v.seen[bucket]
+	if !ok {
+		sigs = make(map[string]struct{})
+		v.seen[bucket] = sigs
+	}
+	if _, ok := sigs[signature]; ok {
+		return false
+	}
+	sigs[signature] = struct{}{}
+	return true
+}
+
+// VerifySlackRequest checks the signature of a request body and that its timestamp is within the window,
+// returning the timestamp
+func VerifySlackRequest(signingSecret, timestamp, body, slackSignature string, window time.Duration) (time.Time, bool) {
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	ts := time.Unix(secs, 0)
+	if age := time.Since(ts); age > window || age < -window {
+		return time.Time{}, false
+	}
+
+	basestring := "v0:" + timestamp + ":" + body
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(basestring))
+	expectedSig := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	return ts, hmac.Equal([]byte(expectedSig), []byte(slackSignature))
+}
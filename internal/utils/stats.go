@@ -0,0 +1,134 @@
+// File: internal/utils/stats.go
+
+// This file contains statistical tests used to tell shifts in feedback apart from noise.
+
+package utils
+
+import (
+	"math"
+
+	"twothumbs/internal/models"
+)
+
+// Two-sided p-value of a standard normal z-score
+func NormalPValue(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// Two-proportion z-test of x1 successes out of n1 against x0 out of n0, with the pooled proportion.
+// Returns the z-score (positive if the first proportion is higher) and the two-sided p-value.
+func TwoProportionZTest(x1, n1, x0, n0 int) (z, p float64) {
+	if n1 == 0 || n0 == 0 {
+		return 0, 1
+	}
+	p1 := float64(x1) / float64(n1)
+	p0 := float64(x0) / float64(n0)
+	pooled := float64(x1+x0) / float64(n1+n0)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n0)))
+	if se == 0 {
+		return 0, 1
+	}
+	z = (p1 - p0) / se
+	return z, NormalPValue(z)
+}
+
+// Test a count against the daily counts of a baseline, as an overdispersed Poisson variable:
+// the variance is the mean scaled by the dispersion of the baseline (at least 1).
+// Returns the expected count, the z-score (positive if the count is higher), and the two-sided p-value.
+func CountZTest(count int, baseline []int) (expected, z, p float64) {
+	if len(baseline) == 0 {
+		return 0, 0, 1
+	}
+	var sum float64
+	for _, c := range baseline {
+		sum += float64(c)
+	}
+	expected = sum / float64(len(baseline))
+	if expected == 0 {
+		return 0, 0, 1
+	}
+
+	dispersion := 1.0
+	if len(baseline) > 1 {
+		var ss float64
+		for _, c := range baseline {
+			ss += (float64(c) - expected) * (float64(c) - expected)
+		}
+		dispersion = max(dispersion, ss/float64(len(baseline)-1)/expected)
+	}
+
+	z = (float64(count) - expected) / math.Sqrt(dispersion*expected)
+	return expected, z, NormalPValue(z)
+}
+
+const (
+	ConfidenceZ       = 1.959964 // z-score of 95% confidence intervals
+	SignificanceLevel = 0.05     // of period-over-period changes
+)
+
+// Wilson score interval at 95% confidence of x successes out of n, as proportions
+func WilsonInterval(x, n int) (low, high float64) {
+	if n == 0 {
+		return 0, 0
+	}
+	z2 := ConfidenceZ * ConfidenceZ
+	nf := float64(n)
+	p := float64(x) / nf
+	center := (p + z2/(2*nf)) / (1 + z2/nf)
+	margin := ConfidenceZ / (1 + z2/nf) * math.Sqrt(p*(1-p)/nf+z2/(4*nf*nf))
+	return max(0, center-margin), min(1, center+margin)
+}
+
+// Mean and variance of a single NPS response, scored 1 for promoters, -1 for detractors, and 0 otherwise
+func npsMoments(promoters, detractors, n int) (mean, variance float64) {
+	pPro := float64(promoters) / float64(n)
+	pDet := float64(detractors) / float64(n)
+	mean = pPro - pDet
+	return mean, pPro + pDet - mean*mean
+}
+
+// Normal interval at 95% confidence of an NPS, as a fraction between -1 and 1
+func NPSInterval(promoters, detractors, n int) (low, high float64) {
+	if n == 0 {
+		return 0, 0
+	}
+	mean, variance := npsMoments(promoters, detractors, n)
+	margin := ConfidenceZ * math.Sqrt(variance/float64(n))
+	return max(-1, mean-margin), min(1, mean+margin)
+}
+
+// Two-sample z-test of the NPS of the first sample against the second.
+// Returns the z-score (positive if the first NPS is higher) and the two-sided p-value.
+func NPSZTest(pro1, det1, n1, pro0, det0, n0 int) (z, p float64) {
+	if n1 == 0 || n0 == 0 {
+		return 0, 1
+	}
+	mean1, var1 := npsMoments(pro1, det1, n1)
+	mean0, var0 := npsMoments(pro0, det0, n0)
+	se := math.Sqrt(var1/float64(n1) + var0/float64(n0))
+	if se == 0 {
+		return 0, 1
+	}
+	z = (mean1 - mean0) / se
+	return z, NormalPValue(z)
+}
+
+// Set the confidence interval of the headline score for the scale of the stats, and whether its
+// change from the previous period is significant
+func SetScoreConfidence(stats *models.FeedbackStats) {
+	var low, high, p float64
+	switch stats.Scale {
+	case models.ScaleCSAT:
+		low, high = WilsonInterval(stats.NPositive, stats.NRated)
+		_, p = TwoProportionZTest(stats.NPositive, stats.NRated, stats.PrevNPositive, stats.PrevNRated)
+	case models.ScaleNPS:
+		low, high = NPSInterval(stats.NPositive, stats.NDetractors, stats.NRated)
+		_, p = NPSZTest(stats.NPositive, stats.NDetractors, stats.NRated, stats.PrevNPositive, stats.PrevNDetractors, stats.PrevNRated)
+	default:
+		low, high = WilsonInterval(stats.NThumbsUp, stats.NFeedback)
+		_, p = TwoProportionZTest(stats.NThumbsUp, stats.NFeedback, stats.PrevNThumbsUp, stats.PrevNFeedback)
+	}
+	stats.ScoreLow, stats.ScoreHigh = 100*low, 100*high
+	stats.ScoreChangeP = p
+	stats.ScoreChangeSignificant = p < SignificanceLevel
+}
@@ -0,0 +1,55 @@
+// File: internal/utils/stats_test.go
+
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWilsonInterval(t *testing.T) {
+	tests := []struct {
+		x, n      int
+		low, high float64
+	}{
+		{0, 0, 0, 0},
+		{5, 10, 0.2366, 0.7634},
+		{0, 10, 0, 0.2775},
+		{10, 10, 0.7225, 1},
+		{80, 100, 0.7112, 0.8666},
+	}
+	for _, tt := range tests {
+		low, high := WilsonInterval(tt.x, tt.n)
+		if math.Abs(low-tt.low) > 1e-4 || math.Abs(high-tt.high) > 1e-4 {
+			t.Errorf("WilsonInterval(%d, %d) = (%.4f, %.4f), want (%.4f, %.4f)", tt.x, tt.n, low, high, tt.low, tt.high)
+		}
+	}
+}
+
+func TestNPSZTest(t *testing.T) {
+	tests := []struct {
+		name           string
+		pro1, det1, n1 int
+		pro0, det0, n0 int
+		z              float64
+		significant    bool
+	}{
+		{"empty sample", 5, 1, 10, 0, 0, 0, 0, false},
+		{"same NPS", 50, 10, 100, 50, 10, 100, 0, false},
+		{"no variance", 10, 0, 10, 20, 0, 20, 0, false},
+		{"higher NPS", 50, 10, 100, 30, 30, 100, 3.9223, true},
+		{"lower NPS", 30, 30, 100, 50, 10, 100, -3.9223, true},
+		{"small samples", 6, 2, 10, 4, 3, 10, 0.8226, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z, p := NPSZTest(tt.pro1, tt.det1, tt.n1, tt.pro0, tt.det0, tt.n0)
+			if math.Abs(z-tt.z) > 1e-3 {
+				t.Errorf("z = %.4f, want %.4f", z, tt.z)
+			}
+			if (p < SignificanceLevel) != tt.significant {
+				t.Errorf("p = %.4f, want significant %v", p, tt.significant)
+			}
+		})
+	}
+}
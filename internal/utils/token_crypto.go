@@ -0,0 +1,241 @@
+// File: internal/utils/token_crypto.go
+
+// This file contains the envelope encryption of Slack bot tokens at rest.
+// Each token is encrypted with its own random data key (AES-256-GCM), and the data key is in turn
+// encrypted with a master key from TOKEN_MASTER_KEYS. Master keys are identified by an ID, so that
+// a new master key can be added while data keys wrapped with older ones remain readable until rewrapped.
+// Ciphertexts are bound to the row and column they are stored in with associated data (see TokenAAD),
+// so that a ciphertext copied to another row does not decrypt there.
+
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// An encrypted token as stored in the database
+type EncryptedToken struct {
+	Ciphertext []byte // nonce followed by the token sealed with the data key
+	DataKey    []byte // nonce followed by the data key sealed with the master key
+	KeyID      string // ID of the master key that sealed the data key
+	AADBound   bool   // whether the ciphertexts are sealed with associated data, false for older tokens
+}
+
+// TokenAAD returns the associated data binding a ciphertext to a column and the key of its row
+func TokenAAD(column, rowKey string) []byte {
+	return []byte(column + ":" + rowKey)
+}
+
+// The configured master keys, the first of which is used to encrypt
+type TokenKeyring struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+var tokenKeyring *TokenKeyring
+
+// ParseTokenKeyring parses a comma-separated list of id:base64key pairs, e.g. "2:...,1:...",
+// where each key is 32 bytes and the first one is current
+func ParseTokenKeyring(spec string) (*TokenKeyring, error) {
+	kr := &TokenKeyring{keys: make(map[string][]byte)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("master key entry must be id:base64key")
+		}
+		if _, exists := kr.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate master key id %s", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %s must be 32 bytes, got %d", id, len(key))
+		}
+		if kr.currentID == "" {
+			kr.currentID = id
+		}
+		kr.keys[id] = key
+	}
+	if kr.currentID == "" {
+		return nil, errors.New("no master key configured")
+	}
+	return kr, nil
+}
+
+// CurrentID returns the ID of the master key used to encrypt
+func (kr *TokenKeyring) CurrentID() string {
+	return kr.currentID
+}
+
+// SetTokenKeyring sets the keyring used to encrypt and decrypt bot tokens
+func SetTokenKeyring(kr *TokenKeyring) {
+	tokenKeyring = kr
+}
+
+// GetTokenKeyring returns the keyring set with SetTokenKeyring
+func GetTokenKeyring() (*TokenKeyring, error) {
+	if tokenKeyring == nil {
+		return nil, errors.New("token keyring not configured")
+	}
+	return tokenKeyring, nil
+}
+
+// EncryptToken encrypts a token with a new data key wrapped with the current master key, binding it
+// to the associated data if any
+func EncryptToken(token string, aad []byte) (*EncryptedToken, error) {
+	kr, err := GetTokenKeyring()
+	if err != nil {
+		return nil, err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataKey, []byte(token), aad)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(kr.keys[kr.currentID], dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedToken{Ciphertext: ciphertext, DataKey: wrapped, KeyID: kr.currentID, AADBound: aad != nil}, nil
+}
+
+// DecryptToken decrypts a token encrypted with EncryptToken. The associated data is ignored for
+// tokens encrypted before they were bound to it.
+func DecryptToken(enc *EncryptedToken, aad []byte) (string, error) {
+	dataKey, err := unwrapDataKey(enc)
+	if err != nil {
+		return "", err
+	}
+	token, err := open(dataKey, enc.Ciphertext, enc.boundAAD(aad))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(token), nil
+}
+
+// EncryptWithDataKey encrypts another secret with the data key of an encrypted token, so that
+// secrets stored along with the token are covered when the token is rewrapped. The secret is bound
+// to the associated data if the token is.
+func EncryptWithDataKey(enc *EncryptedToken, secret string, aad []byte) ([]byte, error) {
+	dataKey, err := unwrapDataKey(enc)
+	if err != nil {
+		return nil, err
+	}
+	return seal(dataKey, []byte(secret), enc.boundAAD(aad))
+}
+
+// DecryptWithDataKey decrypts a secret encrypted with EncryptWithDataKey
+func DecryptWithDataKey(enc *EncryptedToken, ciphertext []byte, aad []byte) (string, error) {
+	dataKey, err := unwrapDataKey(enc)
+	if err != nil {
+		return "", err
+	}
+	secret, err := open(dataKey, ciphertext, enc.boundAAD(aad))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// RewrapToken re-encrypts the data key of a token with the current master key, leaving the
+// token ciphertext as is. Returns false if the data key is already wrapped with the current key.
+func RewrapToken(enc *EncryptedToken) (*EncryptedToken, bool, error) {
+	kr, err := GetTokenKeyring()
+	if err != nil {
+		return nil, false, err
+	}
+	if enc.KeyID == kr.currentID {
+		return enc, false, nil
+	}
+	dataKey, err := unwrapDataKey(enc)
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := seal(kr.keys[kr.currentID], dataKey, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return &EncryptedToken{Ciphertext: enc.Ciphertext, DataKey: wrapped, KeyID: kr.currentID, AADBound: enc.AADBound}, true, nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash of a token, used to look it up without decrypting
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// The associated data the ciphertexts of a token are sealed with
+func (enc *EncryptedToken) boundAAD(aad []byte) []byte {
+	if !enc.AADBound {
+		return nil
+	}
+	return aad
+}
+
+// Decrypt the data key of a token with the master key it was wrapped with
+func unwrapDataKey(enc *EncryptedToken) ([]byte, error) {
+	kr, err := GetTokenKeyring()
+	if err != nil {
+		return nil, err
+	}
+	masterKey, ok := kr.keys[enc.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key id %s", enc.KeyID)
+	}
+	dataKey, err := open(masterKey, enc.DataKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with master key %s: %w", enc.KeyID, err)
+	}
+	return dataKey, nil
+}
+
+// Encrypt with AES-256-GCM, authenticating the associated data and prepending the random nonce
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Decrypt the output of seal
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
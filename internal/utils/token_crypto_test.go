@@ -0,0 +1,102 @@
+// File: internal/utils/token_crypto_test.go
+
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, ids ...string) *TokenKeyring {
+	t.Helper()
+	var entries []string
+	for _, id := range ids {
+		key := strings.Repeat(id, 32)[:32]
+		entries = append(entries, id+":"+base64.StdEncoding.EncodeToString([]byte(key)))
+	}
+	kr, err := ParseTokenKeyring(strings.Join(entries, ","))
+	if err != nil {
+		t.Fatalf("ParseTokenKeyring: %v", err)
+	}
+	return kr
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	SetTokenKeyring(testKeyring(t, "1"))
+	t.Cleanup(func() { SetTokenKeyring(nil) })
+
+	tests := []struct {
+		name       string
+		encryptAAD []byte
+		decryptAAD []byte
+		wantErr    bool
+	}{
+		{"bound", TokenAAD("installations.bot_token", "T1"), TokenAAD("installations.bot_token", "T1"), false},
+		{"other row", TokenAAD("installations.bot_token", "T1"), TokenAAD("installations.bot_token", "T2"), true},
+		{"other column", TokenAAD("installations.bot_token", "T1"), TokenAAD("installations.refresh_token", "T1"), true},
+		{"unbound", nil, TokenAAD("installations.bot_token", "T1"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := EncryptToken("xoxb-secret", tt.encryptAAD)
+			if err != nil {
+				t.Fatalf("EncryptToken: %v", err)
+			}
+			got, err := DecryptToken(enc, tt.decryptAAD)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecryptToken error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != "xoxb-secret" {
+				t.Errorf("DecryptToken = %q, want %q", got, "xoxb-secret")
+			}
+
+			refresh, err := EncryptWithDataKey(enc, "xoxe-refresh", tt.encryptAAD)
+			if err != nil {
+				t.Fatalf("EncryptWithDataKey: %v", err)
+			}
+			got, err = DecryptWithDataKey(enc, refresh, tt.decryptAAD)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecryptWithDataKey error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != "xoxe-refresh" {
+				t.Errorf("DecryptWithDataKey = %q, want %q", got, "xoxe-refresh")
+			}
+		})
+	}
+}
+
+func TestRewrapToken(t *testing.T) {
+	aad := TokenAAD("webhooks.secret", "7")
+	SetTokenKeyring(testKeyring(t, "1"))
+	t.Cleanup(func() { SetTokenKeyring(nil) })
+	enc, err := EncryptToken("whsec", aad)
+	if err != nil {
+		t.Fatalf("EncryptToken: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		ids         []string
+		wantChanged bool
+		wantKeyID   string
+	}{
+		{"current key", []string{"1"}, false, "1"},
+		{"new key in front", []string{"2", "1"}, true, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetTokenKeyring(testKeyring(t, tt.ids...))
+			rewrapped, changed, err := RewrapToken(enc)
+			if err != nil {
+				t.Fatalf("RewrapToken: %v", err)
+			}
+			if changed != tt.wantChanged || rewrapped.KeyID != tt.wantKeyID {
+				t.Errorf("RewrapToken = key %s, changed %v, want key %s, changed %v", rewrapped.KeyID, changed, tt.wantKeyID, tt.wantChanged)
+			}
+			if got, err := DecryptToken(rewrapped, aad); err != nil || got != "whsec" {
+				t.Errorf("DecryptToken after rewrap = %q, %v", got, err)
+			}
+		})
+	}
+}
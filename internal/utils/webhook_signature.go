@@ -0,0 +1,56 @@
+// File: internal/utils/webhook_signature.go
+
+// This file contains the payloads and signing of outgoing webhook deliveries.
+// Like Slack's request signatures, the signature is an HMAC-SHA256 of "v1:<timestamp>:<body>",
+// so that receivers can verify both the sender and the freshness of a delivery.
+
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	"twothumbs/internal/models"
+)
+
+const (
+	WebhookEventFeedbackCreated = "feedback.created"
+
+	WebhookSignatureHeader = "X-TwoThumbs-Signature"
+	WebhookTimestampHeader = "X-TwoThumbs-Timestamp"
+	WebhookDeliveryHeader  = "X-TwoThumbs-Delivery"
+	WebhookEventHeader     = "X-TwoThumbs-Event"
+)
+
+// Sign a webhook body sent at the given Unix timestamp, returning the X-TwoThumbs-Signature value
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v1:" + strconv.FormatInt(timestamp, 10) + ":"))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Build the payload of a feedback.created delivery for a stored feedback record
+func FeedbackCreatedPayload(fb *models.Feedback) ([]byte, error) {
+	return json.Marshal(models.WebhookEvent{
+		Event:     WebhookEventFeedbackCreated,
+		Workspace: fb.SlackWorkspace,
+		Feedback: models.WebhookFeedback{
+			ID:           fb.ID,
+			CreatedAt:    fb.CreatedAt,
+			Origin:       fb.Origin,
+			Category:     fb.Category,
+			Prompt:       fb.Prompt,
+			ThumbUp:      fb.ThumbUp,
+			Rating:       fb.Rating,
+			Scale:        fb.Scale,
+			Comment:      fb.Comment,
+			UserID:       fb.UserID,
+			InProduction: fb.InProduction,
+			Metadata:     fb.Metadata,
+		},
+	})
+}
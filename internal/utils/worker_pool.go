@@ -0,0 +1,55 @@
+// File: internal/utils/worker_pool.go
+
+// This file contains a bounded worker pool for background work such as Slack events.
+// A fixed number of workers drain a bounded queue, so a burst of work cannot start an unbounded
+// number of goroutines or exhaust the database connections.
+
+package utils
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+type WorkerPool struct {
+	name string
+	jobs chan func()
+}
+
+// Start a pool of workers sharing a queue of queueSize pending jobs
+func NewWorkerPool(name string, workers, queueSize int) *WorkerPool {
+	p := &WorkerPool{
+		name: name,
+		jobs: make(chan func(), queueSize),
+	}
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+// Queue a job without blocking, returning false if the queue is full
+func (p *WorkerPool) Submit(job func()) bool {
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *WorkerPool) work() {
+	for job := range p.jobs {
+		p.run(job)
+	}
+}
+
+// Run a job, recovering from panics so the worker stays alive
+func (p *WorkerPool) run(job func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Worker pool %s recovered from panic: %v\n%s", p.name, r, debug.Stack())
+		}
+	}()
+	job()
+}
@@ -0,0 +1,214 @@
+// File: internal/webhooks/dispatcher.go
+
+// This file contains the dispatcher of outgoing webhooks. Deliveries of new feedback records are queued in
+// the webhook_deliveries table for each matching webhook, in the transaction that stores the feedback, and
+// the dispatcher sends them right away when woken, signed with the secret of the webhook. Failed deliveries
+// are retried with exponential backoff. The delivery log keeps the number of attempts of each delivery and
+// the outcome of its latest attempt.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"twothumbs/internal/models"
+	"twothumbs/internal/queries"
+	"twothumbs/internal/utils"
+)
+
+const (
+	claimBatchSize = 20               // deliveries attempted concurrently
+	deliveryLease  = 60               // seconds before an unfinished attempt is retried
+	requestTimeout = 10 * time.Second // per attempt
+	pollInterval   = 15 * time.Second // to pick up retries and deliveries queued by other instances
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = 6 * time.Hour
+	maxErrorLen    = 500
+)
+
+type Dispatcher struct {
+	db          *sql.DB
+	client      *http.Client
+	maxAttempts int
+	wake        chan struct{}
+}
+
+// Create a dispatcher giving up on a delivery after maxAttempts. Unless allowPrivateURLs is set,
+// webhooks may only resolve to public addresses, so that they cannot reach internal services.
+func NewDispatcher(conn *sql.DB, maxAttempts int, allowPrivateURLs bool) *Dispatcher {
+	return &Dispatcher{
+		db:          conn,
+		client:      newHTTPClient(allowPrivateURLs),
+		maxAttempts: maxAttempts,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Wake the dispatcher to send due deliveries, e.g. after new feedback has queued some
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Send due deliveries until the context is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		d.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Claim and attempt due deliveries in batches until none are left
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := queries.ClaimWebhookDeliveries(d.db, claimBatchSize, deliveryLease)
+		if err != nil {
+			log.Printf("Failed to claim webhook deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, del := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.attempt(ctx, del)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// Attempt a delivery and record the outcome, scheduling a retry on failure
+func (d *Dispatcher) attempt(ctx context.Context, del models.WebhookDelivery) {
+	if del.SecretErr != nil {
+		// Retrying would fail the same way, so the delivery is given up on right away
+		log.Printf("Webhook delivery %d failed for good: %v", del.ID, del.SecretErr)
+		if err := queries.FailWebhookDelivery(d.db, del.ID, nil, del.SecretErr.Error(), nil); err != nil {
+			log.Printf("Failed to record webhook delivery %d: %v", del.ID, err)
+		}
+		return
+	}
+
+	statusCode, err := d.send(ctx, del)
+	if err == nil {
+		if err := queries.CompleteWebhookDelivery(d.db, del.ID, statusCode); err != nil {
+			log.Printf("Failed to record webhook delivery %d: %v", del.ID, err)
+		}
+		return
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	errMsg := err.Error()
+	if len(errMsg) > maxErrorLen {
+		errMsg = errMsg[:maxErrorLen]
+	}
+	var retryAt *time.Time
+	if del.Attempts < d.maxAttempts {
+		t := time.Now().Add(retryDelay(del.Attempts))
+		retryAt = &t
+		log.Printf("Webhook delivery %d failed (attempt %d/%d), retrying at %s: %v", del.ID, del.Attempts, d.maxAttempts, t.UTC().Format(time.RFC3339), err)
+	} else {
+		log.Printf("Webhook delivery %d failed for good after %d attempts: %v", del.ID, del.Attempts, err)
+	}
+	if err := queries.FailWebhookDelivery(d.db, del.ID, code, errMsg, retryAt); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", del.ID, err)
+	}
+}
+
+// Send a signed delivery, returning the response status code (0 if there is no response)
+func (d *Dispatcher) send(ctx context.Context, del models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TwoThumbs-Webhooks/1.0")
+	req.Header.Set(utils.WebhookEventHeader, utils.WebhookEventFeedbackCreated)
+	req.Header.Set(utils.WebhookDeliveryHeader, strconv.FormatInt(del.ID, 10))
+	req.Header.Set(utils.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(utils.WebhookSignatureHeader, utils.SignWebhookPayload(del.Secret, timestamp, del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Delay before the next attempt, doubling with each attempt, with up to 10% jitter
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, retryMaxDelay)
+	return delay + time.Duration(rand.Int63n(int64(delay/10)+1))
+}
+
+// HTTP client that does not follow redirects, and only connects to public addresses unless allowPrivate is set
+func newHTTPClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		}
+		// A proxy would be dialed instead of the webhook, bypassing the check
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return errors.New("webhook redirects are not followed")
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast())
+}
@@ -0,0 +1,24 @@
+-- Move the single api_key of each account to the api_keys table,
+-- keeping it valid with all scopes under the name "Default"
+
+BEGIN;
+
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    api_key TEXT UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+INSERT INTO api_keys (account_id, name, api_key, scopes, created_at)
+SELECT account_id, 'Default', api_key, ARRAY['write-feedback', 'read-data', 'admin'], created_at
+FROM accounts;
+
+ALTER TABLE accounts DROP COLUMN api_key;
+
+COMMIT;
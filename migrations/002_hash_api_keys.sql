@@ -0,0 +1,26 @@
+-- Replace the plaintext api_key with a display prefix and a salted
+-- SHA-256 hash, matching utils.HashApiKey (hex of sha256(salt || key))
+
+BEGIN;
+
+ALTER TABLE api_keys
+    ADD COLUMN key_prefix TEXT,
+    ADD COLUMN key_salt TEXT,
+    ADD COLUMN key_hash TEXT;
+
+UPDATE api_keys
+SET key_prefix = left(api_key, 13),
+    key_salt = replace(gen_random_uuid()::text, '-', '');
+
+UPDATE api_keys
+SET key_hash = encode(sha256(convert_to(key_salt || api_key, 'UTF8')), 'hex');
+
+ALTER TABLE api_keys
+    ALTER COLUMN key_prefix SET NOT NULL,
+    ALTER COLUMN key_salt SET NOT NULL,
+    ALTER COLUMN key_hash SET NOT NULL,
+    DROP COLUMN api_key;
+
+CREATE INDEX idx_api_keys_key_prefix ON api_keys (key_prefix);
+
+COMMIT;
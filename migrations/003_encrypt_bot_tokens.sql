@@ -0,0 +1,14 @@
+-- Add the columns for envelope encrypted bot tokens. The tokens cannot be
+-- encrypted in SQL, so run cmd/rekey afterwards to encrypt the existing ones,
+-- then apply 004_drop_plaintext_bot_tokens.sql
+
+BEGIN;
+
+ALTER TABLE installations
+    ADD COLUMN bot_token_ciphertext BYTEA,
+    ADD COLUMN bot_token_data_key BYTEA,
+    ADD COLUMN bot_token_key_id TEXT,
+    ADD COLUMN bot_token_hash TEXT,
+    ALTER COLUMN bot_token DROP NOT NULL;
+
+COMMIT;
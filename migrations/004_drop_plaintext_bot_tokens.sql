@@ -0,0 +1,13 @@
+-- Drop the plaintext bot tokens once cmd/rekey has encrypted all of them.
+-- Fails on the NOT NULL constraints if any token is still unencrypted.
+
+BEGIN;
+
+ALTER TABLE installations
+    ALTER COLUMN bot_token_ciphertext SET NOT NULL,
+    ALTER COLUMN bot_token_data_key SET NOT NULL,
+    ALTER COLUMN bot_token_key_id SET NOT NULL,
+    ALTER COLUMN bot_token_hash SET NOT NULL,
+    DROP COLUMN bot_token;
+
+COMMIT;
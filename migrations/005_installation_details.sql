@@ -0,0 +1,14 @@
+-- Record the details of each Slack installation. Installations made before
+-- keep empty values until the app is reinstalled.
+
+BEGIN;
+
+ALTER TABLE installations
+    ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN bot_user_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN installed_by TEXT NOT NULL DEFAULT '',
+    ADD COLUMN enterprise_id TEXT,
+    ADD COLUMN app_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN installed_at TIMESTAMPTZ DEFAULT NOW();
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Store the refresh token and expiry of bot tokens for Slack token rotation.
+-- The refresh token is encrypted with the data key of the bot token.
+
+BEGIN;
+
+ALTER TABLE installations
+    ADD COLUMN refresh_token_ciphertext BYTEA,
+    ADD COLUMN bot_token_expires_at TIMESTAMPTZ;
+
+COMMIT;
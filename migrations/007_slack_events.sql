@@ -0,0 +1,10 @@
+-- Record the Slack event deliveries, so that retries of an event are not processed twice.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS slack_events (
+    event_id TEXT PRIMARY KEY,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,50 @@
+-- Support Enterprise Grid: org-wide installations, whose bot token covers the workspaces of an enterprise,
+-- and accounts linking several workspaces of the same enterprise.
+-- The workspace and digest channel of each account move to account_workspaces, and API keys record the
+-- workspace they were created in, which receives the feedback sent with them.
+
+BEGIN;
+
+ALTER TABLE installations
+    ADD COLUMN is_enterprise_install BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE enterprise_workspaces (
+    slack_workspace TEXT PRIMARY KEY,
+    enterprise_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_enterprise_workspaces_enterprise_id ON enterprise_workspaces (enterprise_id);
+
+INSERT INTO enterprise_workspaces (slack_workspace, enterprise_id)
+SELECT slack_workspace, enterprise_id
+FROM installations
+WHERE enterprise_id IS NOT NULL;
+
+CREATE TABLE account_workspaces (
+    slack_workspace TEXT PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+    slack_channel TEXT,
+    linked_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_account_workspaces_account_id ON account_workspaces (account_id);
+
+INSERT INTO account_workspaces (slack_workspace, account_id, slack_channel, linked_at)
+SELECT slack_workspace, account_id, slack_channel, created_at
+FROM accounts
+WHERE slack_workspace IS NOT NULL;
+
+ALTER TABLE api_keys
+    ADD COLUMN slack_workspace TEXT;
+
+UPDATE api_keys k
+SET slack_workspace = a.slack_workspace
+FROM accounts a
+WHERE a.account_id = k.account_id;
+
+ALTER TABLE accounts
+    DROP COLUMN slack_workspace,
+    DROP COLUMN slack_channel;
+
+COMMIT;
@@ -0,0 +1,38 @@
+-- Add outgoing webhooks, which receive each new feedback row as signed JSON, and their delivery log.
+-- Webhook secrets are encrypted with the master keys like the bot tokens.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    slack_workspace TEXT NOT NULL REFERENCES account_workspaces (slack_workspace) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret_ciphertext BYTEA NOT NULL,
+    secret_data_key BYTEA NOT NULL,
+    secret_key_id TEXT NOT NULL,
+    origins TEXT[] NOT NULL DEFAULT '{}',
+    categories TEXT[] NOT NULL DEFAULT '{}',
+    thumb TEXT NOT NULL DEFAULT 'any' CHECK (thumb IN ('any', 'up', 'down')),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_slack_workspace ON webhooks (slack_workspace);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    feedback_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, created_at);
+
+COMMIT;
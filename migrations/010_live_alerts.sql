@@ -0,0 +1,11 @@
+-- Add live alerts: new thumbs-down comments on live prompts are posted to the output channel
+-- within a minute, and marked as alerted so that they are posted only once.
+
+BEGIN;
+
+ALTER TABLE prompts ADD COLUMN IF NOT EXISTS live BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS alerted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_feedback_live_alerts ON feedback (created_at) WHERE alerted_at IS NULL AND NOT thumb_up AND comment IS NOT NULL;
+
+COMMIT;
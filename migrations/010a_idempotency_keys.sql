@@ -0,0 +1,16 @@
+-- Store the responses of requests sent with an Idempotency-Key header, so that a retried request returns
+-- the original response instead of storing the feedback again. Applied before 011, which binds the keys
+-- to their request.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    account_id BIGINT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    status_code INT,
+    response_body BYTEA,
+    PRIMARY KEY (account_id, idempotency_key)
+);
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- Bind idempotency keys to the method, path, and body of the request they were first used for,
+-- so that reusing a key for a different request is rejected instead of replaying another response.
+-- Keys stored before this migration are replayed unchecked until they expire.
+
+BEGIN;
+
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS request_method TEXT NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS request_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS request_hash TEXT NOT NULL DEFAULT '';
+
+COMMIT;
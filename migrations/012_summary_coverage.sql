@@ -0,0 +1,11 @@
+-- Record how many of the comments of a group each daily summary is based on, as comments beyond the
+-- input limits of the cache job are left out. Digests report the share of comments they are based on.
+-- Summaries stored before this migration are taken to be based on all of their comments.
+
+BEGIN;
+
+ALTER TABLE summaries ADD COLUMN IF NOT EXISTS n_comments_summarized INT;
+UPDATE summaries SET n_comments_summarized = n_comments WHERE n_comments_summarized IS NULL;
+ALTER TABLE summaries ALTER COLUMN n_comments_summarized SET NOT NULL;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Bind encrypted bot tokens and webhook secrets to their row with AES-GCM associated data, so that a
+-- ciphertext copied to another row no longer decrypts. Existing ciphertexts are marked as unbound, and
+-- are encrypted again by cmd/rekey.
+
+BEGIN;
+
+ALTER TABLE installations ADD COLUMN IF NOT EXISTS bot_token_aad BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS secret_aad BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
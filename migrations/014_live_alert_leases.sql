@@ -0,0 +1,12 @@
+-- Live alerts are leased while they are posted and only marked as alerted once their message is sent,
+-- so that alerts claimed by an instance that stops are posted by the next run. Live prompts record when
+-- live mode was turned on, so that only comments from then on are posted.
+
+BEGIN;
+
+ALTER TABLE prompts ADD COLUMN IF NOT EXISTS live_since TIMESTAMPTZ;
+UPDATE prompts SET live_since = NOW() WHERE live AND live_since IS NULL;
+
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS alert_leased_until TIMESTAMPTZ;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- Let feedback carry a CSAT or NPS rating besides thumbs. A prompt keeps the scale of its first feedback,
+-- and feedback stored before this migration is on the thumbs scale.
+
+BEGIN;
+
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS rating SMALLINT;
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS scale TEXT NOT NULL DEFAULT 'thumbs' CHECK (scale IN ('thumbs', 'csat', 'nps'));
+
+ALTER TABLE prompts ADD COLUMN IF NOT EXISTS scale TEXT NOT NULL DEFAULT 'thumbs' CHECK (scale IN ('thumbs', 'csat', 'nps'));
+
+COMMIT;
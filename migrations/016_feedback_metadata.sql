@@ -0,0 +1,11 @@
+-- Let feedback carry an optional metadata object, used to filter and group stats, comments, and raw data.
+-- The GIN index serves the key lookups, and the partial index the scans of a workspace's feedback with metadata.
+
+BEGIN;
+
+ALTER TABLE feedback ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_feedback_metadata ON feedback USING GIN (metadata);
+CREATE INDEX IF NOT EXISTS idx_feedback_metadata_workspace ON feedback (slack_workspace, created_at) WHERE metadata IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- Store the token buckets of the rate limiters in Postgres, so that instances share them
+-- (RATE_LIMIT_BACKEND=postgres). The index serves the cleanup of idle buckets.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+    bucket_key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    last_allowed BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_last_seen ON rate_limits (last_seen);
+
+COMMIT;